@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
 	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
 	"github.com/alecthomas/kong"
 	"google.golang.org/grpc"
@@ -20,7 +24,15 @@ type result struct {
 	Version   uint64    `json:"version"`
 	Data      string    `json:"data"`
 	NodeId    uint64    `json:"nodeId"`
+	Epoch     uint64    `json:"epoch,omitempty"`
 	WriteTime time.Time `json:"writeTime"`
+	Token     []byte    `json:"token,omitempty"`
+}
+
+type watchResult struct {
+	Key string `json:"key"`
+	result
+	Tombstone bool `json:"tombstone,omitempty"`
 }
 
 type Conn struct {
@@ -28,20 +40,72 @@ type Conn struct {
 	Secure   bool   `help:"toggle if this connection routes through TLS"`
 }
 
+type Consistency struct {
+	Consistency string `help:"how many replicas must take part in the request" enum:"one,quorum,all" default:"one"`
+}
+
+func (c Consistency) level() kvstorepb.Consistency {
+	return kvstorepb.Consistency(kvstorepb.Consistency_value[strings.ToUpper(c.Consistency)])
+}
+
 type Get struct {
 	Conn
-	Key string `arg:"" name:"key" help:"Key to retreive" type:"string"`
+	Consistency
+	Key      string        `arg:"" name:"key" help:"Key to retreive" type:"string"`
+	MinToken string        `help:"a base64 token from an earlier put or get. The server waits until it has caught up with that token before reading"`
+	Timeout  time.Duration `help:"how long to wait for the server to catch up with --min-token" default:"5s"`
 }
 
 type Put struct {
 	Conn
-	Key     string `arg:"" name:"key" help:"Key to retreive" type:"string"`
-	Data    string `arg:"" name:"data" help:"The data to put into the key-value store"`
+	Consistency
+	Key         string `arg:"" name:"key" help:"Key to retreive" type:"string"`
+	Data        string `arg:"" name:"data" help:"The data to put into the key-value store"`
+	ExpectClock string `help:"only apply the put if the key's vector clock equals this one. Either the clock of the key as printed by scan --details, or a JSON object of writer to version, where writers are '<node id>@<epoch>', e.g. '{\"7394018230557169321@1700000000000\":2}'. Use '{}' to only put keys that do not exist yet"`
+}
+
+type Delete struct {
+	Conn
+	Key string `arg:"" name:"key" help:"Key to delete" type:"string"`
+}
+
+type Cas struct {
+	Conn
+	Key    string  `arg:"" name:"key" help:"The register to set" type:"string"`
+	Data   string  `arg:"" name:"data" help:"The value to set the register to"`
+	Expect *string `help:"only set the register if it holds this value. Leave unset to only set registers that have never been written"`
+}
+
+type Scan struct {
+	Conn
+	Prefix    string `help:"only list keys that start with this prefix"`
+	Start     string `help:"only list keys at or after this key"`
+	End       string `help:"only list keys before this key"`
+	PageSize  uint32 `help:"the number of keys to list per page"`
+	PageToken string `help:"continue a previous scan from its next page token"`
+	Details   bool   `help:"include the clock and chunk count of each key"`
+	All       bool   `help:"keep fetching pages until every matching key has been listed"`
+}
+
+type Peers struct {
+	Conn
+}
+
+type Watch struct {
+	Conn
+	Key    string `help:"watch a single key"`
+	Prefix string `help:"watch every key that starts with this prefix"`
+	Replay bool   `help:"print the existing chunks of watched keys before streaming new ones"`
 }
 
 var cli struct {
-	Get Get `cmd:"" help:"Get by key"`
-	Put Put `cmd:"" help:"Put key if versions match"`
+	Get    Get    `cmd:"" help:"Get by key"`
+	Put    Put    `cmd:"" help:"Put key if versions match"`
+	Delete Delete `cmd:"" help:"Delete by key"`
+	Cas    Cas    `cmd:"" help:"Compare-and-set a register, agreed on by every node"`
+	Scan   Scan   `cmd:"" help:"List keys in lexicographic order"`
+	Watch  Watch  `cmd:"" help:"Stream new chunks for a key or prefix"`
+	Peers  Peers  `cmd:"" help:"Show how the server's replication with each of its peers is doing"`
 }
 
 func main() {
@@ -51,10 +115,17 @@ func main() {
 }
 
 func (cmd *Get) Run() error {
-	ctx := context.Background()
+	minToken, err := base64.StdEncoding.DecodeString(cmd.MinToken)
+	if err != nil {
+		return fmt.Errorf("decoding min token: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.Timeout)
+	defer cancel()
 	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
 		response, err := client.Get(ctx, &kvstorepb.GetRequest{
-			Key: cmd.Key,
+			Key:         cmd.Key,
+			MinToken:    minToken,
+			Consistency: cmd.level(),
 		})
 		if err != nil {
 			return err
@@ -72,6 +143,8 @@ func (cmd *Get) Run() error {
 				WriteTime: time.UnixMilli(int64(response.WriteTimeUnixMillis)),
 				Version:   response.Version,
 				NodeId:    response.NodeId,
+				Epoch:     response.Epoch,
+				Token:     response.Token,
 			}
 			stringResults, err := json.Marshal(result)
 			if err != nil {
@@ -85,10 +158,33 @@ func (cmd *Get) Run() error {
 
 func (cmd *Put) Run() error {
 	ctx := context.Background()
+	request := &kvstorepb.PutRequest{
+		Key:         cmd.Key,
+		Update:      []byte(cmd.Data),
+		Consistency: cmd.level(),
+	}
+	if cmd.ExpectClock != "" {
+		clock, err := parseClock(cmd.ExpectClock)
+		if err != nil {
+			return fmt.Errorf("parsing expected clock: %w", err)
+		}
+		request.ExpectedClock = clock
+	}
 	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
-		response, err := client.Put(ctx, &kvstorepb.PutRequest{
-			Key:    cmd.Key,
-			Update: []byte(cmd.Data),
+		response, err := client.Put(ctx, request)
+		if err != nil {
+			return err
+		}
+		fmt.Println(protojson.Format(response))
+		return nil
+	})
+}
+
+func (cmd *Delete) Run() error {
+	ctx := context.Background()
+	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
+		response, err := client.Delete(ctx, &kvstorepb.DeleteRequest{
+			Key: cmd.Key,
 		})
 		if err != nil {
 			return err
@@ -98,6 +194,126 @@ func (cmd *Put) Run() error {
 	})
 }
 
+func (cmd *Cas) Run() error {
+	ctx := context.Background()
+	request := &kvstorepb.CompareAndSetRequest{
+		Key:    cmd.Key,
+		Update: []byte(cmd.Data),
+	}
+	if cmd.Expect != nil {
+		request.Expected = []byte(*cmd.Expect)
+	}
+	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
+		response, err := client.CompareAndSet(ctx, request)
+		if err != nil {
+			return err
+		}
+		fmt.Println(protojson.Format(response))
+		return nil
+	})
+}
+
+func (cmd *Scan) Run() error {
+	ctx := context.Background()
+	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
+		pageToken := cmd.PageToken
+		for {
+			response, err := client.Scan(ctx, &kvstorepb.ScanRequest{
+				Prefix:         cmd.Prefix,
+				StartKey:       cmd.Start,
+				EndKey:         cmd.End,
+				PageSize:       cmd.PageSize,
+				PageToken:      pageToken,
+				IncludeDetails: cmd.Details,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(protojson.Format(response))
+			pageToken = response.GetNextPageToken()
+			if !cmd.All || pageToken == "" {
+				return nil
+			}
+		}
+	})
+}
+
+func (cmd *Watch) Run() error {
+	ctx := context.Background()
+	return withKvClient(cmd.Conn.Hostname, cmd.Conn.Secure, func(client kvstorepb.KvstoreClient) error {
+		stream, err := client.Watch(ctx, &kvstorepb.WatchRequest{
+			Key:    cmd.Key,
+			Prefix: cmd.Prefix,
+			Replay: cmd.Replay,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("getting next chunk: %w", err)
+			}
+			result := &watchResult{
+				Key: response.Key,
+				result: result{
+					Data:      string(response.Data),
+					WriteTime: time.UnixMilli(int64(response.WriteTimeUnixMillis)),
+					Version:   response.Version,
+					NodeId:    response.NodeId,
+					Epoch:     response.Epoch,
+				},
+				Tombstone: response.Tombstone,
+			}
+			stringResults, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("marshaling response json: %w", err)
+			}
+			fmt.Println(string(stringResults))
+		}
+	})
+}
+
+// parseClock reads a clock as scan --details prints it, or a JSON object of writer to
+// version. Writers in their first epoch may be given by node id alone.
+func parseClock(value string) (*kvstorepb.VectorClock, error) {
+	printed := &kvstorepb.VectorClock{}
+	if err := protojson.Unmarshal([]byte(value), printed); err == nil {
+		return printed, nil
+	}
+	var versions map[string]uint64
+	if err := json.Unmarshal([]byte(value), &versions); err != nil {
+		return nil, err
+	}
+	clock := &kvstorepb.VectorClock{
+		Clock: map[uint64]uint64{},
+	}
+	for writer, version := range versions {
+		node, epoch, _ := strings.Cut(writer, "@")
+		nodeId, err := strconv.ParseUint(node, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node id of %s: %w", writer, err)
+		}
+		if epoch == "" {
+			clock.Clock[nodeId] = version
+			continue
+		}
+		epochValue, err := strconv.ParseUint(epoch, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing epoch of %s: %w", writer, err)
+		}
+		clock.Entries = append(clock.Entries, &kvstorepb.VersionEntry{
+			NodeId:  nodeId,
+			Epoch:   epochValue,
+			Version: version,
+		})
+	}
+	return clock, nil
+}
+
 func getGrpcClient(hostname string, secure bool) (*grpc.ClientConn, error) {
 	var creds credentials.TransportCredentials
 	if secure {
@@ -108,6 +324,20 @@ func getGrpcClient(hostname string, secure bool) (*grpc.ClientConn, error) {
 	return grpc.NewClient(hostname, grpc.WithTransportCredentials(creds))
 }
 
+func (cmd *Peers) Run() error {
+	conn, err := getGrpcClient(cmd.Conn.Hostname, cmd.Conn.Secure)
+	if err != nil {
+		return fmt.Errorf("connecting to grpc server: %w", err)
+	}
+	defer conn.Close()
+	response, err := clockspb.NewClocksClient(conn).Peers(context.Background(), &clockspb.PeersRequest{})
+	if err != nil {
+		return err
+	}
+	fmt.Println(protojson.Format(response))
+	return nil
+}
+
 func withKvClient(
 	hostname string,
 	secure bool,
@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/gen/go/membership/v1"
+	"github.com/WadeCappa/consensus/gen/go/raft/v1"
 	"github.com/WadeCappa/consensus/internal/clocksclient"
 	"github.com/WadeCappa/consensus/internal/clockserver"
 	"github.com/WadeCappa/consensus/internal/db"
 	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/internal/membership"
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/WadeCappa/consensus/internal/raft"
+	"github.com/WadeCappa/consensus/internal/strong"
 	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
 	"google.golang.org/grpc"
 )
@@ -21,39 +31,205 @@ import (
 var (
 	port    = flag.Int("port", 3100, "The server port")
 	secure  = flag.Bool("secure", false, "Set this flag if we connect to remote servers with TLS")
-	servers []string
+	dataDir = flag.String("data-dir", "", "Directory for the write-ahead log and snapshots. Leave empty to keep all data in memory")
+	storage = flag.String("storage", "memory", "The storage engine to use, either 'memory' or 'file'. The file engine requires --data-dir")
+
+	snapshotInterval = flag.Duration("snapshot-interval", time.Minute*10, "How often to snapshot the database and truncate the write-ahead log. Set to 0 to disable snapshots")
+	snapshotRetain   = flag.Int("snapshot-retain", 2, "How many snapshots, along with the log segments they depend on, to keep on disk")
+
+	pinnedId      = flag.Uint64("node-id", 0, "Pin this node's id instead of generating one. A node that comes back without its data directory keeps its id but starts a new epoch")
+	strongPrefix  = flag.String("strong-prefix", "", "Keys starting with this prefix are replicated through Raft and read linearizably. Leave empty to make every key eventually consistent")
+	raftBootstrap = flag.Bool("raft-bootstrap", false, "Start a new Raft cluster with this node as its only member. Other nodes join it through --seeds")
+
+	clusterSize = flag.Int("cluster-size", 1, "How many nodes make up the cluster, which every node must agree on. Compare-and-sets, QUORUM and ALL requests and tombstone collection need a majority or all of this many nodes, however many are reachable")
+
+	advertise = flag.String("advertise", "", "The address other members reach this node at. Defaults to localhost:<port>")
+
+	seeds []string
 )
 
 func main() {
-	flag.Func("servers", "the hostnames for servers that this node should drive consensus with. Deliminate this list with ','", func(s string) error {
-		servers = strings.Split(s, ",")
+	flag.Func("seeds", "the hostnames of existing members to join the cluster through. Deliminate this list with ','", func(s string) error {
+		seeds = strings.Split(s, ",")
 		return nil
 	})
 	flag.Parse()
+	if *clusterSize < 1 || len(seeds) > 0 && *clusterSize < 2 {
+		log.Fatalf("--cluster-size must count every node in the cluster, got %d", *clusterSize)
+	}
+	if *advertise == "" {
+		*advertise = fmt.Sprintf("localhost:%d", *port)
+	}
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	// Stopping waits for running handlers, so that nothing writes to the database once it
+	// is closed.
+	s := grpc.NewServer(grpc.WaitForHandlers(true))
 
-	fmt.Printf("listening to %s\n", servers)
+	local, err := incarnation()
+	if err != nil {
+		log.Fatalf("failed to load node identity: %v", err)
+	}
+	log.Printf("running as node %d in epoch %d", local.NodeId, local.Epoch)
 
-	db := db.NewDatabase(uint64(*port))
+	db, err := openDatabase(local)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
 
-	kvServer := kvserver.NewKvServer(db)
+	client := clocksclient.NewClocksClient(db, local, *clusterSize, *secure, time.Second*3)
+
+	// The kv server must see a nil interface, not a nil keyspace, when there is no strongly
+	// consistent keyspace.
+	var strongKeyspace kvserver.StrongKeyspace
+	var keyspace *strong.Keyspace
+	var raftNode *raft.Node
+	if *strongPrefix != "" {
+		raftNode, err = openRaft(local.NodeId)
+		if err != nil {
+			log.Fatalf("failed to open raft: %v", err)
+		}
+		raftpb.RegisterRaftServer(s, raft.NewRaftServer(raftNode))
+		keyspace = strong.NewKeyspace(*strongPrefix, local, raftNode)
+		strongKeyspace = keyspace
+	}
+
+	acceptor, err := openAcceptor()
+	if err != nil {
+		log.Fatalf("failed to open paxos acceptor: %v", err)
+	}
+	proposer := paxos.NewProposer(local.NodeId, acceptor, client)
+
+	kvServer := kvserver.NewKvServer(db, client, strongKeyspace, proposer)
 	kvstorepb.RegisterKvstoreServer(s, kvServer)
 
-	clockServer := clockserver.NewClockServer(db)
+	clockServer := clockserver.NewClockServer(db, local, acceptor, client)
 	clockspb.RegisterClocksServer(s, clockServer)
 
-	client := clocksclient.NewClocksClient(db, *secure, time.Second*3)
-	for _, server := range servers {
-		go client.RunAcksWithRetry(context.Background(), server)
-		go client.SendDataWithRetry(context.Background(), server)
+	members := membership.NewMembership(local.NodeId, *advertise, membership.DefaultConfig(*secure), client)
+	membershippb.RegisterMembershipServer(s, membership.NewMembershipServer(members))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go members.Run(ctx)
+	if len(seeds) > 0 {
+		go members.JoinWithRetry(ctx, seeds)
+	}
+	go client.CollectTombstones(ctx)
+	if *storage == "memory" && *dataDir != "" && *snapshotInterval > 0 {
+		go snapshotPeriodically(ctx, db)
+	}
+
+	// Raft keeps running while we leave, so that this node can still take part in
+	// committing its own removal.
+	raftCtx, stopRaft := context.WithCancel(context.Background())
+	if raftNode != nil {
+		go raftNode.Run(raftCtx, keyspace)
+		if !*raftBootstrap && len(seeds) > 0 {
+			go raftNode.JoinWithRetry(ctx, seeds)
+		}
 	}
 
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		leaveCtx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+		defer cancel()
+		members.Leave(leaveCtx)
+		if raftNode != nil {
+			if err := raftNode.RemoveServer(leaveCtx, local.NodeId); err != nil {
+				log.Printf("failed to leave raft cluster: %v", err)
+			}
+		}
+		stopRaft()
+		s.Stop()
+	}()
+
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	<-stopped
+	if err := db.Close(); err != nil {
+		log.Printf("failed to close database: %v", err)
+	}
+	if err := acceptor.Close(); err != nil {
+		log.Printf("failed to close paxos acceptor: %v", err)
+	}
+}
+
+func openRaft(nodeId uint64) (*raft.Node, error) {
+	var dir string
+	if *dataDir != "" {
+		dir = filepath.Join(*dataDir, "raft")
+	}
+	node, err := raft.NewNode(nodeId, *advertise, dir, raft.DefaultConfig(*secure))
+	if err != nil {
+		return nil, err
+	}
+	if *raftBootstrap {
+		if err := node.Bootstrap(); err != nil {
+			return nil, fmt.Errorf("bootstrapping raft cluster: %w", err)
+		}
+	}
+	return node, nil
+}
+
+func openAcceptor() (*paxos.Acceptor, error) {
+	if *dataDir == "" {
+		return paxos.NewAcceptor(), nil
+	}
+	return paxos.OpenAcceptor(filepath.Join(*dataDir, "paxos"))
+}
+
+// incarnation loads this node's identity from the data directory so that it survives
+// restarts. Nodes without a data directory lose their data on restart anyway, so they
+// always start a new epoch.
+func incarnation() (db.Incarnation, error) {
+	if *dataDir == "" {
+		return db.NewIncarnation(*pinnedId), nil
+	}
+	return db.LoadIncarnation(*dataDir, *pinnedId)
+}
+
+func openDatabase(local db.Incarnation) (*db.Database, error) {
+	switch *storage {
+	case "memory":
+		if *dataDir == "" {
+			return db.NewDatabase(local), nil
+		}
+		return db.OpenDatabase(local, *dataDir)
+	case "file":
+		if *dataDir == "" {
+			return nil, fmt.Errorf("the file storage engine requires --data-dir")
+		}
+		if err := os.MkdirAll(*dataDir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating data directory: %w", err)
+		}
+		engine, err := db.OpenFileEngine(filepath.Join(*dataDir, "records.db"))
+		if err != nil {
+			return nil, fmt.Errorf("opening file storage engine: %w", err)
+		}
+		return db.NewDatabaseWithEngine(local, engine)
+	default:
+		return nil, fmt.Errorf("unrecognized storage engine %q", *storage)
+	}
+}
+
+func snapshotPeriodically(ctx context.Context, data *db.Database) {
+	ticker := time.NewTicker(*snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := data.Snapshot(*snapshotRetain); err != nil {
+			log.Printf("failed to snapshot database: %v", err)
+		}
+	}
 }
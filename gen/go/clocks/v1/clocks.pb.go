@@ -21,6 +21,307 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type PaxosPhase int32
+
+const (
+	PaxosPhase_PREPARE PaxosPhase = 0
+	PaxosPhase_ACCEPT  PaxosPhase = 1
+	PaxosPhase_COMMIT  PaxosPhase = 2
+)
+
+// Enum value maps for PaxosPhase.
+var (
+	PaxosPhase_name = map[int32]string{
+		0: "PREPARE",
+		1: "ACCEPT",
+		2: "COMMIT",
+	}
+	PaxosPhase_value = map[string]int32{
+		"PREPARE": 0,
+		"ACCEPT":  1,
+		"COMMIT":  2,
+	}
+)
+
+func (x PaxosPhase) Enum() *PaxosPhase {
+	p := new(PaxosPhase)
+	*p = x
+	return p
+}
+
+func (x PaxosPhase) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PaxosPhase) Descriptor() protoreflect.EnumDescriptor {
+	return file_clocks_v1_clocks_proto_enumTypes[0].Descriptor()
+}
+
+func (PaxosPhase) Type() protoreflect.EnumType {
+	return &file_clocks_v1_clocks_proto_enumTypes[0]
+}
+
+func (x PaxosPhase) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PaxosPhase.Descriptor instead.
+func (PaxosPhase) EnumDescriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{0}
+}
+
+type PeerStatus int32
+
+const (
+	PeerStatus_CONNECTING PeerStatus = 0
+	PeerStatus_SYNCING    PeerStatus = 1
+	// The last attempt failed and the next one is scheduled for retryAt.
+	PeerStatus_BACKING_OFF PeerStatus = 2
+	// The peer failed too many times in a row. Nothing is sent to it until retryAt.
+	PeerStatus_CIRCUIT_OPEN PeerStatus = 3
+)
+
+// Enum value maps for PeerStatus.
+var (
+	PeerStatus_name = map[int32]string{
+		0: "CONNECTING",
+		1: "SYNCING",
+		2: "BACKING_OFF",
+		3: "CIRCUIT_OPEN",
+	}
+	PeerStatus_value = map[string]int32{
+		"CONNECTING":   0,
+		"SYNCING":      1,
+		"BACKING_OFF":  2,
+		"CIRCUIT_OPEN": 3,
+	}
+)
+
+func (x PeerStatus) Enum() *PeerStatus {
+	p := new(PeerStatus)
+	*p = x
+	return p
+}
+
+func (x PeerStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PeerStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_clocks_v1_clocks_proto_enumTypes[1].Descriptor()
+}
+
+func (PeerStatus) Type() protoreflect.EnumType {
+	return &file_clocks_v1_clocks_proto_enumTypes[1]
+}
+
+func (x PeerStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PeerStatus.Descriptor instead.
+func (PeerStatus) EnumDescriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{1}
+}
+
+type HelloRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeId        uint64                 `protobuf:"varint,1,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HelloRequest) Reset() {
+	*x = HelloRequest{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HelloRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HelloRequest) ProtoMessage() {}
+
+func (x *HelloRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HelloRequest.ProtoReflect.Descriptor instead.
+func (*HelloRequest) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *HelloRequest) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+type HelloResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	NodeId uint64                 `protobuf:"varint,1,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	// Changes whenever the node restarts without its previous state.
+	Epoch         uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HelloResponse) Reset() {
+	*x = HelloResponse{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HelloResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HelloResponse) ProtoMessage() {}
+
+func (x *HelloResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HelloResponse.ProtoReflect.Descriptor instead.
+func (*HelloResponse) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HelloResponse) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *HelloResponse) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+type SyncMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identify the dialing node. Only set on its first message.
+	NodeId uint64 `protobuf:"varint,1,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Epoch  uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	// Keys that changed on the sender, each with the chunks the receiver has not acknowledged.
+	// A record without chunks only tells the receiver which clock the sender holds.
+	Records []*PublishRequest `protobuf:"bytes,3,rep,name=records,proto3" json:"records,omitempty"`
+	// The clocks of keys after merging records from the receiver.
+	Acks []*KeyClock `protobuf:"bytes,4,rep,name=acks,proto3" json:"acks,omitempty"`
+	// Set on the last message of a batch of changes to the change of the sender's database
+	// that the batch reaches. The receiver sends it back as acknowledged once it has merged
+	// the batch, and a new stream resumes publishing after the last acknowledged change.
+	Checkpoint   uint64 `protobuf:"varint,5,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	Acknowledged uint64 `protobuf:"varint,6,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	// Merkle tree leaves the sender disagrees with the receiver on. The receiver publishes
+	// every key under them.
+	Leaves        []uint32 `protobuf:"varint,7,rep,packed,name=leaves,proto3" json:"leaves,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncMessage) Reset() {
+	*x = SyncMessage{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncMessage) ProtoMessage() {}
+
+func (x *SyncMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncMessage.ProtoReflect.Descriptor instead.
+func (*SyncMessage) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SyncMessage) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetRecords() []*PublishRequest {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *SyncMessage) GetAcks() []*KeyClock {
+	if x != nil {
+		return x.Acks
+	}
+	return nil
+}
+
+func (x *SyncMessage) GetCheckpoint() uint64 {
+	if x != nil {
+		return x.Checkpoint
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetAcknowledged() uint64 {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return 0
+}
+
+func (x *SyncMessage) GetLeaves() []uint32 {
+	if x != nil {
+		return x.Leaves
+	}
+	return nil
+}
+
 type PublishRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Clock         *VectorClock           `protobuf:"bytes,1,opt,name=clock,proto3" json:"clock,omitempty"`
@@ -32,7 +333,7 @@ type PublishRequest struct {
 
 func (x *PublishRequest) Reset() {
 	*x = PublishRequest{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[0]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -44,7 +345,7 @@ func (x *PublishRequest) String() string {
 func (*PublishRequest) ProtoMessage() {}
 
 func (x *PublishRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[0]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -57,7 +358,7 @@ func (x *PublishRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PublishRequest.ProtoReflect.Descriptor instead.
 func (*PublishRequest) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{0}
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *PublishRequest) GetClock() *VectorClock {
@@ -89,7 +390,7 @@ type PublishResponse struct {
 
 func (x *PublishResponse) Reset() {
 	*x = PublishResponse{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[1]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -101,7 +402,7 @@ func (x *PublishResponse) String() string {
 func (*PublishResponse) ProtoMessage() {}
 
 func (x *PublishResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[1]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -114,18 +415,21 @@ func (x *PublishResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PublishResponse.ProtoReflect.Descriptor instead.
 func (*PublishResponse) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{1}
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{4}
 }
 
 type AckRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The token of the last checkpoint received on a previous stream. The server only sends
+	// clocks that changed after it, or every clock if the token is from before a restart.
+	ResumeToken   []byte `protobuf:"bytes,1,opt,name=resumeToken,proto3" json:"resumeToken,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *AckRequest) Reset() {
 	*x = AckRequest{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[2]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -137,7 +441,7 @@ func (x *AckRequest) String() string {
 func (*AckRequest) ProtoMessage() {}
 
 func (x *AckRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[2]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -150,20 +454,29 @@ func (x *AckRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AckRequest.ProtoReflect.Descriptor instead.
 func (*AckRequest) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{2}
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AckRequest) GetResumeToken() []byte {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return nil
 }
 
 type AckResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Clock         *VectorClock           `protobuf:"bytes,1,opt,name=clock,proto3" json:"clock,omitempty"`
-	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Clock *VectorClock           `protobuf:"bytes,1,opt,name=clock,proto3" json:"clock,omitempty"`
+	Key   string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// Set on checkpoints, which carry no key and follow every batch of clocks.
+	ResumeToken   []byte `protobuf:"bytes,3,opt,name=resumeToken,proto3" json:"resumeToken,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *AckResponse) Reset() {
 	*x = AckResponse{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[3]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -175,7 +488,7 @@ func (x *AckResponse) String() string {
 func (*AckResponse) ProtoMessage() {}
 
 func (x *AckResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[3]
+	mi := &file_clocks_v1_clocks_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -188,7 +501,7 @@ func (x *AckResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AckResponse.ProtoReflect.Descriptor instead.
 func (*AckResponse) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{3}
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *AckResponse) GetClock() *VectorClock {
@@ -205,28 +518,39 @@ func (x *AckResponse) GetKey() string {
 	return ""
 }
 
-type VectorClock struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Clock         map[uint64]uint64      `protobuf:"bytes,1,rep,name=clock,proto3" json:"clock,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+func (x *AckResponse) GetResumeToken() []byte {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return nil
+}
+
+// The contents of an Ack resume token.
+type AckPosition struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the server process. Positions are meaningless once it restarts.
+	Instance uint64 `protobuf:"varint,1,opt,name=instance,proto3" json:"instance,omitempty"`
+	// The last change to the server's database sent before the checkpoint.
+	Sequence      uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *VectorClock) Reset() {
-	*x = VectorClock{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[4]
+func (x *AckPosition) Reset() {
+	*x = AckPosition{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *VectorClock) String() string {
+func (x *AckPosition) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*VectorClock) ProtoMessage() {}
+func (*AckPosition) ProtoMessage() {}
 
-func (x *VectorClock) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[4]
+func (x *AckPosition) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -237,43 +561,47 @@ func (x *VectorClock) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use VectorClock.ProtoReflect.Descriptor instead.
-func (*VectorClock) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use AckPosition.ProtoReflect.Descriptor instead.
+func (*AckPosition) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *VectorClock) GetClock() map[uint64]uint64 {
+func (x *AckPosition) GetInstance() uint64 {
 	if x != nil {
-		return x.Clock
+		return x.Instance
 	}
-	return nil
+	return 0
 }
 
-type Chunk struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	Data                []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	NodeId              uint64                 `protobuf:"varint,2,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
-	Version             uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
-	WriteTimeUnixMillis uint64                 `protobuf:"varint,4,opt,name=writeTimeUnixMillis,proto3" json:"writeTimeUnixMillis,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+func (x *AckPosition) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
 }
 
-func (x *Chunk) Reset() {
-	*x = Chunk{}
-	mi := &file_clocks_v1_clocks_proto_msgTypes[5]
+type FetchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchRequest) Reset() {
+	*x = FetchRequest{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Chunk) String() string {
+func (x *FetchRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Chunk) ProtoMessage() {}
+func (*FetchRequest) ProtoMessage() {}
 
-func (x *Chunk) ProtoReflect() protoreflect.Message {
-	mi := &file_clocks_v1_clocks_proto_msgTypes[5]
+func (x *FetchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -284,35 +612,948 @@ func (x *Chunk) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
-func (*Chunk) Descriptor() ([]byte, []int) {
-	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use FetchRequest.ProtoReflect.Descriptor instead.
+func (*FetchRequest) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *Chunk) GetData() []byte {
+func (x *FetchRequest) GetKey() string {
 	if x != nil {
-		return x.Data
+		return x.Key
+	}
+	return ""
+}
+
+type FetchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Found         bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Clock         *VectorClock           `protobuf:"bytes,2,opt,name=clock,proto3" json:"clock,omitempty"`
+	Chunks        []*Chunk               `protobuf:"bytes,3,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchResponse) Reset() {
+	*x = FetchResponse{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchResponse) ProtoMessage() {}
+
+func (x *FetchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchResponse.ProtoReflect.Descriptor instead.
+func (*FetchResponse) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FetchResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *FetchResponse) GetClock() *VectorClock {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *FetchResponse) GetChunks() []*Chunk {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+type DigestsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Tree nodes to return the digests of. The root is node 0 and the children of node n are
+	// n*16+1 through n*16+16.
+	Nodes []uint32 `protobuf:"varint,1,rep,packed,name=nodes,proto3" json:"nodes,omitempty"`
+	// Leaves to return the clocks of every key under.
+	Leaves        []uint32 `protobuf:"varint,2,rep,packed,name=leaves,proto3" json:"leaves,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DigestsRequest) Reset() {
+	*x = DigestsRequest{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DigestsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DigestsRequest) ProtoMessage() {}
+
+func (x *DigestsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DigestsRequest.ProtoReflect.Descriptor instead.
+func (*DigestsRequest) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DigestsRequest) GetNodes() []uint32 {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *DigestsRequest) GetLeaves() []uint32 {
+	if x != nil {
+		return x.Leaves
+	}
+	return nil
+}
+
+type DigestsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One digest for each requested node, in the same order.
+	Digests       []uint64    `protobuf:"varint,1,rep,packed,name=digests,proto3" json:"digests,omitempty"`
+	Clocks        []*KeyClock `protobuf:"bytes,2,rep,name=clocks,proto3" json:"clocks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DigestsResponse) Reset() {
+	*x = DigestsResponse{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DigestsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DigestsResponse) ProtoMessage() {}
+
+func (x *DigestsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DigestsResponse.ProtoReflect.Descriptor instead.
+func (*DigestsResponse) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DigestsResponse) GetDigests() []uint64 {
+	if x != nil {
+		return x.Digests
+	}
+	return nil
+}
+
+func (x *DigestsResponse) GetClocks() []*KeyClock {
+	if x != nil {
+		return x.Clocks
+	}
+	return nil
+}
+
+type KeyClock struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Clock         *VectorClock           `protobuf:"bytes,2,opt,name=clock,proto3" json:"clock,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyClock) Reset() {
+	*x = KeyClock{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyClock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyClock) ProtoMessage() {}
+
+func (x *KeyClock) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyClock.ProtoReflect.Descriptor instead.
+func (*KeyClock) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *KeyClock) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyClock) GetClock() *VectorClock {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+type VectorClock struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Versions of writers in their first epoch, keyed by node id.
+	Clock map[uint64]uint64 `protobuf:"bytes,1,rep,name=clock,proto3" json:"clock,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Versions of writers in any later epoch.
+	Entries       []*VersionEntry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VectorClock) Reset() {
+	*x = VectorClock{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VectorClock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VectorClock) ProtoMessage() {}
+
+func (x *VectorClock) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VectorClock.ProtoReflect.Descriptor instead.
+func (*VectorClock) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *VectorClock) GetClock() map[uint64]uint64 {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *VectorClock) GetEntries() []*VersionEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type VersionEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeId        uint64                 `protobuf:"varint,1,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Epoch         uint64                 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Version       uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionEntry) Reset() {
+	*x = VersionEntry{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionEntry) ProtoMessage() {}
+
+func (x *VersionEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionEntry.ProtoReflect.Descriptor instead.
+func (*VersionEntry) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *VersionEntry) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *VersionEntry) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *VersionEntry) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type Chunk struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Data                []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	NodeId              uint64                 `protobuf:"varint,2,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Version             uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	WriteTimeUnixMillis uint64                 `protobuf:"varint,4,opt,name=writeTimeUnixMillis,proto3" json:"writeTimeUnixMillis,omitempty"`
+	// Tombstones mark the key as deleted. They carry no data and hide every chunk ordered
+	// before them.
+	Tombstone bool `protobuf:"varint,5,opt,name=tombstone,proto3" json:"tombstone,omitempty"`
+	// The epoch of the node when it wrote this chunk. Versions are only unique within an epoch.
+	Epoch uint64 `protobuf:"varint,6,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	// Together with writeTimeUnixMillis, the hybrid logical clock reading the chunk was written
+	// at. Orders chunks written within the same millisecond.
+	WriteTimeLogical uint32 `protobuf:"varint,7,opt,name=writeTimeLogical,proto3" json:"writeTimeLogical,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Chunk) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *Chunk) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Chunk) GetWriteTimeUnixMillis() uint64 {
+	if x != nil {
+		return x.WriteTimeUnixMillis
+	}
+	return 0
+}
+
+func (x *Chunk) GetTombstone() bool {
+	if x != nil {
+		return x.Tombstone
+	}
+	return false
+}
+
+func (x *Chunk) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Chunk) GetWriteTimeLogical() uint32 {
+	if x != nil {
+		return x.WriteTimeLogical
+	}
+	return 0
+}
+
+// Ballots are ordered by counter and then by the id of the node that proposed them, so no
+// two proposers ever use the same ballot.
+type Ballot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counter       uint64                 `protobuf:"varint,1,opt,name=counter,proto3" json:"counter,omitempty"`
+	NodeId        uint64                 `protobuf:"varint,2,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ballot) Reset() {
+	*x = Ballot{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ballot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ballot) ProtoMessage() {}
+
+func (x *Ballot) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ballot.ProtoReflect.Descriptor instead.
+func (*Ballot) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Ballot) GetCounter() uint64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+func (x *Ballot) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+type Proposal struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Ballot *Ballot                `protobuf:"bytes,1,opt,name=ballot,proto3" json:"ballot,omitempty"`
+	Value  []byte                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// The ballot the value was first proposed with. Proposers that finish an earlier round keep
+	// it, so that the original proposer can recognize its value once it is chosen.
+	Origin *Ballot `protobuf:"bytes,3,opt,name=origin,proto3" json:"origin,omitempty"`
+	// How many values the register has held, counting this one. Every value is proposed
+	// against the one before it, so each sequence number is only ever chosen once.
+	Sequence      uint64 `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Proposal) Reset() {
+	*x = Proposal{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Proposal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Proposal) ProtoMessage() {}
+
+func (x *Proposal) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Proposal.ProtoReflect.Descriptor instead.
+func (*Proposal) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Proposal) GetBallot() *Ballot {
+	if x != nil {
+		return x.Ballot
 	}
 	return nil
 }
 
-func (x *Chunk) GetNodeId() uint64 {
+func (x *Proposal) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Proposal) GetOrigin() *Ballot {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *Proposal) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+type PaxosRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Phase  PaxosPhase             `protobuf:"varint,1,opt,name=phase,proto3,enum=clocks.PaxosPhase" json:"phase,omitempty"`
+	Key    string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Ballot *Ballot                `protobuf:"bytes,3,opt,name=ballot,proto3" json:"ballot,omitempty"`
+	// The proposal being accepted or committed, unset when preparing.
+	Proposal      *Proposal `protobuf:"bytes,4,opt,name=proposal,proto3" json:"proposal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaxosRequest) Reset() {
+	*x = PaxosRequest{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaxosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaxosRequest) ProtoMessage() {}
+
+func (x *PaxosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaxosRequest.ProtoReflect.Descriptor instead.
+func (*PaxosRequest) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PaxosRequest) GetPhase() PaxosPhase {
+	if x != nil {
+		return x.Phase
+	}
+	return PaxosPhase_PREPARE
+}
+
+func (x *PaxosRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *PaxosRequest) GetBallot() *Ballot {
+	if x != nil {
+		return x.Ballot
+	}
+	return nil
+}
+
+func (x *PaxosRequest) GetProposal() *Proposal {
+	if x != nil {
+		return x.Proposal
+	}
+	return nil
+}
+
+type PaxosResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether the acceptor promised or accepted the ballot. Commits always succeed.
+	Ok bool `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	// The highest ballot the acceptor has promised.
+	Promised *Ballot `protobuf:"bytes,2,opt,name=promised,proto3" json:"promised,omitempty"`
+	// The proposal the acceptor most recently accepted but has not seen committed, if any.
+	Accepted *Proposal `protobuf:"bytes,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// The most recent committed proposal, unset if the register has never been written.
+	Committed     *Proposal `protobuf:"bytes,4,opt,name=committed,proto3" json:"committed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaxosResponse) Reset() {
+	*x = PaxosResponse{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaxosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaxosResponse) ProtoMessage() {}
+
+func (x *PaxosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaxosResponse.ProtoReflect.Descriptor instead.
+func (*PaxosResponse) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PaxosResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *PaxosResponse) GetPromised() *Ballot {
+	if x != nil {
+		return x.Promised
+	}
+	return nil
+}
+
+func (x *PaxosResponse) GetAccepted() *Proposal {
+	if x != nil {
+		return x.Accepted
+	}
+	return nil
+}
+
+func (x *PaxosResponse) GetCommitted() *Proposal {
+	if x != nil {
+		return x.Committed
+	}
+	return nil
+}
+
+// The state an acceptor keeps for a single register, as written to disk.
+type PaxosState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Promised      *Ballot                `protobuf:"bytes,2,opt,name=promised,proto3" json:"promised,omitempty"`
+	Accepted      *Proposal              `protobuf:"bytes,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Committed     *Proposal              `protobuf:"bytes,4,opt,name=committed,proto3" json:"committed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaxosState) Reset() {
+	*x = PaxosState{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaxosState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaxosState) ProtoMessage() {}
+
+func (x *PaxosState) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaxosState.ProtoReflect.Descriptor instead.
+func (*PaxosState) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *PaxosState) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *PaxosState) GetPromised() *Ballot {
+	if x != nil {
+		return x.Promised
+	}
+	return nil
+}
+
+func (x *PaxosState) GetAccepted() *Proposal {
+	if x != nil {
+		return x.Accepted
+	}
+	return nil
+}
+
+func (x *PaxosState) GetCommitted() *Proposal {
+	if x != nil {
+		return x.Committed
+	}
+	return nil
+}
+
+type PeersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeersRequest) Reset() {
+	*x = PeersRequest{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeersRequest) ProtoMessage() {}
+
+func (x *PeersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeersRequest.ProtoReflect.Descriptor instead.
+func (*PeersRequest) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{21}
+}
+
+type PeersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         []*PeerState           `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeersResponse) Reset() {
+	*x = PeersResponse{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeersResponse) ProtoMessage() {}
+
+func (x *PeersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeersResponse.ProtoReflect.Descriptor instead.
+func (*PeersResponse) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *PeersResponse) GetPeers() []*PeerState {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type PeerState struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Hostname string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// Zero until the peer has told us its id.
+	NodeId uint64     `protobuf:"varint,2,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Status PeerStatus `protobuf:"varint,3,opt,name=status,proto3,enum=clocks.PeerStatus" json:"status,omitempty"`
+	// Consecutive failed calls to the peer.
+	Failures  uint32 `protobuf:"varint,4,opt,name=failures,proto3" json:"failures,omitempty"`
+	LastError string `protobuf:"bytes,5,opt,name=lastError,proto3" json:"lastError,omitempty"`
+	// Milliseconds since the epoch.
+	RetryAt       uint64 `protobuf:"varint,6,opt,name=retryAt,proto3" json:"retryAt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerState) Reset() {
+	*x = PeerState{}
+	mi := &file_clocks_v1_clocks_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerState) ProtoMessage() {}
+
+func (x *PeerState) ProtoReflect() protoreflect.Message {
+	mi := &file_clocks_v1_clocks_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerState.ProtoReflect.Descriptor instead.
+func (*PeerState) Descriptor() ([]byte, []int) {
+	return file_clocks_v1_clocks_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *PeerState) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *PeerState) GetNodeId() uint64 {
 	if x != nil {
 		return x.NodeId
 	}
 	return 0
 }
 
-func (x *Chunk) GetVersion() uint64 {
+func (x *PeerState) GetStatus() PeerStatus {
 	if x != nil {
-		return x.Version
+		return x.Status
+	}
+	return PeerStatus_CONNECTING
+}
+
+func (x *PeerState) GetFailures() uint32 {
+	if x != nil {
+		return x.Failures
 	}
 	return 0
 }
 
-func (x *Chunk) GetWriteTimeUnixMillis() uint64 {
+func (x *PeerState) GetLastError() string {
 	if x != nil {
-		return x.WriteTimeUnixMillis
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *PeerState) GetRetryAt() uint64 {
+	if x != nil {
+		return x.RetryAt
 	}
 	return 0
 }
@@ -321,31 +1562,128 @@ var File_clocks_v1_clocks_proto protoreflect.FileDescriptor
 
 const file_clocks_v1_clocks_proto_rawDesc = "" +
 	"\n" +
-	"\x16clocks/v1/clocks.proto\x12\x06clocks\"t\n" +
+	"\x16clocks/v1/clocks.proto\x12\x06clocks\"&\n" +
+	"\fHelloRequest\x12\x16\n" +
+	"\x06nodeId\x18\x01 \x01(\x04R\x06nodeId\"=\n" +
+	"\rHelloResponse\x12\x16\n" +
+	"\x06nodeId\x18\x01 \x01(\x04R\x06nodeId\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x04R\x05epoch\"\xef\x01\n" +
+	"\vSyncMessage\x12\x16\n" +
+	"\x06nodeId\x18\x01 \x01(\x04R\x06nodeId\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x04R\x05epoch\x120\n" +
+	"\arecords\x18\x03 \x03(\v2\x16.clocks.PublishRequestR\arecords\x12$\n" +
+	"\x04acks\x18\x04 \x03(\v2\x10.clocks.KeyClockR\x04acks\x12\x1e\n" +
+	"\n" +
+	"checkpoint\x18\x05 \x01(\x04R\n" +
+	"checkpoint\x12\"\n" +
+	"\facknowledged\x18\x06 \x01(\x04R\facknowledged\x12\x16\n" +
+	"\x06leaves\x18\a \x03(\rR\x06leaves\"t\n" +
 	"\x0ePublishRequest\x12)\n" +
 	"\x05clock\x18\x01 \x01(\v2\x13.clocks.VectorClockR\x05clock\x12\x10\n" +
 	"\x03key\x18\x02 \x01(\tR\x03key\x12%\n" +
 	"\x06chunks\x18\x03 \x03(\v2\r.clocks.ChunkR\x06chunks\"\x11\n" +
-	"\x0fPublishResponse\"\f\n" +
+	"\x0fPublishResponse\".\n" +
 	"\n" +
-	"AckRequest\"J\n" +
+	"AckRequest\x12 \n" +
+	"\vresumeToken\x18\x01 \x01(\fR\vresumeToken\"l\n" +
 	"\vAckResponse\x12)\n" +
 	"\x05clock\x18\x01 \x01(\v2\x13.clocks.VectorClockR\x05clock\x12\x10\n" +
-	"\x03key\x18\x02 \x01(\tR\x03key\"}\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12 \n" +
+	"\vresumeToken\x18\x03 \x01(\fR\vresumeToken\"E\n" +
+	"\vAckPosition\x12\x1a\n" +
+	"\binstance\x18\x01 \x01(\x04R\binstance\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\" \n" +
+	"\fFetchRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"w\n" +
+	"\rFetchResponse\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12)\n" +
+	"\x05clock\x18\x02 \x01(\v2\x13.clocks.VectorClockR\x05clock\x12%\n" +
+	"\x06chunks\x18\x03 \x03(\v2\r.clocks.ChunkR\x06chunks\">\n" +
+	"\x0eDigestsRequest\x12\x14\n" +
+	"\x05nodes\x18\x01 \x03(\rR\x05nodes\x12\x16\n" +
+	"\x06leaves\x18\x02 \x03(\rR\x06leaves\"U\n" +
+	"\x0fDigestsResponse\x12\x18\n" +
+	"\adigests\x18\x01 \x03(\x04R\adigests\x12(\n" +
+	"\x06clocks\x18\x02 \x03(\v2\x10.clocks.KeyClockR\x06clocks\"G\n" +
+	"\bKeyClock\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12)\n" +
+	"\x05clock\x18\x02 \x01(\v2\x13.clocks.VectorClockR\x05clock\"\xad\x01\n" +
 	"\vVectorClock\x124\n" +
-	"\x05clock\x18\x01 \x03(\v2\x1e.clocks.VectorClock.ClockEntryR\x05clock\x1a8\n" +
+	"\x05clock\x18\x01 \x03(\v2\x1e.clocks.VectorClock.ClockEntryR\x05clock\x12.\n" +
+	"\aentries\x18\x02 \x03(\v2\x14.clocks.VersionEntryR\aentries\x1a8\n" +
 	"\n" +
 	"ClockEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x04R\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\x7f\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"V\n" +
+	"\fVersionEntry\x12\x16\n" +
+	"\x06nodeId\x18\x01 \x01(\x04R\x06nodeId\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x04R\x05epoch\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x04R\aversion\"\xdf\x01\n" +
 	"\x05Chunk\x12\x12\n" +
 	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
 	"\x06nodeId\x18\x02 \x01(\x04R\x06nodeId\x12\x18\n" +
 	"\aversion\x18\x03 \x01(\x04R\aversion\x120\n" +
-	"\x13writeTimeUnixMillis\x18\x04 \x01(\x04R\x13writeTimeUnixMillis2|\n" +
-	"\x06clocks\x12>\n" +
+	"\x13writeTimeUnixMillis\x18\x04 \x01(\x04R\x13writeTimeUnixMillis\x12\x1c\n" +
+	"\ttombstone\x18\x05 \x01(\bR\ttombstone\x12\x14\n" +
+	"\x05epoch\x18\x06 \x01(\x04R\x05epoch\x12*\n" +
+	"\x10writeTimeLogical\x18\a \x01(\rR\x10writeTimeLogical\":\n" +
+	"\x06Ballot\x12\x18\n" +
+	"\acounter\x18\x01 \x01(\x04R\acounter\x12\x16\n" +
+	"\x06nodeId\x18\x02 \x01(\x04R\x06nodeId\"\x8c\x01\n" +
+	"\bProposal\x12&\n" +
+	"\x06ballot\x18\x01 \x01(\v2\x0e.clocks.BallotR\x06ballot\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value\x12&\n" +
+	"\x06origin\x18\x03 \x01(\v2\x0e.clocks.BallotR\x06origin\x12\x1a\n" +
+	"\bsequence\x18\x04 \x01(\x04R\bsequence\"\xa0\x01\n" +
+	"\fPaxosRequest\x12(\n" +
+	"\x05phase\x18\x01 \x01(\x0e2\x12.clocks.PaxosPhaseR\x05phase\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12&\n" +
+	"\x06ballot\x18\x03 \x01(\v2\x0e.clocks.BallotR\x06ballot\x12,\n" +
+	"\bproposal\x18\x04 \x01(\v2\x10.clocks.ProposalR\bproposal\"\xa9\x01\n" +
+	"\rPaxosResponse\x12\x0e\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12*\n" +
+	"\bpromised\x18\x02 \x01(\v2\x0e.clocks.BallotR\bpromised\x12,\n" +
+	"\baccepted\x18\x03 \x01(\v2\x10.clocks.ProposalR\baccepted\x12.\n" +
+	"\tcommitted\x18\x04 \x01(\v2\x10.clocks.ProposalR\tcommitted\"\xa8\x01\n" +
+	"\n" +
+	"PaxosState\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\bpromised\x18\x02 \x01(\v2\x0e.clocks.BallotR\bpromised\x12,\n" +
+	"\baccepted\x18\x03 \x01(\v2\x10.clocks.ProposalR\baccepted\x12.\n" +
+	"\tcommitted\x18\x04 \x01(\v2\x10.clocks.ProposalR\tcommitted\"\x0e\n" +
+	"\fPeersRequest\"8\n" +
+	"\rPeersResponse\x12'\n" +
+	"\x05peers\x18\x01 \x03(\v2\x11.clocks.PeerStateR\x05peers\"\xbf\x01\n" +
+	"\tPeerState\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x16\n" +
+	"\x06nodeId\x18\x02 \x01(\x04R\x06nodeId\x12*\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x12.clocks.PeerStatusR\x06status\x12\x1a\n" +
+	"\bfailures\x18\x04 \x01(\rR\bfailures\x12\x1c\n" +
+	"\tlastError\x18\x05 \x01(\tR\tlastError\x12\x18\n" +
+	"\aretryAt\x18\x06 \x01(\x04R\aretryAt*1\n" +
+	"\n" +
+	"PaxosPhase\x12\v\n" +
+	"\aPREPARE\x10\x00\x12\n" +
+	"\n" +
+	"\x06ACCEPT\x10\x01\x12\n" +
+	"\n" +
+	"\x06COMMIT\x10\x02*L\n" +
+	"\n" +
+	"PeerStatus\x12\x0e\n" +
+	"\n" +
+	"CONNECTING\x10\x00\x12\v\n" +
+	"\aSYNCING\x10\x01\x12\x0f\n" +
+	"\vBACKING_OFF\x10\x02\x12\x10\n" +
+	"\fCIRCUIT_OPEN\x10\x032\xd2\x03\n" +
+	"\x06clocks\x126\n" +
+	"\x05Hello\x12\x14.clocks.HelloRequest\x1a\x15.clocks.HelloResponse\"\x00\x126\n" +
+	"\x04Sync\x12\x13.clocks.SyncMessage\x1a\x13.clocks.SyncMessage\"\x00(\x010\x01\x12>\n" +
 	"\aPublish\x12\x16.clocks.PublishRequest\x1a\x17.clocks.PublishResponse\"\x00(\x01\x122\n" +
-	"\x03Ack\x12\x12.clocks.AckRequest\x1a\x13.clocks.AckResponse\"\x000\x01B:Z8github.com/WadeCappa/consensus/gen/go/clocks/v1;clockspbb\x06proto3"
+	"\x03Ack\x12\x12.clocks.AckRequest\x1a\x13.clocks.AckResponse\"\x000\x01\x126\n" +
+	"\x05Fetch\x12\x14.clocks.FetchRequest\x1a\x15.clocks.FetchResponse\"\x00\x12<\n" +
+	"\aDigests\x12\x16.clocks.DigestsRequest\x1a\x17.clocks.DigestsResponse\"\x00\x126\n" +
+	"\x05Paxos\x12\x14.clocks.PaxosRequest\x1a\x15.clocks.PaxosResponse\"\x00\x126\n" +
+	"\x05Peers\x12\x14.clocks.PeersRequest\x1a\x15.clocks.PeersResponse\"\x00B:Z8github.com/WadeCappa/consensus/gen/go/clocks/v1;clockspbb\x06proto3"
 
 var (
 	file_clocks_v1_clocks_proto_rawDescOnce sync.Once
@@ -359,30 +1697,83 @@ func file_clocks_v1_clocks_proto_rawDescGZIP() []byte {
 	return file_clocks_v1_clocks_proto_rawDescData
 }
 
-var file_clocks_v1_clocks_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_clocks_v1_clocks_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_clocks_v1_clocks_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_clocks_v1_clocks_proto_goTypes = []any{
-	(*PublishRequest)(nil),  // 0: clocks.PublishRequest
-	(*PublishResponse)(nil), // 1: clocks.PublishResponse
-	(*AckRequest)(nil),      // 2: clocks.AckRequest
-	(*AckResponse)(nil),     // 3: clocks.AckResponse
-	(*VectorClock)(nil),     // 4: clocks.VectorClock
-	(*Chunk)(nil),           // 5: clocks.Chunk
-	nil,                     // 6: clocks.VectorClock.ClockEntry
+	(PaxosPhase)(0),         // 0: clocks.PaxosPhase
+	(PeerStatus)(0),         // 1: clocks.PeerStatus
+	(*HelloRequest)(nil),    // 2: clocks.HelloRequest
+	(*HelloResponse)(nil),   // 3: clocks.HelloResponse
+	(*SyncMessage)(nil),     // 4: clocks.SyncMessage
+	(*PublishRequest)(nil),  // 5: clocks.PublishRequest
+	(*PublishResponse)(nil), // 6: clocks.PublishResponse
+	(*AckRequest)(nil),      // 7: clocks.AckRequest
+	(*AckResponse)(nil),     // 8: clocks.AckResponse
+	(*AckPosition)(nil),     // 9: clocks.AckPosition
+	(*FetchRequest)(nil),    // 10: clocks.FetchRequest
+	(*FetchResponse)(nil),   // 11: clocks.FetchResponse
+	(*DigestsRequest)(nil),  // 12: clocks.DigestsRequest
+	(*DigestsResponse)(nil), // 13: clocks.DigestsResponse
+	(*KeyClock)(nil),        // 14: clocks.KeyClock
+	(*VectorClock)(nil),     // 15: clocks.VectorClock
+	(*VersionEntry)(nil),    // 16: clocks.VersionEntry
+	(*Chunk)(nil),           // 17: clocks.Chunk
+	(*Ballot)(nil),          // 18: clocks.Ballot
+	(*Proposal)(nil),        // 19: clocks.Proposal
+	(*PaxosRequest)(nil),    // 20: clocks.PaxosRequest
+	(*PaxosResponse)(nil),   // 21: clocks.PaxosResponse
+	(*PaxosState)(nil),      // 22: clocks.PaxosState
+	(*PeersRequest)(nil),    // 23: clocks.PeersRequest
+	(*PeersResponse)(nil),   // 24: clocks.PeersResponse
+	(*PeerState)(nil),       // 25: clocks.PeerState
+	nil,                     // 26: clocks.VectorClock.ClockEntry
 }
 var file_clocks_v1_clocks_proto_depIdxs = []int32{
-	4, // 0: clocks.PublishRequest.clock:type_name -> clocks.VectorClock
-	5, // 1: clocks.PublishRequest.chunks:type_name -> clocks.Chunk
-	4, // 2: clocks.AckResponse.clock:type_name -> clocks.VectorClock
-	6, // 3: clocks.VectorClock.clock:type_name -> clocks.VectorClock.ClockEntry
-	0, // 4: clocks.clocks.Publish:input_type -> clocks.PublishRequest
-	2, // 5: clocks.clocks.Ack:input_type -> clocks.AckRequest
-	1, // 6: clocks.clocks.Publish:output_type -> clocks.PublishResponse
-	3, // 7: clocks.clocks.Ack:output_type -> clocks.AckResponse
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	5,  // 0: clocks.SyncMessage.records:type_name -> clocks.PublishRequest
+	14, // 1: clocks.SyncMessage.acks:type_name -> clocks.KeyClock
+	15, // 2: clocks.PublishRequest.clock:type_name -> clocks.VectorClock
+	17, // 3: clocks.PublishRequest.chunks:type_name -> clocks.Chunk
+	15, // 4: clocks.AckResponse.clock:type_name -> clocks.VectorClock
+	15, // 5: clocks.FetchResponse.clock:type_name -> clocks.VectorClock
+	17, // 6: clocks.FetchResponse.chunks:type_name -> clocks.Chunk
+	14, // 7: clocks.DigestsResponse.clocks:type_name -> clocks.KeyClock
+	15, // 8: clocks.KeyClock.clock:type_name -> clocks.VectorClock
+	26, // 9: clocks.VectorClock.clock:type_name -> clocks.VectorClock.ClockEntry
+	16, // 10: clocks.VectorClock.entries:type_name -> clocks.VersionEntry
+	18, // 11: clocks.Proposal.ballot:type_name -> clocks.Ballot
+	18, // 12: clocks.Proposal.origin:type_name -> clocks.Ballot
+	0,  // 13: clocks.PaxosRequest.phase:type_name -> clocks.PaxosPhase
+	18, // 14: clocks.PaxosRequest.ballot:type_name -> clocks.Ballot
+	19, // 15: clocks.PaxosRequest.proposal:type_name -> clocks.Proposal
+	18, // 16: clocks.PaxosResponse.promised:type_name -> clocks.Ballot
+	19, // 17: clocks.PaxosResponse.accepted:type_name -> clocks.Proposal
+	19, // 18: clocks.PaxosResponse.committed:type_name -> clocks.Proposal
+	18, // 19: clocks.PaxosState.promised:type_name -> clocks.Ballot
+	19, // 20: clocks.PaxosState.accepted:type_name -> clocks.Proposal
+	19, // 21: clocks.PaxosState.committed:type_name -> clocks.Proposal
+	25, // 22: clocks.PeersResponse.peers:type_name -> clocks.PeerState
+	1,  // 23: clocks.PeerState.status:type_name -> clocks.PeerStatus
+	2,  // 24: clocks.clocks.Hello:input_type -> clocks.HelloRequest
+	4,  // 25: clocks.clocks.Sync:input_type -> clocks.SyncMessage
+	5,  // 26: clocks.clocks.Publish:input_type -> clocks.PublishRequest
+	7,  // 27: clocks.clocks.Ack:input_type -> clocks.AckRequest
+	10, // 28: clocks.clocks.Fetch:input_type -> clocks.FetchRequest
+	12, // 29: clocks.clocks.Digests:input_type -> clocks.DigestsRequest
+	20, // 30: clocks.clocks.Paxos:input_type -> clocks.PaxosRequest
+	23, // 31: clocks.clocks.Peers:input_type -> clocks.PeersRequest
+	3,  // 32: clocks.clocks.Hello:output_type -> clocks.HelloResponse
+	4,  // 33: clocks.clocks.Sync:output_type -> clocks.SyncMessage
+	6,  // 34: clocks.clocks.Publish:output_type -> clocks.PublishResponse
+	8,  // 35: clocks.clocks.Ack:output_type -> clocks.AckResponse
+	11, // 36: clocks.clocks.Fetch:output_type -> clocks.FetchResponse
+	13, // 37: clocks.clocks.Digests:output_type -> clocks.DigestsResponse
+	21, // 38: clocks.clocks.Paxos:output_type -> clocks.PaxosResponse
+	24, // 39: clocks.clocks.Peers:output_type -> clocks.PeersResponse
+	32, // [32:40] is the sub-list for method output_type
+	24, // [24:32] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_clocks_v1_clocks_proto_init() }
@@ -395,13 +1786,14 @@ func file_clocks_v1_clocks_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_clocks_v1_clocks_proto_rawDesc), len(file_clocks_v1_clocks_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   7,
+			NumEnums:      2,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_clocks_v1_clocks_proto_goTypes,
 		DependencyIndexes: file_clocks_v1_clocks_proto_depIdxs,
+		EnumInfos:         file_clocks_v1_clocks_proto_enumTypes,
 		MessageInfos:      file_clocks_v1_clocks_proto_msgTypes,
 	}.Build()
 	File_clocks_v1_clocks_proto = out.File
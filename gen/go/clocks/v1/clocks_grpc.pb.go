@@ -19,16 +19,42 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
+	Clocks_Hello_FullMethodName   = "/clocks.clocks/Hello"
+	Clocks_Sync_FullMethodName    = "/clocks.clocks/Sync"
 	Clocks_Publish_FullMethodName = "/clocks.clocks/Publish"
 	Clocks_Ack_FullMethodName     = "/clocks.clocks/Ack"
+	Clocks_Fetch_FullMethodName   = "/clocks.clocks/Fetch"
+	Clocks_Digests_FullMethodName = "/clocks.clocks/Digests"
+	Clocks_Paxos_FullMethodName   = "/clocks.clocks/Paxos"
+	Clocks_Peers_FullMethodName   = "/clocks.clocks/Peers"
 )
 
 // ClocksClient is the client API for Clocks service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ClocksClient interface {
+	// Hello exchanges node IDs. Clients call it before streaming so that they know which node
+	// they are replicating to.
+	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// Sync replicates in both directions over a single stream, and is how peers normally keep
+	// each other up to date. Both ends publish the keys that change on their side, merge what
+	// the other end publishes and acknowledge it on the same stream.
+	Sync(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SyncMessage, SyncMessage], error)
 	Publish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishResponse], error)
+	// Ack streams the clock of every key the server holds, then keeps the stream open and sends
+	// the clock of each key again whenever it changes.
 	Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AckResponse], error)
+	Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (*FetchResponse, error)
+	// Digests returns digests from the Merkle tree the server keeps over the clock of every
+	// key. Clients compare them with their own tree from the root down to find the leaves the
+	// two nodes disagree on, then ask for the clocks of the keys under just those leaves.
+	Digests(ctx context.Context, in *DigestsRequest, opts ...grpc.CallOption) (*DigestsResponse, error)
+	// Paxos runs one phase of a single-decree Paxos round on the receiving node, which acts as
+	// an acceptor for the compare-and-set register at the request's key.
+	Paxos(ctx context.Context, in *PaxosRequest, opts ...grpc.CallOption) (*PaxosResponse, error)
+	// Peers reports how the server's replication with each of its peers is doing, for
+	// diagnostics.
+	Peers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeersResponse, error)
 }
 
 type clocksClient struct {
@@ -39,9 +65,32 @@ func NewClocksClient(cc grpc.ClientConnInterface) ClocksClient {
 	return &clocksClient{cc}
 }
 
+func (c *clocksClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HelloResponse)
+	err := c.cc.Invoke(ctx, Clocks_Hello_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clocksClient) Sync(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SyncMessage, SyncMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Clocks_ServiceDesc.Streams[0], Clocks_Sync_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SyncMessage, SyncMessage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Clocks_SyncClient = grpc.BidiStreamingClient[SyncMessage, SyncMessage]
+
 func (c *clocksClient) Publish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Clocks_ServiceDesc.Streams[0], Clocks_Publish_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Clocks_ServiceDesc.Streams[1], Clocks_Publish_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +103,7 @@ type Clocks_PublishClient = grpc.ClientStreamingClient[PublishRequest, PublishRe
 
 func (c *clocksClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AckResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Clocks_ServiceDesc.Streams[1], Clocks_Ack_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Clocks_ServiceDesc.Streams[2], Clocks_Ack_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -71,12 +120,72 @@ func (c *clocksClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.Cal
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Clocks_AckClient = grpc.ServerStreamingClient[AckResponse]
 
+func (c *clocksClient) Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (*FetchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FetchResponse)
+	err := c.cc.Invoke(ctx, Clocks_Fetch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clocksClient) Digests(ctx context.Context, in *DigestsRequest, opts ...grpc.CallOption) (*DigestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DigestsResponse)
+	err := c.cc.Invoke(ctx, Clocks_Digests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clocksClient) Paxos(ctx context.Context, in *PaxosRequest, opts ...grpc.CallOption) (*PaxosResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaxosResponse)
+	err := c.cc.Invoke(ctx, Clocks_Paxos_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clocksClient) Peers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PeersResponse)
+	err := c.cc.Invoke(ctx, Clocks_Peers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ClocksServer is the server API for Clocks service.
 // All implementations must embed UnimplementedClocksServer
 // for forward compatibility.
 type ClocksServer interface {
+	// Hello exchanges node IDs. Clients call it before streaming so that they know which node
+	// they are replicating to.
+	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
+	// Sync replicates in both directions over a single stream, and is how peers normally keep
+	// each other up to date. Both ends publish the keys that change on their side, merge what
+	// the other end publishes and acknowledge it on the same stream.
+	Sync(grpc.BidiStreamingServer[SyncMessage, SyncMessage]) error
 	Publish(grpc.ClientStreamingServer[PublishRequest, PublishResponse]) error
+	// Ack streams the clock of every key the server holds, then keeps the stream open and sends
+	// the clock of each key again whenever it changes.
 	Ack(*AckRequest, grpc.ServerStreamingServer[AckResponse]) error
+	Fetch(context.Context, *FetchRequest) (*FetchResponse, error)
+	// Digests returns digests from the Merkle tree the server keeps over the clock of every
+	// key. Clients compare them with their own tree from the root down to find the leaves the
+	// two nodes disagree on, then ask for the clocks of the keys under just those leaves.
+	Digests(context.Context, *DigestsRequest) (*DigestsResponse, error)
+	// Paxos runs one phase of a single-decree Paxos round on the receiving node, which acts as
+	// an acceptor for the compare-and-set register at the request's key.
+	Paxos(context.Context, *PaxosRequest) (*PaxosResponse, error)
+	// Peers reports how the server's replication with each of its peers is doing, for
+	// diagnostics.
+	Peers(context.Context, *PeersRequest) (*PeersResponse, error)
 	mustEmbedUnimplementedClocksServer()
 }
 
@@ -87,12 +196,30 @@ type ClocksServer interface {
 // pointer dereference when methods are called.
 type UnimplementedClocksServer struct{}
 
+func (UnimplementedClocksServer) Hello(context.Context, *HelloRequest) (*HelloResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Hello not implemented")
+}
+func (UnimplementedClocksServer) Sync(grpc.BidiStreamingServer[SyncMessage, SyncMessage]) error {
+	return status.Error(codes.Unimplemented, "method Sync not implemented")
+}
 func (UnimplementedClocksServer) Publish(grpc.ClientStreamingServer[PublishRequest, PublishResponse]) error {
 	return status.Error(codes.Unimplemented, "method Publish not implemented")
 }
 func (UnimplementedClocksServer) Ack(*AckRequest, grpc.ServerStreamingServer[AckResponse]) error {
 	return status.Error(codes.Unimplemented, "method Ack not implemented")
 }
+func (UnimplementedClocksServer) Fetch(context.Context, *FetchRequest) (*FetchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Fetch not implemented")
+}
+func (UnimplementedClocksServer) Digests(context.Context, *DigestsRequest) (*DigestsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Digests not implemented")
+}
+func (UnimplementedClocksServer) Paxos(context.Context, *PaxosRequest) (*PaxosResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Paxos not implemented")
+}
+func (UnimplementedClocksServer) Peers(context.Context, *PeersRequest) (*PeersResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Peers not implemented")
+}
 func (UnimplementedClocksServer) mustEmbedUnimplementedClocksServer() {}
 func (UnimplementedClocksServer) testEmbeddedByValue()                {}
 
@@ -114,6 +241,31 @@ func RegisterClocksServer(s grpc.ServiceRegistrar, srv ClocksServer) {
 	s.RegisterService(&Clocks_ServiceDesc, srv)
 }
 
+func _Clocks_Hello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClocksServer).Hello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Clocks_Hello_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClocksServer).Hello(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clocks_Sync_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ClocksServer).Sync(&grpc.GenericServerStream[SyncMessage, SyncMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Clocks_SyncServer = grpc.BidiStreamingServer[SyncMessage, SyncMessage]
+
 func _Clocks_Publish_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(ClocksServer).Publish(&grpc.GenericServerStream[PublishRequest, PublishResponse]{ServerStream: stream})
 }
@@ -132,14 +284,113 @@ func _Clocks_Ack_Handler(srv interface{}, stream grpc.ServerStream) error {
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Clocks_AckServer = grpc.ServerStreamingServer[AckResponse]
 
+func _Clocks_Fetch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClocksServer).Fetch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Clocks_Fetch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClocksServer).Fetch(ctx, req.(*FetchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clocks_Digests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DigestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClocksServer).Digests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Clocks_Digests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClocksServer).Digests(ctx, req.(*DigestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clocks_Paxos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaxosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClocksServer).Paxos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Clocks_Paxos_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClocksServer).Paxos(ctx, req.(*PaxosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Clocks_Peers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClocksServer).Peers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Clocks_Peers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClocksServer).Peers(ctx, req.(*PeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Clocks_ServiceDesc is the grpc.ServiceDesc for Clocks service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var Clocks_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "clocks.clocks",
 	HandlerType: (*ClocksServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hello",
+			Handler:    _Clocks_Hello_Handler,
+		},
+		{
+			MethodName: "Fetch",
+			Handler:    _Clocks_Fetch_Handler,
+		},
+		{
+			MethodName: "Digests",
+			Handler:    _Clocks_Digests_Handler,
+		},
+		{
+			MethodName: "Paxos",
+			Handler:    _Clocks_Paxos_Handler,
+		},
+		{
+			MethodName: "Peers",
+			Handler:    _Clocks_Peers_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sync",
+			Handler:       _Clocks_Sync_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Publish",
 			Handler:       _Clocks_Publish_Handler,
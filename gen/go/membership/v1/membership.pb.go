@@ -0,0 +1,473 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v3.19.6
+// source: membership/v1/membership.proto
+
+package membershippb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type State int32
+
+const (
+	State_ALIVE   State = 0
+	State_SUSPECT State = 1
+	State_DEAD    State = 2
+	State_LEFT    State = 3
+)
+
+// Enum value maps for State.
+var (
+	State_name = map[int32]string{
+		0: "ALIVE",
+		1: "SUSPECT",
+		2: "DEAD",
+		3: "LEFT",
+	}
+	State_value = map[string]int32{
+		"ALIVE":   0,
+		"SUSPECT": 1,
+		"DEAD":    2,
+		"LEFT":    3,
+	}
+)
+
+func (x State) Enum() *State {
+	p := new(State)
+	*p = x
+	return p
+}
+
+func (x State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (State) Descriptor() protoreflect.EnumDescriptor {
+	return file_membership_v1_membership_proto_enumTypes[0].Descriptor()
+}
+
+func (State) Type() protoreflect.EnumType {
+	return &file_membership_v1_membership_proto_enumTypes[0]
+}
+
+func (x State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use State.Descriptor instead.
+func (State) EnumDescriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{0}
+}
+
+type Member struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Id      uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Address string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// Only the member itself increases its incarnation, which it does to refute suspicion.
+	Incarnation   uint64 `protobuf:"varint,3,opt,name=incarnation,proto3" json:"incarnation,omitempty"`
+	State         State  `protobuf:"varint,4,opt,name=state,proto3,enum=membership.State" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Member) Reset() {
+	*x = Member{}
+	mi := &file_membership_v1_membership_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Member) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Member) ProtoMessage() {}
+
+func (x *Member) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Member.ProtoReflect.Descriptor instead.
+func (*Member) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Member) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Member) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Member) GetIncarnation() uint64 {
+	if x != nil {
+		return x.Incarnation
+	}
+	return 0
+}
+
+func (x *Member) GetState() State {
+	if x != nil {
+		return x.State
+	}
+	return State_ALIVE
+}
+
+type JoinRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Member        *Member                `protobuf:"bytes,1,opt,name=member,proto3" json:"member,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JoinRequest) Reset() {
+	*x = JoinRequest{}
+	mi := &file_membership_v1_membership_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JoinRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinRequest) ProtoMessage() {}
+
+func (x *JoinRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinRequest.ProtoReflect.Descriptor instead.
+func (*JoinRequest) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *JoinRequest) GetMember() *Member {
+	if x != nil {
+		return x.Member
+	}
+	return nil
+}
+
+type JoinResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Members       []*Member              `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JoinResponse) Reset() {
+	*x = JoinResponse{}
+	mi := &file_membership_v1_membership_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JoinResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinResponse) ProtoMessage() {}
+
+func (x *JoinResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinResponse.ProtoReflect.Descriptor instead.
+func (*JoinResponse) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JoinResponse) GetMembers() []*Member {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+// Membership changes are gossiped by piggybacking them on pings and their responses.
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updates       []*Member              `protobuf:"bytes,1,rep,name=updates,proto3" json:"updates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_membership_v1_membership_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PingRequest) GetUpdates() []*Member {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updates       []*Member              `protobuf:"bytes,1,rep,name=updates,proto3" json:"updates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_membership_v1_membership_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PingResponse) GetUpdates() []*Member {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+type PingReqRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        string                 `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Updates       []*Member              `protobuf:"bytes,2,rep,name=updates,proto3" json:"updates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingReqRequest) Reset() {
+	*x = PingReqRequest{}
+	mi := &file_membership_v1_membership_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingReqRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingReqRequest) ProtoMessage() {}
+
+func (x *PingReqRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_membership_v1_membership_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingReqRequest.ProtoReflect.Descriptor instead.
+func (*PingReqRequest) Descriptor() ([]byte, []int) {
+	return file_membership_v1_membership_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PingReqRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *PingReqRequest) GetUpdates() []*Member {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+var File_membership_v1_membership_proto protoreflect.FileDescriptor
+
+const file_membership_v1_membership_proto_rawDesc = "" +
+	"\n" +
+	"\x1emembership/v1/membership.proto\x12\n" +
+	"membership\"}\n" +
+	"\x06Member\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12 \n" +
+	"\vincarnation\x18\x03 \x01(\x04R\vincarnation\x12'\n" +
+	"\x05state\x18\x04 \x01(\x0e2\x11.membership.StateR\x05state\"9\n" +
+	"\vJoinRequest\x12*\n" +
+	"\x06member\x18\x01 \x01(\v2\x12.membership.MemberR\x06member\"<\n" +
+	"\fJoinResponse\x12,\n" +
+	"\amembers\x18\x01 \x03(\v2\x12.membership.MemberR\amembers\";\n" +
+	"\vPingRequest\x12,\n" +
+	"\aupdates\x18\x01 \x03(\v2\x12.membership.MemberR\aupdates\"<\n" +
+	"\fPingResponse\x12,\n" +
+	"\aupdates\x18\x01 \x03(\v2\x12.membership.MemberR\aupdates\"V\n" +
+	"\x0ePingReqRequest\x12\x16\n" +
+	"\x06target\x18\x01 \x01(\tR\x06target\x12,\n" +
+	"\aupdates\x18\x02 \x03(\v2\x12.membership.MemberR\aupdates*3\n" +
+	"\x05State\x12\t\n" +
+	"\x05ALIVE\x10\x00\x12\v\n" +
+	"\aSUSPECT\x10\x01\x12\b\n" +
+	"\x04DEAD\x10\x02\x12\b\n" +
+	"\x04LEFT\x10\x032\xc9\x01\n" +
+	"\n" +
+	"membership\x12;\n" +
+	"\x04Join\x12\x17.membership.JoinRequest\x1a\x18.membership.JoinResponse\"\x00\x12;\n" +
+	"\x04Ping\x12\x17.membership.PingRequest\x1a\x18.membership.PingResponse\"\x00\x12A\n" +
+	"\aPingReq\x12\x1a.membership.PingReqRequest\x1a\x18.membership.PingResponse\"\x00BBZ@github.com/WadeCappa/consensus/gen/go/membership/v1;membershippbb\x06proto3"
+
+var (
+	file_membership_v1_membership_proto_rawDescOnce sync.Once
+	file_membership_v1_membership_proto_rawDescData []byte
+)
+
+func file_membership_v1_membership_proto_rawDescGZIP() []byte {
+	file_membership_v1_membership_proto_rawDescOnce.Do(func() {
+		file_membership_v1_membership_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_membership_v1_membership_proto_rawDesc), len(file_membership_v1_membership_proto_rawDesc)))
+	})
+	return file_membership_v1_membership_proto_rawDescData
+}
+
+var file_membership_v1_membership_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_membership_v1_membership_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_membership_v1_membership_proto_goTypes = []any{
+	(State)(0),             // 0: membership.State
+	(*Member)(nil),         // 1: membership.Member
+	(*JoinRequest)(nil),    // 2: membership.JoinRequest
+	(*JoinResponse)(nil),   // 3: membership.JoinResponse
+	(*PingRequest)(nil),    // 4: membership.PingRequest
+	(*PingResponse)(nil),   // 5: membership.PingResponse
+	(*PingReqRequest)(nil), // 6: membership.PingReqRequest
+}
+var file_membership_v1_membership_proto_depIdxs = []int32{
+	0, // 0: membership.Member.state:type_name -> membership.State
+	1, // 1: membership.JoinRequest.member:type_name -> membership.Member
+	1, // 2: membership.JoinResponse.members:type_name -> membership.Member
+	1, // 3: membership.PingRequest.updates:type_name -> membership.Member
+	1, // 4: membership.PingResponse.updates:type_name -> membership.Member
+	1, // 5: membership.PingReqRequest.updates:type_name -> membership.Member
+	2, // 6: membership.membership.Join:input_type -> membership.JoinRequest
+	4, // 7: membership.membership.Ping:input_type -> membership.PingRequest
+	6, // 8: membership.membership.PingReq:input_type -> membership.PingReqRequest
+	3, // 9: membership.membership.Join:output_type -> membership.JoinResponse
+	5, // 10: membership.membership.Ping:output_type -> membership.PingResponse
+	5, // 11: membership.membership.PingReq:output_type -> membership.PingResponse
+	9, // [9:12] is the sub-list for method output_type
+	6, // [6:9] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_membership_v1_membership_proto_init() }
+func file_membership_v1_membership_proto_init() {
+	if File_membership_v1_membership_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_membership_v1_membership_proto_rawDesc), len(file_membership_v1_membership_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_membership_v1_membership_proto_goTypes,
+		DependencyIndexes: file_membership_v1_membership_proto_depIdxs,
+		EnumInfos:         file_membership_v1_membership_proto_enumTypes,
+		MessageInfos:      file_membership_v1_membership_proto_msgTypes,
+	}.Build()
+	File_membership_v1_membership_proto = out.File
+	file_membership_v1_membership_proto_goTypes = nil
+	file_membership_v1_membership_proto_depIdxs = nil
+}
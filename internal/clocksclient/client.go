@@ -2,178 +2,394 @@ package clocksclient
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"io"
-	"strconv"
-	"strings"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
 	"github.com/WadeCappa/consensus/internal/db"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Every this many retry delays, peers that are syncing also compare Merkle trees to repair
+// whatever publishing missed, such as keys a peer lost when it restarted.
+const reconcileEvery = 10
+
 type ClockClient struct {
-	data         *db.Database
+	data  db.Storage
+	local db.Incarnation
+	// How many nodes make up the cluster, this one included, however many are reachable.
+	clusterSize  int
 	secure       bool
 	remoteClocks *db.RemoteClocks
 	delay        time.Duration
+	logger       *slog.Logger
+
+	peersLock sync.Mutex
+	peers     map[uint64]string
+	// The epoch of every node we have synced with, removed ones included. These are the
+	// members that must acknowledge a tombstone before it is collected.
+	epochs   map[uint64]uint64
+	hosts    map[string]*peer
+	sessions map[uint64]*session
+	// The latest database change each peer has acknowledged.
+	positions map[uint64]uint64
 }
 
-func NewClocksClient(data *db.Database, secure bool, delay time.Duration) *ClockClient {
+func NewClocksClient(data db.Storage, local db.Incarnation, clusterSize int, secure bool, delay time.Duration) *ClockClient {
 	return &ClockClient{
 		data:         data,
+		local:        local,
+		clusterSize:  clusterSize,
 		secure:       secure,
 		remoteClocks: db.NewRemoteClocks(),
 		delay:        delay,
+		logger:       slog.With("component", "replication"),
+		peers:        map[uint64]string{},
+		epochs:       map[uint64]uint64{},
+		hosts:        map[string]*peer{},
+		sessions:     map[uint64]*session{},
+		positions:    map[uint64]uint64{},
 	}
 }
 
-func (s *ClockClient) SendDataWithRetry(ctx context.Context, hostname string) {
-	remoteSystemId := getRemoteSystemId(hostname)
-	backoffTicker := time.NewTicker(s.delay)
-	defer backoffTicker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-backoffTicker.C:
-		}
-		fmt.Printf("starting send stream for server %s\n", hostname)
-		if err := withConnection(hostname, s.secure, func(client clockspb.ClocksClient) error {
-			return s.sendData(ctx, client, remoteSystemId)
-		}); err != nil {
-			fmt.Printf("Failed to stream data: %s\n", err.Error())
+// AddPeer starts syncing with the server at hostname, unless we already are.
+func (s *ClockClient) AddPeer(hostname string) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	if _, exists := s.hosts[hostname]; exists {
+		return
+	}
+	p, err := newPeer(hostname, s.secure)
+	if err != nil {
+		s.logger.Error("failed to add peer", "peer", hostname, "error", err)
+		return
+	}
+	s.logger.Info("adding peer", "peer", hostname)
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	s.hosts[hostname] = p
+	go s.syncWithRetry(ctx, p)
+}
+
+// RemovePeer stops replicating to the server at hostname. It remains a member of the
+// cluster, so tombstones are still kept until it acknowledges them.
+func (s *ClockClient) RemovePeer(hostname string) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	p, exists := s.hosts[hostname]
+	if !exists {
+		return
+	}
+	s.logger.Info("removing peer", "peer", hostname)
+	p.cancel()
+	p.conn.Close()
+	delete(s.hosts, hostname)
+	for id, peer := range s.peers {
+		if peer == hostname {
+			s.forget(id)
 		}
 	}
 }
 
-func (s *ClockClient) RunAcksWithRetry(ctx context.Context, hostname string) {
-	remoteSystemId := getRemoteSystemId(hostname)
-	backoffTicker := time.NewTicker(s.delay)
-	defer backoffTicker.Stop()
+// forget drops everything we know about the remote system. The caller must hold peersLock.
+func (s *ClockClient) forget(remoteSystemId uint64) {
+	delete(s.peers, remoteSystemId)
+	delete(s.positions, remoteSystemId)
+	if sess, exists := s.sessions[remoteSystemId]; exists {
+		sess.cancel()
+		delete(s.sessions, remoteSystemId)
+	}
+}
+
+// syncWithRetry keeps a sync session with p running until ctx is done. A session that
+// cannot be started, or that breaks, counts as one failure and is retried after backing off.
+func (s *ClockClient) syncWithRetry(ctx context.Context, p *peer) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-backoffTicker.C:
+		case <-time.After(s.untilRetry(p)):
+		}
+		err := s.syncOnce(ctx, p)
+		if ctx.Err() != nil {
+			return
 		}
-		fmt.Printf("starting ack stream for server %s\n", hostname)
-		if err := withConnection(hostname, s.secure, func(client clockspb.ClocksClient) error {
-			return s.getAcks(ctx, client, remoteSystemId)
-		}); err != nil {
-			fmt.Printf("Failed to stream acks: %s\n", err.Error())
+		if err != nil {
+			s.logger.Warn("failed to sync", "peer", p.hostname, "error", err)
+			s.failed(p, err)
 		}
 	}
 }
 
-func (s *ClockClient) getAcks(
-	ctx context.Context,
-	client clockspb.ClocksClient,
-	remoteSystemId uint64,
-) error {
+// syncOnce syncs with p until the session breaks. It returns nil right away if p already
+// runs a session with us that it dialed.
+func (s *ClockClient) syncOnce(ctx context.Context, p *peer) error {
+	s.setStatus(p, clockspb.PeerStatus_CONNECTING)
+	remoteSystemId, err := s.hello(ctx, p.client, p.hostname)
+	if err != nil {
+		return err
+	}
+	s.succeeded(p)
+	s.setStatus(p, clockspb.PeerStatus_SYNCING)
+	if s.hasSession(remoteSystemId) {
+		s.peersLock.Lock()
+		p.retryAt = time.Now().Add(s.delay)
+		s.peersLock.Unlock()
+		return nil
+	}
+	s.logger.Info("starting sync", "peer", p.hostname, "node", remoteSystemId)
+	return s.dialSession(ctx, p.client, remoteSystemId)
+}
+
+// dialSession syncs with the remote system over a stream we open, and periodically compares
+// Merkle trees with it over the same connection.
+func (s *ClockClient) dialSession(ctx context.Context, client clockspb.ClocksClient, remoteSystemId uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("opening sync stream: %w", err)
+	}
+	if err := stream.Send(&clockspb.SyncMessage{
+		NodeId: s.local.NodeId,
+		Epoch:  s.local.Epoch,
+	}); err != nil {
+		return fmt.Errorf("introducing ourselves: %w", err)
+	}
+	sess, ctx, err := s.openSession(ctx, remoteSystemId, stream, true)
+	if err != nil {
+		return err
+	}
+	defer s.closeSession(sess)
+	go s.reconcilePeriodically(ctx, client, sess)
+	return sess.run(ctx)
+}
+
+// ServeSync syncs with a peer over a stream it dialed. The peer introduces itself in its
+// first message.
+func (s *ClockClient) ServeSync(stream grpc.BidiStreamingServer[clockspb.SyncMessage, clockspb.SyncMessage]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("receiving introduction: %w", err)
+	}
+	remoteSystemId := first.GetNodeId()
+	if remoteSystemId == 0 || remoteSystemId == s.local.NodeId {
+		return status.Errorf(codes.InvalidArgument, "cannot sync with node id %d", remoteSystemId)
+	}
+	s.noteEpoch(remoteSystemId, first.GetEpoch(), fmt.Sprintf("node %d", remoteSystemId))
+	sess, ctx, err := s.openSession(stream.Context(), remoteSystemId, stream, false)
+	if err != nil {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	defer s.closeSession(sess)
+	return sess.run(ctx)
+}
+
+// hello learns the node id of the server at hostname and registers it as a peer. It fails
+// if the server shares our id or if another peer already goes by it.
+func (s *ClockClient) hello(ctx context.Context, client clockspb.ClocksClient, hostname string) (uint64, error) {
+	response, err := client.Hello(ctx, &clockspb.HelloRequest{
+		NodeId: s.local.NodeId,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("exchanging node ids: %w", err)
+	}
+	remoteSystemId := response.GetNodeId()
+	if remoteSystemId == s.local.NodeId {
+		return 0, fmt.Errorf("server %s has the same node id %d as this node", hostname, remoteSystemId)
+	}
+	if err := s.addPeer(remoteSystemId, response.GetEpoch(), hostname); err != nil {
+		return 0, err
+	}
+	return remoteSystemId, nil
+}
+
+// CollectTombstones periodically drops tombstones that every member of the cluster has
+// acknowledged, and folds away the clock entries of epochs they have all seen replaced.
+// Nothing is collected until we have synced with enough members to make up the cluster,
+// since those we have not heard from yet may not have seen the deletes.
+func (s *ClockClient) CollectTombstones(ctx context.Context) {
 	ticker := time.NewTicker(s.delay)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("acks context closed: %w", ctx.Err())
+			return
 		case <-ticker.C:
 		}
+		members := s.members()
+		if len(members) == 0 || len(members)+1 < s.clusterSize {
+			continue
+		}
+		acked := func(key string, record *db.Record) bool {
+			return s.remoteClocks.AckedByAll(members, key, record.Clock)
+		}
+		if err := s.data.CollectTombstones(acked); err != nil {
+			s.logger.Error("failed to collect tombstones", "error", err)
+		}
+		if err := s.data.FoldEpochs(acked); err != nil {
+			s.logger.Error("failed to fold replaced epochs", "error", err)
+		}
+	}
+}
 
-		stream, err := client.Ack(ctx, &clockspb.AckRequest{})
-		if err != nil {
-			return fmt.Errorf("opening acks stream: %w", err)
+func (s *ClockClient) addPeer(remoteSystemId, epoch uint64, hostname string) error {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	if existing, exists := s.peers[remoteSystemId]; exists && existing != hostname {
+		return fmt.Errorf("node id %d of server %s is already in use by %s", remoteSystemId, hostname, existing)
+	}
+	if _, running := s.hosts[hostname]; !running {
+		return fmt.Errorf("server %s was removed", hostname)
+	}
+	// The server may have come back with a new id, in which case the old one is no longer a
+	// member and its acks no longer count.
+	for id, peer := range s.peers {
+		if peer == hostname && id != remoteSystemId {
+			s.forget(id)
+			delete(s.epochs, id)
+			s.remoteClocks.Forget(id)
 		}
+	}
+	s.peers[remoteSystemId] = hostname
+	s.checkEpoch(remoteSystemId, epoch, hostname)
+	return nil
+}
 
-		for {
-			ack, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return fmt.Errorf("receiving ack: %w", err)
-			}
-			s.remoteClocks.Accept(remoteSystemId, ack.GetKey(), db.FromWireType(ack.GetClock()))
+func (s *ClockClient) noteEpoch(remoteSystemId, epoch uint64, name string) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	s.checkEpoch(remoteSystemId, epoch, name)
+}
+
+// checkEpoch forgets what the remote system had acknowledged if it restarted in a new epoch.
+// The caller must hold peersLock.
+func (s *ClockClient) checkEpoch(remoteSystemId, epoch uint64, name string) {
+	if previous, known := s.epochs[remoteSystemId]; known && previous != epoch {
+		s.logger.Info("peer restarted in a new epoch, resending everything",
+			"peer", name, "previous", previous, "epoch", epoch)
+		s.remoteClocks.Forget(remoteSystemId)
+		delete(s.positions, remoteSystemId)
+		if sess, exists := s.sessions[remoteSystemId]; exists {
+			sess.cancel()
+			delete(s.sessions, remoteSystemId)
 		}
 	}
+	s.epochs[remoteSystemId] = epoch
 }
 
-func (s *ClockClient) sendData(
-	ctx context.Context,
-	client clockspb.ClocksClient,
-	remoteSystemId uint64,
-) error {
-	ticker := time.NewTicker(s.delay)
+func (s *ClockClient) position(remoteSystemId uint64) uint64 {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	return s.positions[remoteSystemId]
+}
+
+// advance moves the position of the remote system forward to latest, unless sess has been
+// replaced or closed, in which case the position may have been reset.
+func (s *ClockClient) advance(sess *session, latest uint64) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	if s.sessions[sess.remote] == sess {
+		s.positions[sess.remote] = max(s.positions[sess.remote], latest)
+	}
+}
+
+// members returns the node id of every member of the cluster we know of, reachable or not.
+func (s *ClockClient) members() []uint64 {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	return slices.Collect(maps.Keys(s.epochs))
+}
+
+// knownHosts returns every known peer by node id.
+func (s *ClockClient) knownHosts() map[uint64]*peer {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	hosts := map[uint64]*peer{}
+	for id, hostname := range s.peers {
+		if p, exists := s.hosts[hostname]; exists {
+			hosts[id] = p
+		}
+	}
+	return hosts
+}
+
+func (s *ClockClient) reconcilePeriodically(ctx context.Context, client clockspb.ClocksClient, sess *session) {
+	ticker := time.NewTicker(s.delay * reconcileEvery)
 	defer ticker.Stop()
 	for {
+		if err := s.reconcile(ctx, client, sess); err != nil {
+			s.logger.Warn("failed to reconcile", "node", sess.remote, "error", err)
+		}
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("send data context closed: %w", ctx.Err())
+			return
 		case <-ticker.C:
 		}
-		stream, err := client.Publish(ctx)
+	}
+}
+
+// reconcile compares Merkle trees with the remote system to find the leaves where they disagree,
+// learns the remote clocks of the keys under those leaves and has both ends of sess publish
+// every key under them.
+func (s *ClockClient) reconcile(ctx context.Context, client clockspb.ClocksClient, sess *session) error {
+	var leaves []uint32
+	for nodes := []uint32{0}; len(nodes) > 0; {
+		response, err := client.Digests(ctx, &clockspb.DigestsRequest{
+			Nodes: nodes,
+		})
 		if err != nil {
-			return fmt.Errorf("opening publish stream: %w", err)
+			return fmt.Errorf("fetching digests: %w", err)
 		}
-
-		if err := s.data.Range(func(key string, record *db.Record) error {
-			remoteClock := s.remoteClocks.Get(remoteSystemId, key)
-			var chunksSince []*db.Chunk
-			if remoteClock == nil {
-				chunksSince = record.Chunks
-			} else {
-				chunksSince = record.GetChunksSince(remoteClock)
-			}
-			if len(chunksSince) == 0 {
-				return nil
-			}
-			msg := &clockspb.PublishRequest{
-				Key:    key,
-				Chunks: db.ChunksToWireType(chunksSince),
-				Clock:  record.Clock.ToWireType(),
+		local, err := s.data.Digests(nodes)
+		if err != nil {
+			return fmt.Errorf("reading local digests: %w", err)
+		}
+		if len(response.GetDigests()) != len(nodes) {
+			return fmt.Errorf("asked for %d digests but received %d", len(nodes), len(response.GetDigests()))
+		}
+		var next []uint32
+		for i, node := range nodes {
+			if response.GetDigests()[i] == local[i] {
+				continue
 			}
-			if err := stream.Send(msg); err != nil {
-				return fmt.Errorf("publishing next clock: %w", err)
+			if children := db.MerkleChildren(node); children != nil {
+				next = append(next, children...)
+			} else {
+				leaves = append(leaves, node)
 			}
-			return nil
-		}); err != nil {
-			return fmt.Errorf("iterating over data: %w", err)
-		}
-		_, err = stream.CloseAndRecv()
-		if err != nil {
-			return fmt.Errorf("receiving closing message: %w", err)
 		}
+		nodes = next
 	}
-}
-
-func withConnection(hostname string, secure bool, consumer func(clockspb.ClocksClient) error) error {
-	var creds credentials.TransportCredentials
-	if secure {
-		creds = credentials.NewTLS(&tls.Config{})
-	} else {
-		creds = insecure.NewCredentials()
+	if len(leaves) == 0 {
+		return nil
 	}
-	conn, err := grpc.NewClient(hostname, grpc.WithTransportCredentials(creds))
+
+	response, err := client.Digests(ctx, &clockspb.DigestsRequest{
+		Leaves: leaves,
+	})
 	if err != nil {
-		return fmt.Errorf("connecting to grpc server: %w", err)
+		return fmt.Errorf("fetching clocks of diverging leaves: %w", err)
 	}
-	defer conn.Close()
-	if err := consumer(clockspb.NewClocksClient(conn)); err != nil {
-		return fmt.Errorf("consuming client: %w", err)
+	for _, c := range response.GetClocks() {
+		s.remoteClocks.Accept(sess.remote, c.GetKey(), s.data.FromWireType(c.GetClock()))
 	}
+
+	sess.reconcile(leaves)
 	return nil
 }
 
-func getRemoteSystemId(hostname string) uint64 {
-	hostnameParts := strings.Split(hostname, ":")
-	id, err := strconv.Atoi(hostnameParts[1])
-	if err != nil {
-		panic(err.Error())
+// chunksFor returns the chunks of record that the remote system has not acknowledged yet.
+func (s *ClockClient) chunksFor(remoteSystemId uint64, key string, record *db.Record) []*db.Chunk {
+	remoteClock := s.remoteClocks.Get(remoteSystemId, key)
+	if remoteClock == nil {
+		return record.Chunks
 	}
-	remoteSystemId := uint64(id)
-	return remoteSystemId
+	return record.GetChunksSince(remoteClock)
 }
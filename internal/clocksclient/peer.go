@@ -0,0 +1,161 @@
+package clocksclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// A peer's circuit breaker opens after this many consecutive failures. While it is open
+	// nothing is sent to the peer, and once it has been open for breakerCooldown delays a
+	// single attempt decides whether it closes again.
+	breakerThreshold = 5
+	breakerCooldown  = 20
+	// Retries back off exponentially from one delay up to this many delays.
+	maxBackoff = 8
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// peer is a server we sync with. Its connection lives as long as the peer does, and gRPC
+// reconnects it by itself whenever it drops.
+type peer struct {
+	hostname string
+	conn     *grpc.ClientConn
+	client   clockspb.ClocksClient
+	cancel   context.CancelFunc
+
+	// Guarded by the client's peersLock.
+	status   clockspb.PeerStatus
+	failures int
+	lastErr  error
+	retryAt  time.Time
+}
+
+func newPeer(hostname string, secure bool) (*peer, error) {
+	var creds credentials.TransportCredentials
+	if secure {
+		creds = credentials.NewTLS(&tls.Config{})
+	} else {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(hostname, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to grpc server: %w", err)
+	}
+	return &peer{
+		hostname: hostname,
+		conn:     conn,
+		client:   clockspb.NewClocksClient(conn),
+	}, nil
+}
+
+func (p *peer) open(now time.Time) bool {
+	return p.failures >= breakerThreshold && now.Before(p.retryAt)
+}
+
+// call runs f against p unless its circuit breaker is open, and counts the outcome towards
+// the breaker. Calls cut short by ctx do not count.
+func (s *ClockClient) call(ctx context.Context, p *peer, f func(client clockspb.ClocksClient) error) error {
+	s.peersLock.Lock()
+	open := p.open(time.Now())
+	s.peersLock.Unlock()
+	if open {
+		return fmt.Errorf("calling %s: %w", p.hostname, errCircuitOpen)
+	}
+	err := f(p.client)
+	if ctx.Err() != nil {
+		return err
+	}
+	if err != nil {
+		s.failed(p, err)
+	} else {
+		s.succeeded(p)
+	}
+	return err
+}
+
+func (s *ClockClient) succeeded(p *peer) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	p.failures = 0
+	p.lastErr = nil
+}
+
+// failed counts a failure against p and schedules its next sync attempt, backing off
+// exponentially with jitter so that peers that fail together do not retry together.
+func (s *ClockClient) failed(p *peer, err error) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	p.failures++
+	p.lastErr = err
+	p.status = clockspb.PeerStatus_BACKING_OFF
+	now := time.Now()
+	if p.failures >= breakerThreshold {
+		if p.failures == breakerThreshold {
+			s.logger.Warn("opening circuit breaker", "peer", p.hostname, "error", err)
+		}
+		p.retryAt = now.Add(s.delay * breakerCooldown)
+		return
+	}
+	backoff := s.delay << (p.failures - 1)
+	backoff = min(backoff, s.delay*maxBackoff)
+	p.retryAt = now.Add(backoff/2 + rand.N(backoff/2+1))
+}
+
+func (s *ClockClient) setStatus(p *peer, status clockspb.PeerStatus) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	p.status = status
+}
+
+func (s *ClockClient) untilRetry(p *peer) time.Duration {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	return time.Until(p.retryAt)
+}
+
+// Peers reports the state of every peer, ordered by hostname.
+func (s *ClockClient) Peers() []*clockspb.PeerState {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	ids := map[string]uint64{}
+	for id, hostname := range s.peers {
+		ids[hostname] = id
+	}
+	now := time.Now()
+	var states []*clockspb.PeerState
+	for _, p := range s.hosts {
+		state := &clockspb.PeerState{
+			Hostname: p.hostname,
+			NodeId:   ids[p.hostname],
+			Status:   p.status,
+			Failures: uint32(p.failures),
+		}
+		if p.open(now) {
+			state.Status = clockspb.PeerStatus_CIRCUIT_OPEN
+		}
+		if p.lastErr != nil {
+			state.LastError = p.lastErr.Error()
+		}
+		if p.retryAt.After(now) {
+			state.RetryAt = uint64(p.retryAt.UnixMilli())
+		}
+		states = append(states, state)
+	}
+	slices.SortFunc(states, func(a, b *clockspb.PeerState) int {
+		return strings.Compare(a.GetHostname(), b.GetHostname())
+	})
+	return states
+}
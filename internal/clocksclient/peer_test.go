@@ -0,0 +1,90 @@
+package clocksclient_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/clocksclient"
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitOpensOnUnreachablePeer(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	dead := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	local := db.Incarnation{NodeId: 1, Epoch: 1}
+	client := clocksclient.NewClocksClient(db.NewDatabase(local), local, 3, false, time.Millisecond*20)
+	client.AddPeer(dead)
+	defer client.RemovePeer(dead)
+
+	require.Eventually(t, func() bool {
+		peers := client.Peers()
+		return len(peers) == 1 && peers[0].GetStatus() == clockspb.PeerStatus_CIRCUIT_OPEN
+	}, time.Second*5, time.Millisecond)
+	state := client.Peers()[0]
+	require.Equal(t, dead, state.GetHostname())
+	require.Zero(t, state.GetNodeId())
+	require.Equal(t, uint32(5), state.GetFailures())
+	require.NotEmpty(t, state.GetLastError())
+	require.Greater(t, state.GetRetryAt(), uint64(time.Now().UnixMilli()))
+}
+
+type refusingServer struct {
+	clockspb.UnimplementedClocksServer
+
+	hellos atomic.Int32
+}
+
+func (s *refusingServer) Hello(context.Context, *clockspb.HelloRequest) (*clockspb.HelloResponse, error) {
+	s.hellos.Add(1)
+	return nil, status.Error(codes.Unavailable, "not today")
+}
+
+func TestCircuitOpensAfterExactlyThresholdAttempts(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	refusing := &refusingServer{}
+	server := grpc.NewServer()
+	clockspb.RegisterClocksServer(server, refusing)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// Backing off takes at most 15 delays before the breaker opens, and it then stays open for
+	// 20, which leaves plenty of time to count the attempts.
+	local := db.Incarnation{NodeId: 1, Epoch: 1}
+	client := clocksclient.NewClocksClient(db.NewDatabase(local), local, 3, false, time.Millisecond*20)
+	client.AddPeer(listener.Addr().String())
+	defer client.RemovePeer(listener.Addr().String())
+
+	require.Eventually(t, func() bool {
+		peers := client.Peers()
+		return len(peers) == 1 && peers[0].GetStatus() == clockspb.PeerStatus_CIRCUIT_OPEN
+	}, time.Second*5, time.Millisecond)
+	require.Equal(t, int32(5), refusing.hellos.Load())
+	require.Equal(t, uint32(5), client.Peers()[0].GetFailures())
+}
+
+func TestPeerStateShowsHealthySync(t *testing.T) {
+	a := startNode(t, 1, time.Millisecond*10)
+	b := startNode(t, 2, time.Millisecond*10)
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+
+	require.Eventually(t, func() bool {
+		peers := a.client.Peers()
+		return len(peers) == 1 && peers[0].GetStatus() == clockspb.PeerStatus_SYNCING
+	}, time.Second*5, time.Millisecond*10)
+	state := a.client.Peers()[0]
+	require.Equal(t, uint64(2), state.GetNodeId())
+	require.Zero(t, state.GetFailures())
+}
@@ -0,0 +1,152 @@
+package clocksclient
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/db"
+)
+
+// ClusterSize is the configured number of nodes in the cluster, this one included.
+func (s *ClockClient) ClusterSize() int {
+	return s.clusterSize
+}
+
+// Replicate publishes the record at key directly to every known peer, returning once
+// required of them have merged it.
+func (s *ClockClient) Replicate(ctx context.Context, key string, record *db.Record, required int) error {
+	return s.fanOut(ctx, required, func(ctx context.Context, remoteSystemId uint64, client clockspb.ClocksClient) error {
+		stream, err := client.Publish(ctx)
+		if err != nil {
+			return fmt.Errorf("opening publish stream: %w", err)
+		}
+		if err := stream.Send(&clockspb.PublishRequest{
+			Key:    key,
+			Chunks: db.ChunksToWireType(s.chunksFor(remoteSystemId, key, record)),
+			Clock:  record.Clock.ToWireType(),
+		}); err != nil {
+			return fmt.Errorf("publishing record: %w", err)
+		}
+		if _, err := stream.CloseAndRecv(); err != nil {
+			return fmt.Errorf("receiving closing message: %w", err)
+		}
+		s.remoteClocks.Accept(remoteSystemId, key, record.Clock)
+		return nil
+	})
+}
+
+// Gather fetches the record at key from the known peers and merges each copy into the local
+// database, returning once required of them have been merged.
+func (s *ClockClient) Gather(ctx context.Context, key string, required int) error {
+	return s.fanOut(ctx, required, func(ctx context.Context, remoteSystemId uint64, client clockspb.ClocksClient) error {
+		response, err := client.Fetch(ctx, &clockspb.FetchRequest{
+			Key: key,
+		})
+		if err != nil {
+			return fmt.Errorf("fetching record: %w", err)
+		}
+		if !response.GetFound() {
+			return nil
+		}
+		remoteClock := s.data.FromWireType(response.GetClock())
+		chunks := db.ChunksFromWireType(response.GetChunks())
+		if err := db.CheckDrift(chunks); err != nil {
+			return fmt.Errorf("checking fetched record: %w", err)
+		}
+		if err := s.data.Merge(key, remoteClock, chunks); err != nil {
+			return fmt.Errorf("merging fetched record: %w", err)
+		}
+		s.remoteClocks.Accept(remoteSystemId, key, remoteClock)
+		return nil
+	})
+}
+
+// fanOut calls f against every known peer concurrently, returning as soon as required calls
+// have succeeded or once so many have failed that required can no longer be reached.
+func (s *ClockClient) fanOut(
+	ctx context.Context,
+	required int,
+	f func(ctx context.Context, remoteSystemId uint64, client clockspb.ClocksClient) error,
+) error {
+	if required <= 0 {
+		return nil
+	}
+	peers := s.knownHosts()
+	if len(peers) < required {
+		return fmt.Errorf("need %d peers but only know of %d", required, len(peers))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan error, len(peers))
+	for remoteSystemId, p := range peers {
+		go func() {
+			results <- s.call(ctx, p, func(client clockspb.ClocksClient) error {
+				return f(ctx, remoteSystemId, client)
+			})
+		}()
+	}
+
+	var succeeded, failed int
+	var lastErr error
+	for range peers {
+		if err := <-results; err != nil {
+			failed++
+			lastErr = err
+		} else {
+			succeeded++
+		}
+		if succeeded == required {
+			return nil
+		}
+		if len(peers)-failed < required {
+			break
+		}
+	}
+	return fmt.Errorf("only %d of %d required peers responded, last error: %w", succeeded, required, lastErr)
+}
+
+// Paxos runs request on the acceptor of every known peer. It returns one response per peer,
+// nil for those that failed or had not answered yet, once required peers have answered with
+// ok or every peer is done.
+func (s *ClockClient) Paxos(ctx context.Context, request *clockspb.PaxosRequest, required int) []*clockspb.PaxosResponse {
+	peers := slices.Collect(maps.Values(s.knownHosts()))
+	responses := make([]*clockspb.PaxosResponse, len(peers))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type answer struct {
+		index    int
+		response *clockspb.PaxosResponse
+	}
+	answers := make(chan answer, len(peers))
+	for i, p := range peers {
+		go func() {
+			var response *clockspb.PaxosResponse
+			if err := s.call(ctx, p, func(client clockspb.ClocksClient) error {
+				var err error
+				response, err = client.Paxos(ctx, request)
+				return err
+			}); err != nil && ctx.Err() == nil {
+				s.logger.Warn("failed to run paxos phase", "peer", p.hostname, "error", err)
+			}
+			answers <- answer{index: i, response: response}
+		}()
+	}
+
+	var ok int
+	for range peers {
+		if ok >= required {
+			break
+		}
+		a := <-answers
+		responses[a.index] = a.response
+		if a.response.GetOk() {
+			ok++
+		}
+	}
+	return responses
+}
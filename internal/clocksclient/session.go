@@ -0,0 +1,274 @@
+package clocksclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/db"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Messages carry at most this many records, so that catching a peer up on the whole
+	// keyspace does not run into the gRPC message size limit.
+	maxRecordsPerMessage = 256
+	// A session pushes changes as soon as they are written, but no more than once every
+	// pushInterval. Under load, whatever is written in between goes out in the same batch and a
+	// key written several times is only sent once.
+	pushInterval = time.Millisecond * 5
+)
+
+var errSessionExists = errors.New("already syncing with this peer")
+
+// syncStream is either end of a Sync stream.
+type syncStream interface {
+	Send(*clockspb.SyncMessage) error
+	Recv() (*clockspb.SyncMessage, error)
+}
+
+// session replicates with one peer in both directions over a single Sync stream. Both ends
+// run the same session: each publishes the keys that change locally, merges what the other
+// end publishes and acknowledges it on the same stream.
+type session struct {
+	client *ClockClient
+	remote uint64
+	stream syncStream
+	cancel context.CancelFunc
+	wake   chan struct{}
+
+	lock sync.Mutex
+	// What the next message should carry besides changes.
+	acks         []*clockspb.KeyClock
+	acknowledged uint64
+	requests     []uint32
+	// Leaves the peer asked us to publish.
+	leaves []uint32
+}
+
+// openSession registers a session with remote. Only one session per peer runs at a time.
+// When two nodes dial each other at once, the stream dialed by the node with the lower id
+// wins.
+func (s *ClockClient) openSession(
+	ctx context.Context,
+	remoteSystemId uint64,
+	stream syncStream,
+	dialed bool,
+) (*session, context.Context, error) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	if existing, exists := s.sessions[remoteSystemId]; exists {
+		dialedByLower := dialed == (s.local.NodeId < remoteSystemId)
+		if !dialedByLower {
+			return nil, nil, errSessionExists
+		}
+		existing.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	sess := &session{
+		client: s,
+		remote: remoteSystemId,
+		stream: stream,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+	}
+	s.sessions[remoteSystemId] = sess
+	return sess, ctx, nil
+}
+
+func (s *ClockClient) closeSession(sess *session) {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	sess.cancel()
+	if s.sessions[sess.remote] == sess {
+		delete(s.sessions, sess.remote)
+	}
+}
+
+func (s *ClockClient) hasSession(remoteSystemId uint64) bool {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+	_, exists := s.sessions[remoteSystemId]
+	return exists
+}
+
+// run replicates until either direction fails or ctx is done.
+func (sess *session) run(ctx context.Context) error {
+	errs := make(chan error, 2)
+	go func() {
+		errs <- sess.receive()
+	}()
+	go func() {
+		errs <- sess.send(ctx)
+	}()
+	err := <-errs
+	sess.cancel()
+	return err
+}
+
+func (sess *session) poke() {
+	select {
+	case sess.wake <- struct{}{}:
+	default:
+	}
+}
+
+// reconcile asks the peer to publish every key under leaves, and publishes ours in return.
+func (sess *session) reconcile(leaves []uint32) {
+	sess.lock.Lock()
+	sess.requests = append(sess.requests, leaves...)
+	sess.leaves = append(sess.leaves, leaves...)
+	sess.lock.Unlock()
+	sess.poke()
+}
+
+func (sess *session) receive() error {
+	data := sess.client.data
+	remoteClocks := sess.client.remoteClocks
+	// Keys refused for being stamped too far ahead. Acknowledging a checkpoint would let the
+	// peer resume past them, so none are acknowledged until they have all been merged.
+	refused := map[string]struct{}{}
+	for {
+		msg, err := sess.stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("peer closed the sync stream")
+		}
+		if err != nil {
+			return fmt.Errorf("receiving sync message: %w", err)
+		}
+		var acks []*clockspb.KeyClock
+		for _, record := range msg.GetRecords() {
+			clock := data.FromWireType(record.GetClock())
+			if len(record.GetChunks()) > 0 {
+				chunks := db.ChunksFromWireType(record.GetChunks())
+				if err := db.CheckDrift(chunks); err != nil {
+					// Left unacknowledged, the record is published again once reconciling finds
+					// it missing, by which time our clock may have caught up with it.
+					sess.client.logger.Warn("refusing record from the future", "peer", sess.remote, "key", record.GetKey(), "error", err)
+					refused[record.GetKey()] = struct{}{}
+					continue
+				}
+				if err := data.Merge(record.GetKey(), clock, chunks); err != nil {
+					return fmt.Errorf("merging %s: %w", record.GetKey(), err)
+				}
+				delete(refused, record.GetKey())
+				merged, _, err := data.Get(record.GetKey())
+				if err != nil {
+					return fmt.Errorf("reading merged record: %w", err)
+				}
+				acks = append(acks, &clockspb.KeyClock{
+					Key:   record.GetKey(),
+					Clock: merged.Clock.ToWireType(),
+				})
+			}
+			remoteClocks.Accept(sess.remote, record.GetKey(), clock)
+		}
+		for _, ack := range msg.GetAcks() {
+			remoteClocks.Accept(sess.remote, ack.GetKey(), data.FromWireType(ack.GetClock()))
+		}
+		if msg.GetAcknowledged() > 0 {
+			sess.client.advance(sess, msg.GetAcknowledged())
+		}
+
+		sess.lock.Lock()
+		sess.acks = append(sess.acks, acks...)
+		if msg.GetCheckpoint() > 0 && len(refused) == 0 {
+			sess.acknowledged = msg.GetCheckpoint()
+		}
+		sess.leaves = append(sess.leaves, msg.GetLeaves()...)
+		sess.lock.Unlock()
+		sess.poke()
+	}
+}
+
+func (sess *session) send(ctx context.Context) error {
+	listener := sess.client.data.ListenForChanges()
+	defer sess.client.data.StopListening(listener)
+	// Pushing relies on every change waking us up. Publishing every delay regardless bounds
+	// how long anything missed can sit here.
+	safetyNet := time.NewTicker(sess.client.delay)
+	defer safetyNet.Stop()
+	since := sess.client.position(sess.remote)
+	for {
+		latest, err := sess.publish(since)
+		if err != nil {
+			return err
+		}
+		since = latest
+		published := time.Now()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-listener.Wake():
+		case <-sess.wake:
+		case <-safetyNet.C:
+		}
+		if wait := pushInterval - time.Since(published); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// publish sends every key changed after since along with whatever acks, requests and leaves
+// are pending, and returns the latest change it sent.
+func (sess *session) publish(since uint64) (uint64, error) {
+	sess.lock.Lock()
+	msg := &clockspb.SyncMessage{
+		Acks:         sess.acks,
+		Acknowledged: sess.acknowledged,
+		Leaves:       sess.requests,
+	}
+	leaves := sess.leaves
+	sess.acks, sess.acknowledged, sess.requests, sess.leaves = nil, 0, nil, nil
+	sess.lock.Unlock()
+
+	send := func() error {
+		if err := sess.stream.Send(msg); err != nil {
+			return fmt.Errorf("sending sync message: %w", err)
+		}
+		msg = &clockspb.SyncMessage{}
+		return nil
+	}
+	add := func(key string, record *db.Record) error {
+		remoteClock := sess.client.remoteClocks.Get(sess.remote, key)
+		if remoteClock != nil && db.Dominates(remoteClock, record.Clock) {
+			return nil
+		}
+		msg.Records = append(msg.Records, &clockspb.PublishRequest{
+			Key:    key,
+			Chunks: db.ChunksToWireType(sess.client.chunksFor(sess.remote, key, record)),
+			Clock:  record.Clock.ToWireType(),
+		})
+		if len(msg.Records) < maxRecordsPerMessage {
+			return nil
+		}
+		return send()
+	}
+
+	if len(leaves) > 0 {
+		if err := sess.client.data.RangeLeaves(leaves, add); err != nil {
+			return 0, fmt.Errorf("publishing requested leaves: %w", err)
+		}
+	}
+	latest, err := sess.client.data.Changes(since, add)
+	if err != nil {
+		return 0, fmt.Errorf("publishing changes: %w", err)
+	}
+	if latest != since {
+		msg.Checkpoint = latest
+	}
+	if proto.Size(msg) > 0 {
+		if err := send(); err != nil {
+			return 0, err
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,163 @@
+package clocksclient_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/clocksclient"
+	"github.com/WadeCappa/consensus/internal/clockserver"
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type node struct {
+	data    *db.Database
+	client  *clocksclient.ClockClient
+	address string
+}
+
+func startNode(t *testing.T, nodeId uint64, delay time.Duration) *node {
+	local := db.Incarnation{NodeId: nodeId, Epoch: 1}
+	data := db.NewDatabase(local)
+	client := clocksclient.NewClocksClient(data, local, 3, false, delay)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	clockspb.RegisterClocksServer(server, clockserver.NewClockServer(data, local, paxos.NewAcceptor(), client))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return &node{data: data, client: client, address: listener.Addr().String()}
+}
+
+// requireValue waits for the live chunks of key to add up to value.
+func requireValue(t *testing.T, data *db.Database, key, value string) {
+	require.Eventually(t, func() bool {
+		record, exists, err := data.Get(key)
+		require.NoError(t, err)
+		return exists && string(db.Concat(record.Live())) == value
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestSyncReplicatesBothWaysOverOneStream(t *testing.T) {
+	a := startNode(t, 1, time.Millisecond*10)
+	b := startNode(t, 2, time.Millisecond*10)
+	require.NoError(t, a.data.Put("from-a", []byte("1"), time.Now(), nil))
+	require.NoError(t, b.data.Put("from-b", []byte("1"), time.Now(), nil))
+
+	// Only a dials, so everything b has must reach a over the stream a opened.
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+	requireValue(t, b.data, "from-a", "1")
+	requireValue(t, a.data, "from-b", "1")
+
+	require.NoError(t, b.data.Put("from-a", []byte("2"), time.Now(), nil))
+	require.NoError(t, a.data.Delete("from-b", time.Now()))
+	requireValue(t, a.data, "from-a", "12")
+	requireValue(t, b.data, "from-b", "")
+}
+
+func TestSimultaneousDialsSettleOnOneSession(t *testing.T) {
+	a := startNode(t, 1, time.Millisecond*10)
+	b := startNode(t, 2, time.Millisecond*10)
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+	b.client.AddPeer(a.address)
+	defer b.client.RemovePeer(a.address)
+
+	var value string
+	for i := range 20 {
+		writer := a
+		if i%2 == 1 {
+			writer = b
+		}
+		update := string(rune('a' + i))
+		require.NoError(t, writer.data.Put("key", []byte(update), time.Now(), nil))
+		value += update
+		requireValue(t, a.data, "key", value)
+		requireValue(t, b.data, "key", value)
+	}
+}
+
+func TestWritesArePushedWithoutWaitingForTheNextRound(t *testing.T) {
+	a := startNode(t, 1, time.Second)
+	b := startNode(t, 2, time.Second)
+	require.NoError(t, a.data.Put("first", []byte("1"), time.Now(), nil))
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+	requireValue(t, b.data, "first", "1")
+
+	for i := range 5 {
+		key := fmt.Sprintf("key-%d", i)
+		start := time.Now()
+		require.NoError(t, b.data.Put(key, []byte("x"), time.Now(), nil))
+		requireValue(t, a.data, key, "x")
+		require.Less(t, time.Since(start), time.Millisecond*500)
+	}
+}
+
+func TestRecordsFromTooFarAheadAreNotReplicated(t *testing.T) {
+	a := startNode(t, 1, time.Millisecond*10)
+	b := startNode(t, 2, time.Millisecond*10)
+	// Writes made after the one stamped ahead would be stamped ahead as well.
+	require.NoError(t, a.data.Put("present", []byte("x"), time.Now(), nil))
+	require.NoError(t, a.data.Put("future", []byte("x"), time.Now().Add(time.Hour), nil))
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+
+	requireValue(t, b.data, "present", "x")
+	_, exists, err := b.data.Get("future")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+// requireTombstone waits for between and then checks that key on data still holds chunks.
+func requireTombstone(t *testing.T, data *db.Database, key string, between time.Duration) {
+	time.Sleep(between)
+	record, exists, err := data.Get(key)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.NotEmpty(t, record.Chunks)
+}
+
+func TestTombstonesWaitForRemovedMembers(t *testing.T) {
+	delay := time.Millisecond * 10
+	a := startNode(t, 1, delay)
+	b := startNode(t, 2, delay)
+	c := startNode(t, 3, delay)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.client.CollectTombstones(ctx)
+
+	// With no members to acknowledge it, the delete is never collected.
+	require.NoError(t, a.data.Put("alone", []byte("x"), time.Now(), nil))
+	require.NoError(t, a.data.Delete("alone", time.Now()))
+	requireTombstone(t, a.data, "alone", delay*20)
+
+	require.NoError(t, a.data.Put("key", []byte("x"), time.Now(), nil))
+	a.client.AddPeer(b.address)
+	defer a.client.RemovePeer(b.address)
+	a.client.AddPeer(c.address)
+	requireValue(t, b.data, "key", "x")
+	requireValue(t, c.data, "key", "x")
+
+	// c stays a member while it is out, so b acknowledging the delete is not enough.
+	a.client.RemovePeer(c.address)
+	require.NoError(t, a.data.Delete("key", time.Now()))
+	requireValue(t, b.data, "key", "")
+	requireTombstone(t, a.data, "key", delay*20)
+
+	a.client.AddPeer(c.address)
+	defer a.client.RemovePeer(c.address)
+	requireValue(t, c.data, "key", "")
+	require.Eventually(t, func() bool {
+		record, _, err := a.data.Get("key")
+		require.NoError(t, err)
+		return len(record.Chunks) == 0
+	}, time.Second*5, delay)
+}
@@ -1,24 +1,77 @@
 package clockserver
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand/v2"
 
 	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
 	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/paxos"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
+// Syncer runs the Sync streams that peers dial, and reports how syncing with each peer goes.
+type Syncer interface {
+	ServeSync(stream grpc.BidiStreamingServer[clockspb.SyncMessage, clockspb.SyncMessage]) error
+	Peers() []*clockspb.PeerState
+}
+
 type clockServer struct {
 	clockspb.ClocksServer
 
-	data *db.Database
+	data     db.Storage
+	local    db.Incarnation
+	acceptor *paxos.Acceptor
+	syncer   Syncer
+	// Distinguishes Ack resume tokens from before a restart, whose positions no longer apply.
+	instance uint64
 }
 
-func NewClockServer(db *db.Database) clockspb.ClocksServer {
+func NewClockServer(data db.Storage, local db.Incarnation, acceptor *paxos.Acceptor, syncer Syncer) clockspb.ClocksServer {
 	return &clockServer{
-		data: db,
+		data:     data,
+		local:    local,
+		acceptor: acceptor,
+		syncer:   syncer,
+		instance: rand.Uint64(),
+	}
+}
+
+func (s *clockServer) Hello(
+	ctx context.Context,
+	request *clockspb.HelloRequest,
+) (*clockspb.HelloResponse, error) {
+	if request.GetNodeId() == s.local.NodeId {
+		return nil, status.Errorf(codes.AlreadyExists, "node id %d is already in use by this node", s.local.NodeId)
 	}
+	return &clockspb.HelloResponse{
+		NodeId: s.local.NodeId,
+		Epoch:  s.local.Epoch,
+	}, nil
+}
+
+func (s *clockServer) Sync(stream grpc.BidiStreamingServer[clockspb.SyncMessage, clockspb.SyncMessage]) error {
+	if s.syncer == nil {
+		return status.Error(codes.Unimplemented, "this node does not sync")
+	}
+	return s.syncer.ServeSync(stream)
+}
+
+func (s *clockServer) Peers(
+	ctx context.Context,
+	request *clockspb.PeersRequest,
+) (*clockspb.PeersResponse, error) {
+	if s.syncer == nil {
+		return nil, status.Error(codes.Unimplemented, "this node does not sync")
+	}
+	return &clockspb.PeersResponse{
+		Peers: s.syncer.Peers(),
+	}, nil
 }
 
 func (s *clockServer) Publish(
@@ -36,7 +89,11 @@ func (s *clockServer) Publish(
 		if err != nil {
 			return fmt.Errorf("receiving next publish request: %w", err)
 		}
-		if err := s.data.Merge(request.Key, db.FromWireType(request.Clock), db.ChunksFromWireType(request.Chunks)); err != nil {
+		chunks := db.ChunksFromWireType(request.Chunks)
+		if err := db.CheckDrift(chunks); err != nil {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err := s.data.Merge(request.Key, s.data.FromWireType(request.Clock), chunks); err != nil {
 			return fmt.Errorf("merging clocks: %w", err)
 		}
 	}
@@ -50,13 +107,105 @@ func (s *clockServer) Ack(
 	request *clockspb.AckRequest,
 	stream grpc.ServerStreamingServer[clockspb.AckResponse],
 ) error {
-	return s.data.Range(func(key string, record *db.Record) error {
-		if err := stream.Send(&clockspb.AckResponse{
+	since := s.resumeFrom(request.GetResumeToken())
+	listener := s.data.ListenForChanges()
+	defer s.data.StopListening(listener)
+	for first := true; ; first = false {
+		latest, err := s.data.Changes(since, func(key string, record *db.Record) error {
+			if err := stream.Send(&clockspb.AckResponse{
+				Key:   key,
+				Clock: record.Clock.ToWireType(),
+			}); err != nil {
+				return fmt.Errorf("sending ack: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("sending changed clocks: %w", err)
+		}
+		if latest != since || first {
+			token, err := proto.Marshal(&clockspb.AckPosition{
+				Instance: s.instance,
+				Sequence: latest,
+			})
+			if err != nil {
+				return fmt.Errorf("encoding resume token: %w", err)
+			}
+			if err := stream.Send(&clockspb.AckResponse{ResumeToken: token}); err != nil {
+				return fmt.Errorf("sending checkpoint: %w", err)
+			}
+			since = latest
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-listener.Wake():
+		}
+	}
+}
+
+// resumeFrom returns the change to resume an Ack stream after, which is 0 to start over if
+// the token is missing, malformed or from before this server restarted.
+func (s *clockServer) resumeFrom(token []byte) uint64 {
+	position := &clockspb.AckPosition{}
+	if err := proto.Unmarshal(token, position); err != nil || position.GetInstance() != s.instance {
+		return 0
+	}
+	return position.GetSequence()
+}
+
+func (s *clockServer) Fetch(
+	ctx context.Context,
+	request *clockspb.FetchRequest,
+) (*clockspb.FetchResponse, error) {
+	record, exists, err := s.data.Get(request.GetKey())
+	if err != nil {
+		return nil, fmt.Errorf("reading record: %w", err)
+	}
+	if !exists {
+		return &clockspb.FetchResponse{}, nil
+	}
+	return &clockspb.FetchResponse{
+		Found:  true,
+		Clock:  record.Clock.ToWireType(),
+		Chunks: db.ChunksToWireType(record.Chunks),
+	}, nil
+}
+
+func (s *clockServer) Paxos(
+	ctx context.Context,
+	request *clockspb.PaxosRequest,
+) (*clockspb.PaxosResponse, error) {
+	response, err := s.acceptor.Handle(request)
+	if err != nil {
+		return nil, fmt.Errorf("handling paxos phase: %w", err)
+	}
+	return response, nil
+}
+
+func (s *clockServer) Digests(
+	ctx context.Context,
+	request *clockspb.DigestsRequest,
+) (*clockspb.DigestsResponse, error) {
+	digests, err := s.data.Digests(request.GetNodes())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	response := &clockspb.DigestsResponse{
+		Digests: digests,
+	}
+	if len(request.GetLeaves()) == 0 {
+		return response, nil
+	}
+	if err := s.data.RangeLeaves(request.GetLeaves(), func(key string, record *db.Record) error {
+		response.Clocks = append(response.Clocks, &clockspb.KeyClock{
 			Key:   key,
 			Clock: record.Clock.ToWireType(),
-		}); err != nil {
-			return fmt.Errorf("sending ack: %w", err)
-		}
+		})
 		return nil
-	})
+	}); err != nil {
+		return nil, fmt.Errorf("reading leaves: %w", err)
+	}
+	return response, nil
 }
@@ -0,0 +1,82 @@
+package clockserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/clockserver"
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func startServer(t *testing.T, data db.Storage) clockspb.ClocksClient {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	clockspb.RegisterClocksServer(server, clockserver.NewClockServer(data, db.Incarnation{NodeId: 1}, paxos.NewAcceptor(), nil))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return clockspb.NewClocksClient(conn)
+}
+
+// nextBatch reads acks up to and including the next checkpoint.
+func nextBatch(t *testing.T, stream grpc.ServerStreamingClient[clockspb.AckResponse]) ([]string, []byte) {
+	var keys []string
+	for {
+		ack, err := stream.Recv()
+		require.NoError(t, err)
+		if ack.GetResumeToken() != nil {
+			return keys, ack.GetResumeToken()
+		}
+		keys = append(keys, ack.GetKey())
+	}
+}
+
+func TestAckStreamSendsOnlyChangesAndResumes(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 1})
+	client := startServer(t, data)
+	require.NoError(t, data.Put("a", []byte("1"), time.Now(), nil))
+	require.NoError(t, data.Put("b", []byte("1"), time.Now(), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	first, cancelFirst := context.WithCancel(ctx)
+	stream, err := client.Ack(first, &clockspb.AckRequest{})
+	require.NoError(t, err)
+	keys, _ := nextBatch(t, stream)
+	require.ElementsMatch(t, []string{"a", "b"}, keys)
+
+	require.NoError(t, data.Put("b", []byte("2"), time.Now(), nil))
+	keys, token := nextBatch(t, stream)
+	require.Equal(t, []string{"b"}, keys)
+	cancelFirst()
+
+	require.NoError(t, data.Put("c", []byte("1"), time.Now(), nil))
+	stream, err = client.Ack(ctx, &clockspb.AckRequest{ResumeToken: token})
+	require.NoError(t, err)
+	keys, _ = nextBatch(t, stream)
+	require.Equal(t, []string{"c"}, keys)
+}
+
+func TestAckStreamStartsOverWithUnknownToken(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 1})
+	client := startServer(t, data)
+	require.NoError(t, data.Put("a", []byte("1"), time.Now(), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	stream, err := client.Ack(ctx, &clockspb.AckRequest{ResumeToken: []byte("not a token")})
+	require.NoError(t, err)
+	keys, _ := nextBatch(t, stream)
+	require.Equal(t, []string{"a"}, keys)
+}
@@ -0,0 +1,174 @@
+package db_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+)
+
+// Run the benchmarks with -cpu=1,2,4,8 to see how throughput scales with cores. Every
+// goroutine writes its own keys, which spread over all shards.
+
+func BenchmarkConcurrentPut(b *testing.B) {
+	data := db.NewDatabase(testWriter)
+	var goroutines atomic.Uint64
+	b.RunParallel(func(pb *testing.PB) {
+		id := goroutines.Add(1)
+		for i := 0; pb.Next(); i++ {
+			if err := data.Put(fmt.Sprintf("key-%d-%d", id, i), []byte("data"), time.Now(), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Every put waits for the log to be synced, so the puts of other goroutines that arrive in
+// the meantime are synced along with it. Those goroutines mostly wait on the disk, so there
+// are several per core.
+func BenchmarkConcurrentPutLogged(b *testing.B) {
+	data, err := db.OpenDatabase(testWriter, b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer data.Close()
+	var goroutines atomic.Uint64
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		id := goroutines.Add(1)
+		for i := 0; pb.Next(); i++ {
+			if err := data.Put(fmt.Sprintf("key-%d-%d", id, i), []byte("data"), time.Now(), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkConcurrentMerge(b *testing.B) {
+	data := db.NewDatabase(testWriter)
+	var goroutines atomic.Uint64
+	b.RunParallel(func(pb *testing.PB) {
+		id := goroutines.Add(1)
+		remote := testNodeId + id
+		for i := 0; pb.Next(); i++ {
+			version := uint64(i%8) + 1
+			key := fmt.Sprintf("key-%d-%d", id, i/8)
+			if err := data.Merge(key, db.From(map[uint64]uint64{remote: version}), []*db.Chunk{
+				db.NewChunk(remote, version, time.Now(), []byte("data")),
+			}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetWhilePutting(b *testing.B) {
+	data := db.NewDatabase(testWriter)
+	for i := range 1024 {
+		if err := data.Put(fmt.Sprintf("key-%d", i), []byte("data"), time.Now(), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var goroutines atomic.Uint64
+	b.RunParallel(func(pb *testing.PB) {
+		writer := goroutines.Add(1)%2 == 0
+		for i := 0; pb.Next(); i++ {
+			key := fmt.Sprintf("key-%d", i%1024)
+			if writer {
+				if err := data.Put(key, []byte("data"), time.Now(), nil); err != nil {
+					b.Fatal(err)
+				}
+				continue
+			}
+			if _, _, err := data.Get(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+var clusterSizes = []int{3, 10, 50, 100, 500}
+
+// clusterClock has a version for every node in a cluster of the given size, each version
+// raised by bump.
+func clusterClock(nodes int, bump uint64) *db.Clock {
+	versions := map[uint64]uint64{}
+	for nodeId := range uint64(nodes) {
+		versions[nodeId] = nodeId + bump
+	}
+	return db.From(versions)
+}
+
+func BenchmarkOrder(b *testing.B) {
+	for _, nodes := range clusterSizes {
+		b.Run(fmt.Sprintf("nodes=%d", nodes), func(b *testing.B) {
+			older, newer := clusterClock(nodes, 0), clusterClock(nodes, 1)
+			b.ReportAllocs()
+			for b.Loop() {
+				db.Order(older, newer)
+			}
+		})
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	for _, nodes := range clusterSizes {
+		b.Run(fmt.Sprintf("nodes=%d", nodes), func(b *testing.B) {
+			clock, remote := clusterClock(nodes, 0), clusterClock(nodes, 1)
+			b.ReportAllocs()
+			for b.Loop() {
+				clock.Merge(remote)
+			}
+		})
+	}
+}
+
+func BenchmarkGetChunksSince(b *testing.B) {
+	for _, nodes := range clusterSizes {
+		b.Run(fmt.Sprintf("nodes=%d", nodes), func(b *testing.B) {
+			var chunks []*db.Chunk
+			for nodeId := range uint64(nodes) {
+				chunks = append(chunks, db.NewChunk(nodeId, nodeId+1, time.Now(), []byte("data")))
+			}
+			record := db.NewRecord(clusterClock(nodes, 1), chunks)
+			seen := clusterClock(nodes, 0)
+			b.ReportAllocs()
+			for b.Loop() {
+				record.GetChunksSince(seen)
+			}
+		})
+	}
+}
+
+// BenchmarkOrderRecordWithWireClock compares a record's clock with a clock received over the
+// wire, as replication does for every record it merges or acknowledges. A clock built by the
+// database shares its table with the record, while one built by db.FromWireType does not.
+func BenchmarkOrderRecordWithWireClock(b *testing.B) {
+	for _, nodes := range clusterSizes {
+		data := db.NewDatabase(testWriter)
+		if err := data.Merge("key", clusterClock(nodes, 0), nil); err != nil {
+			b.Fatal(err)
+		}
+		record, _, err := data.Get("key")
+		if err != nil {
+			b.Fatal(err)
+		}
+		wire := clusterClock(nodes, 1).ToWireType()
+		for _, table := range []struct {
+			name   string
+			remote *db.Clock
+		}{
+			{name: "database", remote: data.FromWireType(wire)},
+			{name: "shared", remote: db.FromWireType(wire)},
+		} {
+			b.Run(fmt.Sprintf("nodes=%d/table=%s", nodes, table.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					db.Order(record.Clock, table.remote)
+				}
+			})
+		}
+	}
+}
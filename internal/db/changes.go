@@ -0,0 +1,138 @@
+package db
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// A shard of the change index is compacted once it holds this many superseded changes on
+// top of one change per key.
+const changeCompactThreshold = 1024
+
+type change struct {
+	sequence uint64
+	key      string
+}
+
+// changeIndex numbers every change to the database so that replication can resume from the
+// last change a peer acknowledged instead of scanning every key. Only the latest change to
+// each key is kept, so a key changed many times is visited once. Keys are split over shards
+// like the database's, so that writes to different shards do not contend.
+type changeIndex struct {
+	sequence atomic.Uint64
+	shards   [shardCount]changeShard
+}
+
+type changeShard struct {
+	lock   sync.Mutex
+	latest map[string]uint64
+	// Changes in sequence order, including superseded ones that have not been compacted yet.
+	log []change
+}
+
+func newChangeIndex() *changeIndex {
+	c := &changeIndex{}
+	for i := range c.shards {
+		c.shards[i].latest = map[string]uint64{}
+	}
+	return c
+}
+
+func (c *changeIndex) record(key string) {
+	s := &c.shards[shardIndex(key)]
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// Numbered under the shard's lock, so that since never sees a number whose change has
+	// not been logged yet.
+	sequence := c.sequence.Add(1)
+	s.latest[key] = sequence
+	s.log = append(s.log, change{sequence: sequence, key: key})
+	if len(s.log) > len(s.latest)+changeCompactThreshold {
+		s.compact()
+	}
+}
+
+func (s *changeShard) compact() {
+	s.log = slices.DeleteFunc(s.log, func(ch change) bool {
+		return s.latest[ch.key] != ch.sequence
+	})
+}
+
+// since returns the keys changed after sequence, in the order they were last changed, and
+// the number of the latest change.
+func (c *changeIndex) since(sequence uint64) ([]string, uint64) {
+	for i := range c.shards {
+		c.shards[i].lock.Lock()
+		defer c.shards[i].lock.Unlock()
+	}
+	var changes []change
+	for i := range c.shards {
+		s := &c.shards[i]
+		start := sort.Search(len(s.log), func(i int) bool {
+			return s.log[i].sequence > sequence
+		})
+		for _, ch := range s.log[start:] {
+			if s.latest[ch.key] == ch.sequence {
+				changes = append(changes, ch)
+			}
+		}
+	}
+	slices.SortFunc(changes, func(a, b change) int {
+		return cmp.Compare(a.sequence, b.sequence)
+	})
+	keys := make([]string, len(changes))
+	for i, ch := range changes {
+		keys[i] = ch.key
+	}
+	return keys, c.sequence.Load()
+}
+
+// ChangeListener is woken after every change to the database. Wakes coalesce, so a listener
+// that is busy while several changes land is woken once and can pick them all up through
+// Changes. Unlike a Watcher, a listener never falls behind.
+type ChangeListener struct {
+	wake chan struct{}
+}
+
+func (l *ChangeListener) Wake() <-chan struct{} {
+	return l.wake
+}
+
+// Listeners are kept in a slice that is replaced rather than changed, so that writers wake
+// them without taking watchLock.
+func (d *Database) ListenForChanges() *ChangeListener {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	l := &ChangeListener{wake: make(chan struct{}, 1)}
+	listeners := append(slices.Clip(d.listeners()), l)
+	d.listening.Store(&listeners)
+	return l
+}
+
+func (d *Database) StopListening(l *ChangeListener) {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	listeners := slices.DeleteFunc(slices.Clone(d.listeners()), func(other *ChangeListener) bool {
+		return other == l
+	})
+	d.listening.Store(&listeners)
+}
+
+func (d *Database) listeners() []*ChangeListener {
+	if listeners := d.listening.Load(); listeners != nil {
+		return *listeners
+	}
+	return nil
+}
+
+func (d *Database) wakeListeners() {
+	for _, l := range d.listeners() {
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+}
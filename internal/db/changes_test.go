@@ -0,0 +1,78 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func changedKeys(t *testing.T, data db.Storage, since uint64) ([]string, uint64) {
+	var keys []string
+	latest, err := data.Changes(since, func(key string, record *db.Record) error {
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	return keys, latest
+}
+
+func TestChangesVisitOnlyModifiedKeys(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	for i := range 5 {
+		require.NoError(t, data.Put(fmt.Sprintf("key-%d", i), []byte("data"), time.Now(), nil))
+	}
+	keys, position := changedKeys(t, data, 0)
+	require.Len(t, keys, 5)
+
+	keys, unchanged := changedKeys(t, data, position)
+	require.Empty(t, keys)
+	require.Equal(t, position, unchanged)
+
+	require.NoError(t, data.Put("key-3", []byte("more"), time.Now(), nil))
+	require.NoError(t, data.Merge("key-1", db.From(map[uint64]uint64{testNodeId + 1: 1}), []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, time.Now(), []byte("remote")),
+	}))
+	require.NoError(t, data.Put("key-3", []byte("again"), time.Now(), nil))
+
+	// A key changed several times is visited once, in the position of its latest change.
+	keys, _ = changedKeys(t, data, position)
+	require.Equal(t, []string{"key-1", "key-3"}, keys)
+}
+
+func TestChangesSurviveCompaction(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	for i := range 3000 {
+		require.NoError(t, data.Put(fmt.Sprintf("key-%d", i%3), []byte("x"), time.Now(), nil))
+	}
+	keys, position := changedKeys(t, data, 0)
+	require.Equal(t, []string{"key-0", "key-1", "key-2"}, keys)
+	require.Equal(t, uint64(3000), position)
+
+	keys, _ = changedKeys(t, data, 2998)
+	require.Equal(t, []string{"key-1", "key-2"}, keys)
+}
+
+func TestChangeListenersCoalesceWakes(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	listener := data.ListenForChanges()
+	defer data.StopListening(listener)
+
+	for i := range 10 {
+		require.NoError(t, data.Put(fmt.Sprintf("key-%d", i), []byte("data"), time.Now(), nil))
+	}
+	require.NoError(t, data.Merge("key-0", db.From(map[uint64]uint64{testNodeId + 1: 1}), []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, time.Now(), []byte("remote")),
+	}))
+	<-listener.Wake()
+	select {
+	case <-listener.Wake():
+		t.Fatal("woken more than once for changes that landed together")
+	default:
+	}
+
+	require.NoError(t, data.Put("key-0", []byte("more"), time.Now(), nil))
+	<-listener.Wake()
+}
@@ -1,10 +1,12 @@
 package db
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"log"
-	"maps"
+	"slices"
+	"strconv"
 
 	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
 )
@@ -18,85 +20,240 @@ const (
 	Equal
 )
 
+// Incarnation identifies a writer. A node that restarts without its previous state starts a
+// new epoch and so becomes a new writer, whose versions count up from 1 without being
+// mistaken for versions written by its previous incarnations.
+type Incarnation struct {
+	NodeId uint64
+	Epoch  uint64
+}
+
+func (i Incarnation) String() string {
+	if i.Epoch == 0 {
+		return strconv.FormatUint(i.NodeId, 10)
+	}
+	return fmt.Sprintf("%d@%d", i.NodeId, i.Epoch)
+}
+
+// Clock holds the version of every writer it has seen, sorted by the number its writer table
+// gave the writer. Clocks that share a table are compared and merged entry by entry without
+// looking anything up.
 type Clock struct {
-	clock map[uint64]uint64
+	table   *writerTable
+	entries []entry
+}
+
+type entry struct {
+	writer  uint32
+	version uint64
 }
 
 func EmptyClock() *Clock {
-	return &Clock{
-		clock: map[uint64]uint64{},
-	}
+	return &Clock{table: sharedWriters}
 }
 
+// From builds a clock of writers in their first epoch, keyed by node id.
 func From(clock map[uint64]uint64) *Clock {
-	return &Clock{
-		clock: clock,
+	entries := make(map[Incarnation]uint64, len(clock))
+	for nodeId, version := range clock {
+		entries[Incarnation{NodeId: nodeId}] = version
 	}
+	return FromEntries(entries)
+}
+
+func FromEntries(entries map[Incarnation]uint64) *Clock {
+	return sharedWriters.clockOf(entries)
 }
 
-func newClock(localId, startingVersion uint64) *Clock {
+func newClock(table *writerTable, writer Incarnation, startingVersion uint64) *Clock {
 	return &Clock{
-		clock: map[uint64]uint64{
-			localId: startingVersion,
-		},
+		table:   table,
+		entries: []entry{{writer: table.number(writer), version: startingVersion}},
 	}
 }
 
+// FromWireType builds a clock whose writers are numbered in the table shared by clocks built
+// outside of a database. Clocks that are compared with a database's records should be built
+// with Database.FromWireType instead.
 func FromWireType(clock *clockspb.VectorClock) *Clock {
-	return &Clock{
-		clock: clock.Clock,
+	return sharedWriters.clockOf(wireEntries(clock))
+}
+
+func wireEntries(clock *clockspb.VectorClock) map[Incarnation]uint64 {
+	entries := make(map[Incarnation]uint64, len(clock.GetClock())+len(clock.GetEntries()))
+	for nodeId, version := range clock.GetClock() {
+		entries[Incarnation{NodeId: nodeId}] = version
 	}
+	for _, e := range clock.GetEntries() {
+		entries[Incarnation{NodeId: e.GetNodeId(), Epoch: e.GetEpoch()}] = e.GetVersion()
+	}
+	return entries
 }
 
+// ToWireType keeps first epoch writers in the plain map, so that clocks written before
+// epochs existed read back unchanged.
 func (c *Clock) ToWireType() *clockspb.VectorClock {
-	return &clockspb.VectorClock{
-		Clock: c.clock,
+	result := &clockspb.VectorClock{
+		Clock: map[uint64]uint64{},
+	}
+	writers := c.table.writers()
+	for _, e := range c.entries {
+		writer := writers[e.writer]
+		if writer.Epoch == 0 {
+			result.Clock[writer.NodeId] = e.version
+			continue
+		}
+		result.Entries = append(result.Entries, &clockspb.VersionEntry{
+			NodeId:  writer.NodeId,
+			Epoch:   writer.Epoch,
+			Version: e.version,
+		})
+	}
+	slices.SortFunc(result.Entries, func(a, b *clockspb.VersionEntry) int {
+		return cmp.Or(cmp.Compare(a.GetNodeId(), b.GetNodeId()), cmp.Compare(a.GetEpoch(), b.GetEpoch()))
+	})
+	return result
+}
+
+// Entries returns a copy of the version of every writer in the clock.
+func (c *Clock) Entries() map[Incarnation]uint64 {
+	result := make(map[Incarnation]uint64, len(c.entries))
+	writers := c.table.writers()
+	for _, e := range c.entries {
+		result[writers[e.writer]] = e.version
+	}
+	return result
+}
+
+// comparison tracks which of two clocks has seen a version the other has not.
+type comparison struct {
+	aFirst, bFirst bool
+}
+
+func (o *comparison) add(aValue, bValue uint64) {
+	if aValue < bValue {
+		o.aFirst = true
+	} else if bValue < aValue {
+		o.bFirst = true
+	}
+}
+
+func (o *comparison) result() Ordering {
+	switch {
+	case o.aFirst && o.bFirst:
+		return Concurrent
+	case o.aFirst:
+		return Before
+	case o.bFirst:
+		return After
+	default:
+		return Equal
 	}
 }
 
 func Order(a, b *Clock) Ordering {
-	var aFirst, bFirst bool
-	for key, aValue := range a.clock {
-		bValue := b.clock[key]
-		if aValue < bValue {
-			aFirst = true
-		} else if bValue < aValue {
-			bFirst = true
+	var o comparison
+	if a.table != b.table {
+		// Numbers mean different writers in different tables, so every writer is looked up.
+		writers := a.table.writers()
+		for _, e := range a.entries {
+			o.add(e.version, b.getVersion(writers[e.writer]))
+		}
+		writers = b.table.writers()
+		for _, e := range b.entries {
+			o.add(a.getVersion(writers[e.writer]), e.version)
 		}
+		return o.result()
 	}
 
-	for key, bValue := range b.clock {
-		if _, ok := a.clock[key]; ok {
-			continue
+	i, j := 0, 0
+	for i < len(a.entries) || j < len(b.entries) {
+		switch {
+		case j == len(b.entries) || i < len(a.entries) && a.entries[i].writer < b.entries[j].writer:
+			o.add(a.entries[i].version, 0)
+			i++
+		case i == len(a.entries) || b.entries[j].writer < a.entries[i].writer:
+			o.add(0, b.entries[j].version)
+			j++
+		default:
+			o.add(a.entries[i].version, b.entries[j].version)
+			i++
+			j++
 		}
-		if bValue > 0 {
-			aFirst = true
+		if o.aFirst && o.bFirst {
+			break
 		}
 	}
+	return o.result()
+}
 
-	if aFirst && !bFirst {
-		return Before
+// Dominates reports whether a has seen everything that b has.
+func Dominates(a, b *Clock) bool {
+	order := Order(a, b)
+	return order == After || order == Equal
+}
+
+func (c *Clock) getVersion(writer Incarnation) uint64 {
+	number, exists := c.table.lookup(writer)
+	if !exists {
+		return 0
 	}
-	if bFirst && !aFirst {
-		return After
+	return c.version(number)
+}
+
+func (c *Clock) version(number uint32) uint64 {
+	i, found := c.search(number)
+	if !found {
+		return 0
 	}
-	if bFirst && aFirst {
-		return Concurrent
+	return c.entries[i].version
+}
+
+func (c *Clock) search(number uint32) (int, bool) {
+	return slices.BinarySearchFunc(c.entries, number, func(e entry, number uint32) int {
+		return cmp.Compare(e.writer, number)
+	})
+}
+
+func (c *Clock) set(writer Incarnation, newVersion uint64) {
+	number := c.table.number(writer)
+	i, found := c.search(number)
+	if found {
+		c.entries[i].version = newVersion
+		return
 	}
+	c.entries = slices.Insert(c.entries, i, entry{writer: number, version: newVersion})
+}
 
-	return Equal
+func (c *Clock) clone() *Clock {
+	return &Clock{
+		table:   c.table,
+		entries: slices.Clone(c.entries),
+	}
 }
 
-func (c *Clock) getVersion(nodeId uint64) uint64 {
-	return c.clock[nodeId]
+// in returns a copy of c whose writers are numbered by table.
+func (c *Clock) in(table *writerTable) *Clock {
+	if c.table == table {
+		return c.clone()
+	}
+	return table.clockOf(c.Entries())
 }
 
-func (c *Clock) set(id, newVersion uint64) {
-	c.clock[id] = newVersion
+// visit calls f with every writer in the clock and its version.
+func (c *Clock) visit(f func(writer Incarnation, version uint64)) {
+	writers := c.table.writers()
+	for _, e := range c.entries {
+		f(writers[e.writer], e.version)
+	}
 }
 
 func (c *Clock) toString() string {
-	jsonData, err := json.Marshal(c.clock)
+	byWriter := map[string]uint64{}
+	c.visit(func(writer Incarnation, version uint64) {
+		byWriter[writer.String()] = version
+	})
+	jsonData, err := json.Marshal(byWriter)
 	if err != nil {
 		log.Fatal(fmt.Errorf("serializing clock: %w", err))
 	}
@@ -104,13 +261,49 @@ func (c *Clock) toString() string {
 	return string(jsonData)
 }
 
-func (c *Clock) Merge(remote *Clock) *Clock {
-	result := map[uint64]uint64{}
-	maps.Copy(result, c.clock)
-	for k, v := range remote.clock {
-		if existingVal, found := result[k]; !found || v > existingVal {
-			result[k] = v
+// Merge raises every version in c to at least its version in remote. It only allocates when
+// remote has writers that c has not seen.
+func (c *Clock) Merge(remote *Clock) {
+	if c.table != remote.table {
+		remote.visit(func(writer Incarnation, version uint64) {
+			if version > c.getVersion(writer) {
+				c.set(writer, version)
+			}
+		})
+		return
+	}
+
+	missing := 0
+	i := 0
+	for _, e := range remote.entries {
+		for i < len(c.entries) && c.entries[i].writer < e.writer {
+			i++
+		}
+		if i < len(c.entries) && c.entries[i].writer == e.writer {
+			c.entries[i].version = max(c.entries[i].version, e.version)
+		} else {
+			missing++
+		}
+	}
+	if missing == 0 {
+		return
+	}
+
+	// Fill in the missing writers from the back, so that every entry moves at most once.
+	i, j := len(c.entries)-1, len(remote.entries)-1
+	c.entries = slices.Grow(c.entries, missing)[:len(c.entries)+missing]
+	for k := len(c.entries) - 1; j >= 0; k-- {
+		switch {
+		case i >= 0 && c.entries[i].writer == remote.entries[j].writer:
+			c.entries[k] = c.entries[i]
+			i--
+			j--
+		case i >= 0 && c.entries[i].writer > remote.entries[j].writer:
+			c.entries[k] = c.entries[i]
+			i--
+		default:
+			c.entries[k] = remote.entries[j]
+			j--
 		}
 	}
-	return From(result)
 }
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/WadeCappa/consensus/internal/db"
 	"github.com/stretchr/testify/require"
@@ -101,3 +102,35 @@ func TestOrdering(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeKeepsTheNewestVersionOfEveryWriter(t *testing.T) {
+	clock := db.From(map[uint64]uint64{1: 4, 3: 1, 5: 2})
+	clock.Merge(db.From(map[uint64]uint64{0: 7, 1: 2, 2: 3, 3: 5, 6: 1}))
+	require.Equal(t, map[db.Incarnation]uint64{
+		{NodeId: 0}: 7,
+		{NodeId: 1}: 4,
+		{NodeId: 2}: 3,
+		{NodeId: 3}: 5,
+		{NodeId: 5}: 2,
+		{NodeId: 6}: 1,
+	}, clock.Entries())
+	require.Equal(t, db.Equal, db.Order(clock, db.FromWireType(clock.ToWireType())))
+}
+
+func TestDatabaseClocksCompareWithClocksBuiltElsewhere(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 9, Epoch: 1})
+	require.NoError(t, data.Merge("key", db.From(map[uint64]uint64{2: 3, 1: 1}), []*db.Chunk{
+		db.NewChunk(1, 1, time.Now(), []byte("a")),
+		db.NewChunk(2, 3, time.Now(), []byte("b")),
+	}))
+	require.NoError(t, data.Put("key", []byte("c"), time.Now(), nil))
+	record, _, err := data.Get("key")
+	require.NoError(t, err)
+
+	merged := db.From(map[uint64]uint64{1: 1, 2: 3})
+	merged.Merge(record.Clock)
+	require.Equal(t, db.Equal, db.Order(record.Clock, merged))
+	require.Equal(t, db.After, db.Order(record.Clock, db.From(map[uint64]uint64{1: 1, 2: 3})))
+	require.Equal(t, db.Concurrent, db.Order(record.Clock, db.From(map[uint64]uint64{1: 2})))
+	require.Len(t, record.GetChunksSince(db.From(map[uint64]uint64{1: 1, 2: 2})), 2)
+}
@@ -1,72 +1,577 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/hlc"
 )
 
+// Database stores every record as an immutable version. A change builds a new version from a
+// copy of the current one and replaces it under its shard's lock, so a record handed out by
+// Get or a range stays a consistent point-in-time view of its key without holding the lock.
 type Database struct {
-	lock    sync.Mutex
-	data    map[string]*Record
-	localId uint64
+	shards []*shard
+	local  Incarnation
+	dir    string
+	// Every record clock numbers its writers in this table.
+	writers *writerTable
+	// Stamps local chunks with the time they are written at.
+	clock   *hlc.Clock
+	tree    *merkleTree
+	changes *changeIndex
+
+	// Swapping the log for the next segment takes every shard's lock. Appends from
+	// different shards are synced together, see wal.
+	log     *wal
+	segment uint64
+	// Serializes snapshots, which write their file after releasing the shard locks.
+	snapshotLock sync.Mutex
+
+	// Guards watchers and changes to listening. Writers count watchers without it.
+	watchLock sync.Mutex
+	watchers  map[*Watcher]struct{}
+	watching  atomic.Int64
+	listening atomic.Pointer[[]*ChangeListener]
+}
+
+var _ Storage = (*Database)(nil)
+
+var (
+	ErrClockMismatch = errors.New("record clock does not match the expected clock")
+	ErrNotFound      = errors.New("record not found")
+
+	errPageFull = errors.New("page full")
+)
+
+// Replication refuses chunks stamped more than this far ahead of our clock. See CheckDrift.
+const maxClockDrift = time.Second
+
+// Ranging over the database collects this many records at a time from each shard while
+// holding its lock.
+const rangePageSize = 256
+
+type keyedRecord struct {
+	key    string
+	record *Record
 }
 
-func NewDatabase(localId uint64) *Database {
+// NewDatabase returns a database whose local writes are attributed to local.
+func NewDatabase(local Incarnation) *Database {
+	return newDatabase(local, memoryShards(nil))
+}
+
+// NewDatabaseWithEngine returns a database backed by engine, which may already hold records.
+// Its shards all share engine, so only the memory engine that NewDatabase uses gets the full
+// benefit of sharding.
+func NewDatabaseWithEngine(local Incarnation, engine Engine) (*Database, error) {
+	d := newDatabase(local, sharedShards(engine))
+	if decoding, ok := engine.(decodingEngine); ok {
+		decoding.useWriters(d.writers)
+	}
+	if err := d.rebuildIndexes(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func newDatabase(local Incarnation, shards []*shard) *Database {
 	return &Database{
-		data:    map[string]*Record{},
-		lock:    sync.Mutex{},
-		localId: localId,
+		shards:  shards,
+		local:   local,
+		writers: newWriterTable(),
+		clock:   hlc.NewClock(),
+		tree:    newMerkleTree(),
+		changes: newChangeIndex(),
 	}
 }
 
-func (d *Database) Get(key string) (*Record, bool) {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	result, exists := d.data[key]
-	if !exists {
-		return nil, false
+// OpenDatabase rebuilds a database from the newest readable snapshot in dataDir and the
+// log segments written after it, then appends every subsequent Put and Merge to the
+// current segment before applying it.
+func OpenDatabase(local Incarnation, dataDir string) (*Database, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	d := NewDatabase(local)
+	d.dir = dataDir
+
+	snapshots, err := listSequences(dataDir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
 	}
-	return result, true
+	var covered uint64
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		records, err := loadSnapshot(filepath.Join(dataDir, snapshotName(snapshots[i])))
+		if err != nil {
+			fmt.Printf("skipping unreadable snapshot %d: %s\n", snapshots[i], err.Error())
+			continue
+		}
+		for _, record := range records {
+			record.Clock = record.Clock.in(d.writers)
+		}
+		d.shards = memoryShards(records)
+		covered = snapshots[i]
+		break
+	}
+	if err := d.rebuildIndexes(); err != nil {
+		return nil, err
+	}
+
+	segments, err := listSequences(dataDir, walPrefix, walSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("listing log segments: %w", err)
+	}
+	d.segment = max(covered, 1)
+	for _, seq := range segments {
+		if seq < covered {
+			continue
+		}
+		if err := replayWal(filepath.Join(dataDir, segmentName(seq)), d.replay); err != nil {
+			return nil, fmt.Errorf("replaying log segment %d: %w", seq, err)
+		}
+		d.segment = seq
+	}
+
+	log, err := openWal(filepath.Join(dataDir, segmentName(d.segment)))
+	if err != nil {
+		return nil, fmt.Errorf("opening write-ahead log: %w", err)
+	}
+	d.log = log
+	return d, nil
 }
 
-func (d *Database) Put(key string, update []byte, updateTime time.Time) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	prev, exists := d.data[key]
-	if !exists {
-		clock := newClock(d.localId, 1)
-		chunk := NewChunk(d.localId, 1, updateTime, update)
-		d.data[key] = FromChunk(clock, chunk)
+func (d *Database) Close() error {
+	// A snapshot underway finishes first.
+	d.snapshotLock.Lock()
+	defer d.snapshotLock.Unlock()
+	d.lockAll()
+	defer d.unlockAll()
+	if d.log != nil {
+		if err := d.log.close(); err != nil {
+			return fmt.Errorf("closing write-ahead log: %w", err)
+		}
+		d.log = nil
+	}
+	d.watchLock.Lock()
+	for w := range d.watchers {
+		d.removeWatcher(w, nil)
+	}
+	d.watchLock.Unlock()
+	for _, s := range d.partitions() {
+		if err := s.engine.Close(); err != nil {
+			return fmt.Errorf("closing storage engine: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *Database) Get(key string) (*Record, bool, error) {
+	result, exists, err := d.shardFor(key).get(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading record: %w", err)
+	}
+	return result, exists, nil
+}
+
+func (d *Database) Put(key string, update []byte, updateTime time.Time, expected *Clock) error {
+	s := d.shardFor(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prev, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if expected != nil {
+		current := EmptyClock()
+		if exists {
+			current = prev.Clock
+		}
+		// Callers may have read the record before or after its epochs were folded.
+		if Order(current, expected) != Equal && (!exists || Order(prev.unfold(), expected) != Equal) {
+			return fmt.Errorf("%w: expected %s but found %s", ErrClockMismatch, expected.toString(), current.toString())
+		}
+	}
+	version := uint64(1)
+	if exists {
+		version = prev.GetWriterVersion(d.local) + 1
+	}
+
+	return d.appendChunk(s, key, NewChunkFrom(d.local, version, updateTime, update))
+}
+
+// Delete appends a tombstone to the record at key. The tombstone replicates like any
+// other chunk and hides all data written before it.
+func (d *Database) Delete(key string, deleteTime time.Time) error {
+	s := d.shardFor(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prev, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists || len(prev.Live()) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return d.appendChunk(s, key, NewTombstoneFrom(d.local, prev.GetWriterVersion(d.local)+1, deleteTime))
+}
+
+// appendChunk stamps chunk with the hybrid clock, taking the time it was written at as the
+// physical time, and applies it.
+func (d *Database) appendChunk(s *shard, key string, chunk *Chunk) error {
+	chunk.writeTime = d.clock.Now(chunk.writeTime.Time())
+	if err := d.persist(putEntry, key, nil, []*Chunk{chunk}); err != nil {
+		return fmt.Errorf("persisting chunk: %w", err)
+	}
+	return d.applyPut(s, key, chunk)
+}
+
+// CollectTombstones drops tombstones, and the chunks they hide, from every record that
+// acked reports has been acknowledged by every member of the cluster. The record's clock is kept so
+// that replicas still holding the deleted chunks cannot resurrect them.
+func (d *Database) CollectTombstones(acked func(key string, record *Record) bool) error {
+	collected := map[string]*Record{}
+	if err := d.Range(func(key string, record *Record) error {
+		if record.lastTombstone() >= 0 && acked(key, record) {
+			collected[key] = record
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("finding tombstones: %w", err)
+	}
+	for key, record := range collected {
+		if err := d.collect(key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FoldEpochs folds the clock entries of writers that a newer epoch of the same node has
+// replaced into the chunks they wrote, in every record that acked reports has been
+// acknowledged by every member of the cluster. Once they have all seen the new epoch, the old one writes
+// nothing more, so its entries only take up space. See Record.fold.
+func (d *Database) FoldEpochs(acked func(key string, record *Record) bool) error {
+	folded := map[string]*Record{}
+	if err := d.Range(func(key string, record *Record) error {
+		if record.fold() != nil && acked(key, record) {
+			folded[key] = record
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("finding replaced epochs: %w", err)
+	}
+	for key, record := range folded {
+		if err := d.fold(key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fold folds the epochs of the record at key, unless it changed since it was found to be
+// acknowledged. Folding changes how the record is stored but not what it holds, so it is
+// not logged and leaves the Merkle tree as it is.
+func (d *Database) fold(key string, acked *Record) error {
+	s := d.shardFor(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	record, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists || Order(record.Clock, acked.Clock) != Equal {
+		return nil
+	}
+	folded := record.fold()
+	if folded == nil {
+		return nil
+	}
+	if err := s.engine.Set(key, folded); err != nil {
+		return fmt.Errorf("storing folded record: %w", err)
+	}
+	return nil
+}
+
+// collect drops the tombstones of the record at key, unless it changed since it was found
+// to be acknowledged. Collecting is logged, so that replaying the log does not bring back
+// what was collected.
+func (d *Database) collect(key string, acked *Record) error {
+	s := d.shardFor(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	record, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists || Order(record.Clock, acked.Clock) != Equal {
 		return nil
 	}
+	// Folding is not logged, so the log holds the unfolded clock that replay will find.
+	clock := record.unfold()
+	if err := d.persist(collectEntry, key, clock, nil); err != nil {
+		return fmt.Errorf("persisting collection: %w", err)
+	}
+	return d.applyCollect(s, key, clock)
+}
 
-	prev.Update(d.localId, prev.GetVersion(d.localId)+1, updateTime, update)
+func (d *Database) applyCollect(s *shard, key string, clock *Clock) error {
+	record, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	// The collected chunks may hold entries that were folded into them, which the clock
+	// must keep.
+	unfolded := record.unfold()
+	if Order(unfolded, clock) != Equal {
+		return nil
+	}
+	live := NewRecord(unfolded, slices.Clone(record.Live()))
+	if err := s.engine.Set(key, live); err != nil {
+		return fmt.Errorf("storing collected record: %w", err)
+	}
 	return nil
 }
 
+// Range visits every record in lexicographic key order. See RangeFrom.
 func (d *Database) Range(consumer func(key string, record *Record) error) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	for key, val := range d.data {
-		if err := consumer(key, val); err != nil {
+	return d.RangeFrom("", consumer)
+}
+
+// RangeFrom visits every record whose key is at or after start, in lexicographic key order.
+// Records are collected a page at a time and handed to consumer without holding the lock,
+// so a slow consumer does not hold up writers. A record changed while the range is underway
+// is visited in whichever version was current when its page was collected.
+func (d *Database) RangeFrom(start string, consumer func(key string, record *Record) error) error {
+	for {
+		page, err := d.pageFrom(start)
+		if err != nil {
+			return fmt.Errorf("consuming db rows: %w", err)
+		}
+		if err := visit(page, consumer); err != nil {
 			return fmt.Errorf("consuming db rows: %w", err)
 		}
+		if len(page) < rangePageSize {
+			return nil
+		}
+		// The smallest key after the last one visited.
+		start = page[len(page)-1].key + "\x00"
+	}
+}
+
+// rangeKeys visits the records at keys, collecting them a page at a time like RangeFrom.
+// Keys that no longer exist are skipped.
+func (d *Database) rangeKeys(keys []string, consumer func(key string, record *Record) error) error {
+	for len(keys) > 0 {
+		n := min(len(keys), rangePageSize)
+		page, err := d.pageOf(keys[:n])
+		if err != nil {
+			return err
+		}
+		if err := visit(page, consumer); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+func (d *Database) pageOf(keys []string) ([]keyedRecord, error) {
+	page := make([]keyedRecord, 0, len(keys))
+	for _, key := range keys {
+		record, exists, err := d.shardFor(key).get(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading record: %w", err)
+		}
+		if exists {
+			page = append(page, keyedRecord{key: key, record: record})
+		}
+	}
+	return page, nil
+}
+
+func visit(page []keyedRecord, consumer func(key string, record *Record) error) error {
+	for _, r := range page {
+		if err := consumer(r.key, r.record); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// Digests returns the digest of each of the given nodes of the Merkle tree over the clocks
+// of every key. It does not wait for the database lock, so it may be called while ranging
+// over the database.
+func (d *Database) Digests(nodes []uint32) ([]uint64, error) {
+	return d.tree.digestsOf(nodes)
+}
+
+// RangeLeaves visits every record under the given leaves of the Merkle tree, in lexicographic
+// key order.
+func (d *Database) RangeLeaves(leaves []uint32, consumer func(key string, record *Record) error) error {
+	keys, err := d.tree.keysIn(leaves)
+	if err != nil {
+		return err
+	}
+	return d.rangeKeys(keys, consumer)
+}
+
+// Changes visits every record modified after the change numbered since, in the order they
+// were last modified, and returns the number of the latest change. Passing that number to
+// the next call visits only what changed in between. Numbers start over when the database is
+// reopened, so callers must start from 0 again after a restart.
+func (d *Database) Changes(since uint64, consumer func(key string, record *Record) error) (uint64, error) {
+	keys, latest := d.changes.since(since)
+	if err := d.rangeKeys(keys, consumer); err != nil {
+		return 0, err
+	}
+	return latest, nil
+}
+
+func (d *Database) rebuildIndexes() error {
+	d.tree = newMerkleTree()
+	d.changes = newChangeIndex()
+	if err := d.rangeAll(func(key string, record *Record) error {
+		d.tree.update(key, record.unfold())
+		d.changes.record(key)
+		d.observe(record.Chunks)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("indexing records: %w", err)
+	}
+	return nil
+}
+
+// FromWireType builds a clock whose writers are numbered in the same table as the clocks of
+// the database's records, so that comparing or merging it with them looks nothing up.
+func (d *Database) FromWireType(clock *clockspb.VectorClock) *Clock {
+	return d.writers.clockOf(wireEntries(clock))
+}
+
+// FromEntries builds a clock numbered like the clocks of the database's records. See
+// FromWireType.
+func (d *Database) FromEntries(entries map[Incarnation]uint64) *Clock {
+	return d.writers.clockOf(entries)
+}
+
+// CheckDrift refuses chunks stamped more than maxClockDrift ahead of our physical clock, so
+// that a peer whose clock runs fast cannot drag ours along with it. Replication checks what
+// it receives before merging it. Raft's log is not checked, since applying it must not
+// depend on the local time.
+func CheckDrift(chunks []*Chunk) error {
+	now := time.Now()
+	for _, c := range chunks {
+		if err := hlc.CheckDrift(c.writeTime, now, maxClockDrift); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge applies a remote version of the record at key.
 func (d *Database) Merge(key string, remoteClock *Clock, chunks []*Chunk) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	record, exists := d.data[key]
-	if !exists {
-		d.data[key] = NewRecord(remoteClock, chunks)
+	s := d.shardFor(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := d.persist(mergeEntry, key, remoteClock, chunks); err != nil {
+		return fmt.Errorf("persisting merge: %w", err)
+	}
+	return d.applyMerge(s, key, remoteClock, chunks)
+}
+
+// persist appends a change to the log. The caller must hold the lock of the key's shard.
+func (d *Database) persist(kind entryKind, key string, clock *Clock, chunks []*Chunk) error {
+	if d.log == nil {
+		return nil
+	}
+	return d.log.append(kind, key, clock, chunks)
+}
+
+func (d *Database) replay(kind entryKind, msg *clockspb.PublishRequest) error {
+	chunks := ChunksFromWireType(msg.GetChunks())
+	switch kind {
+	case putEntry:
+		for _, c := range chunks {
+			if err := d.applyPut(d.shardFor(msg.GetKey()), msg.GetKey(), c); err != nil {
+				return err
+			}
+		}
 		return nil
+	case mergeEntry:
+		return d.applyMerge(d.shardFor(msg.GetKey()), msg.GetKey(), d.FromWireType(msg.GetClock()), chunks)
+	case collectEntry:
+		return d.applyCollect(d.shardFor(msg.GetKey()), msg.GetKey(), d.FromWireType(msg.GetClock()))
+	default:
+		return fmt.Errorf("unrecognized entry kind %d", kind)
+	}
+}
+
+// observe moves our clock past chunks, so that local writes are ordered after everything
+// they could have read, including what we wrote before restarting.
+func (d *Database) observe(chunks []*Chunk) {
+	for _, c := range chunks {
+		d.clock.Observe(c.writeTime)
+	}
+}
+
+func (d *Database) applyPut(s *shard, key string, chunk *Chunk) error {
+	record, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists {
+		record = FromChunk(newClock(d.writers, chunk.writer, chunk.version), chunk)
+	} else {
+		record = record.clone()
+		record.insert(chunk)
+	}
+
+	if err := s.engine.Set(key, record); err != nil {
+		return fmt.Errorf("storing record: %w", err)
+	}
+	d.observe([]*Chunk{chunk})
+	d.tree.update(key, record.unfold())
+	d.changes.record(key)
+	d.announce(key, []*Chunk{chunk})
+	return nil
+}
+
+func (d *Database) applyMerge(s *shard, key string, remoteClock *Clock, chunks []*Chunk) error {
+	record, exists, err := s.engine.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	// The record takes ownership of the clock it merges, which the caller may still hold.
+	remoteClock = remoteClock.in(d.writers)
+	previousClock := EmptyClock()
+	if !exists {
+		record = NewRecord(remoteClock, slices.Clone(chunks))
+	} else {
+		// Clocks are merged entry by entry, so entries folded into chunks are read back first.
+		previousClock = record.unfold()
+		record = NewRecord(previousClock.clone(), slices.Clone(record.Chunks))
+		if err := record.Merge(remoteClock, chunks); err != nil {
+			return fmt.Errorf("merging remote data with local data: %w", err)
+		}
 	}
 
-	if err := record.Merge(remoteClock, chunks); err != nil {
-		return fmt.Errorf("merging remote data with local data: %w", err)
+	if err := s.engine.Set(key, record); err != nil {
+		return fmt.Errorf("storing record: %w", err)
 	}
+	d.observe(chunks)
+	d.tree.update(key, record.Clock)
+	d.changes.record(key)
+	d.announce(key, record.GetChunksSince(previousClock))
 	return nil
 }
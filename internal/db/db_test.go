@@ -0,0 +1,551 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/hlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReopenedDatabaseKeepsRecords(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, data.Put("local-key", []byte("first"), time.Now(), nil))
+	require.NoError(t, data.Put("local-key", []byte("second"), time.Now(), nil))
+	remoteClock := db.From(map[uint64]uint64{
+		testNodeId + 1: 1,
+	})
+	remoteChunks := []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, time.Now(), []byte("remote")),
+	}
+	require.NoError(t, data.Merge("remote-key", remoteClock, remoteChunks))
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	local, exists, err := reopened.Get("local-key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("firstsecond"), db.Concat(local.Chunks))
+	require.Equal(t, uint64(2), local.GetVersion(testNodeId))
+
+	remote, exists, err := reopened.Get("remote-key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("remote"), db.Concat(remote.Chunks))
+	require.Equal(t, db.Equal, db.Order(remoteClock, remote.Clock))
+
+	// The local version counter must carry on from where it left off, otherwise peers
+	// would discard this write as one they have already seen.
+	require.NoError(t, reopened.Put("local-key", []byte("third"), time.Now(), nil))
+	local, exists, err = reopened.Get("local-key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, uint64(3), local.GetVersion(testNodeId))
+}
+
+func TestReopenedDatabaseDropsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	require.NoError(t, data.Put("key", []byte("kept"), time.Now(), nil))
+	require.NoError(t, data.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	logFile, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = logFile.Write([]byte{1, 200, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, logFile.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Put("key", []byte("-appended"), time.Now(), nil))
+	require.NoError(t, reopened.Close())
+
+	reopened, err = db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	record, exists, err := reopened.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("kept-appended"), db.Concat(record.Chunks))
+}
+
+func TestReopenedDatabaseRefusesCorruptLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(entry []byte)
+	}{
+		{
+			name: "checksum_mismatch",
+			corrupt: func(entry []byte) {
+				entry[len(entry)-1] ^= 0xff
+			},
+		},
+		{
+			name: "oversized_length",
+			corrupt: func(entry []byte) {
+				entry[4] = 0xff
+			},
+		},
+		{
+			// The length still fits under the maximum, but runs past the end of the file.
+			name: "length_past_end",
+			corrupt: func(entry []byte) {
+				entry[3] ^= 0x10
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			data, err := db.OpenDatabase(testWriter, dir)
+			require.NoError(t, err)
+			require.NoError(t, data.Put("key", []byte("first"), time.Now(), nil))
+			segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+			require.NoError(t, err)
+			require.Len(t, segments, 1)
+			info, err := os.Stat(segments[0])
+			require.NoError(t, err)
+			require.NoError(t, data.Put("key", []byte("second"), time.Now(), nil))
+			require.NoError(t, data.Close())
+
+			// Corrupt the first entry, which has been followed by another since.
+			contents, err := os.ReadFile(segments[0])
+			require.NoError(t, err)
+			test.corrupt(contents[:info.Size()])
+			require.NoError(t, os.WriteFile(segments[0], contents, 0o644))
+
+			_, err = db.OpenDatabase(testWriter, dir)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestConcurrentWritesShareLogSyncs(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, data.Put(fmt.Sprintf("key-%d", i), []byte("data"), time.Now(), nil))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	for i := range 32 {
+		record, exists, err := reopened.Get(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.Equal(t, []byte("data"), db.Concat(record.Chunks))
+	}
+}
+
+func TestSnapshotReplacesCoveredHistory(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, data.Put("key", []byte("before"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(1))
+	require.NoError(t, data.Put("key", []byte("-between"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(1))
+	require.NoError(t, data.Put("key", []byte("-after"), time.Now(), nil))
+	require.NoError(t, data.Close())
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, "snapshot-*.snap"))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	record, exists, err := reopened.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("before-between-after"), db.Concat(record.Chunks))
+	require.Equal(t, uint64(3), record.GetVersion(testNodeId))
+}
+
+func TestWritesDuringSnapshotsSurviveReopen(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 200 {
+			assert.NoError(t, data.Put(fmt.Sprintf("key-%d", i), []byte("x"), time.Now(), nil))
+		}
+	})
+	for range 5 {
+		require.NoError(t, data.Snapshot(2))
+	}
+	wg.Wait()
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	for i := range 200 {
+		_, exists, err := reopened.Get(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		require.True(t, exists, "key-%d", i)
+	}
+}
+
+func TestSnapshotFallsBackToOlderRetainedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, data.Put("key", []byte("a"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(2))
+	require.NoError(t, data.Put("key", []byte("b"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(2))
+	require.NoError(t, data.Put("key", []byte("c"), time.Now(), nil))
+	require.NoError(t, data.Close())
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, "snapshot-*.snap"))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.NoError(t, os.WriteFile(snapshots[1], []byte("corrupt"), 0o644))
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	record, exists, err := reopened.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("abc"), db.Concat(record.Chunks))
+}
+
+func TestConditionalPut(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+
+	require.ErrorIs(t, data.Put("key", []byte("stale"), time.Now(), db.From(map[uint64]uint64{
+		testNodeId: 1,
+	})), db.ErrClockMismatch)
+	require.NoError(t, data.Put("key", []byte("first"), time.Now(), db.EmptyClock()))
+
+	expected := db.From(map[uint64]uint64{
+		testNodeId: 1,
+	})
+	require.NoError(t, data.Put("key", []byte("-second"), time.Now(), expected))
+
+	// The clock has moved on to version 2, so a writer still holding version 1 loses.
+	require.ErrorIs(t, data.Put("key", []byte("-lost"), time.Now(), expected), db.ErrClockMismatch)
+
+	record, exists, err := data.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("first-second"), db.Concat(record.Chunks))
+}
+
+func TestDeleteHidesChunksUntilCollected(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	require.ErrorIs(t, data.Delete("key", time.Now()), db.ErrNotFound)
+
+	start := time.Now()
+	require.NoError(t, data.Put("key", []byte("deleted"), start, nil))
+	require.NoError(t, data.Delete("key", start.Add(time.Millisecond)))
+	require.ErrorIs(t, data.Delete("key", start.Add(time.Millisecond*2)), db.ErrNotFound)
+
+	record, exists, err := data.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Empty(t, record.Live())
+	require.Len(t, record.Chunks, 2)
+
+	require.NoError(t, data.CollectTombstones(func(key string, record *db.Record) bool {
+		return false
+	}))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Len(t, record.Chunks, 2)
+
+	require.NoError(t, data.CollectTombstones(func(key string, record *db.Record) bool {
+		return true
+	}))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Empty(t, record.Chunks)
+
+	// A peer that never saw the delete must not be able to bring the old chunk back.
+	require.NoError(t, data.Merge("key", db.From(map[uint64]uint64{
+		testNodeId: 1,
+	}), []*db.Chunk{
+		db.NewChunk(testNodeId, 1, start, []byte("deleted")),
+	}))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Empty(t, record.Live())
+
+	require.NoError(t, data.Put("key", []byte("recreated"), start.Add(time.Millisecond*3), nil))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("recreated"), db.Concat(record.Live()))
+	require.Equal(t, uint64(3), record.GetVersion(testNodeId))
+}
+
+func TestCollectedTombstonesStayCollectedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, data.Put("key", []byte("deleted"), start, nil))
+	require.NoError(t, data.Delete("key", start.Add(time.Millisecond)))
+	require.NoError(t, data.Put("key", []byte("kept"), start.Add(time.Millisecond*2), nil))
+	require.NoError(t, data.CollectTombstones(func(key string, record *db.Record) bool {
+		return true
+	}))
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	record, exists, err := reopened.Get("key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Len(t, record.Chunks, 1)
+	require.Equal(t, []byte("kept"), db.Concat(record.Chunks))
+	require.Equal(t, uint64(3), record.GetWriterVersion(testWriter))
+}
+
+func TestIncarnationIsStableUntilDataIsLost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node")
+	incarnation, err := db.LoadIncarnation(dir, 0)
+	require.NoError(t, err)
+	require.NotZero(t, incarnation.NodeId)
+	require.NotZero(t, incarnation.Epoch)
+
+	reloaded, err := db.LoadIncarnation(dir, 0)
+	require.NoError(t, err)
+	require.Equal(t, incarnation, reloaded)
+
+	_, err = db.LoadIncarnation(dir, incarnation.NodeId+1)
+	require.Error(t, err)
+
+	// The same node coming back without its data directory starts a new epoch.
+	time.Sleep(time.Millisecond * 2)
+	wiped, err := db.LoadIncarnation(t.TempDir(), incarnation.NodeId)
+	require.NoError(t, err)
+	require.Equal(t, incarnation.NodeId, wiped.NodeId)
+	require.Greater(t, wiped.Epoch, incarnation.Epoch)
+}
+
+func TestNewEpochIsANewWriter(t *testing.T) {
+	before := db.NewIncarnation(testNodeId)
+	original := db.NewDatabase(before)
+	require.NoError(t, original.Put("key", []byte("old"), time.Now(), nil))
+	require.NoError(t, original.Put("key", []byte("-older"), time.Now(), nil))
+	oldRecord, _, err := original.Get("key")
+	require.NoError(t, err)
+
+	// The node lost its state, so its versions count up from 1 again.
+	after := db.Incarnation{NodeId: testNodeId, Epoch: before.Epoch + 1}
+	restarted := db.NewDatabase(after)
+	require.NoError(t, restarted.Put("key", []byte("new"), time.Now(), nil))
+	newRecord, _, err := restarted.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), newRecord.GetWriterVersion(after))
+
+	// Peers that saw the old incarnation still accept writes from the new one.
+	require.NoError(t, original.Merge("key", newRecord.Clock, newRecord.Chunks))
+	merged, _, err := original.Get("key")
+	require.NoError(t, err)
+	require.Len(t, merged.Chunks, 3)
+	require.Equal(t, uint64(2), merged.GetWriterVersion(before))
+	require.Equal(t, uint64(1), merged.GetWriterVersion(after))
+
+	require.NoError(t, restarted.Merge("key", oldRecord.Clock, oldRecord.Chunks))
+	merged, _, err = restarted.Get("key")
+	require.NoError(t, err)
+	require.Len(t, merged.Chunks, 3)
+}
+
+func TestFoldEpochsDropsReplacedIncarnationsOnceAcknowledged(t *testing.T) {
+	before := db.Incarnation{NodeId: testNodeId, Epoch: 1}
+	after := db.Incarnation{NodeId: testNodeId, Epoch: 2}
+	start := time.Now()
+	old := []*db.Chunk{db.NewChunkFrom(before, 1, start, []byte("old"))}
+	oldClock := db.FromEntries(map[db.Incarnation]uint64{before: 1})
+	newer := []*db.Chunk{db.NewChunkFrom(after, 1, start.Add(time.Millisecond), []byte("-new"))}
+	newClock := db.FromEntries(map[db.Incarnation]uint64{before: 1, after: 1})
+
+	data := db.NewDatabase(db.Incarnation{NodeId: testNodeId + 1})
+	require.NoError(t, data.Merge("key", oldClock, old))
+	require.NoError(t, data.Merge("key", newClock, newer))
+	digests, err := data.Digests([]uint32{0})
+	require.NoError(t, err)
+
+	require.NoError(t, data.FoldEpochs(func(key string, record *db.Record) bool {
+		return false
+	}))
+	record, _, err := data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, map[db.Incarnation]uint64{before: 1, after: 1}, record.Clock.Entries())
+
+	require.NoError(t, data.FoldEpochs(func(key string, record *db.Record) bool {
+		return true
+	}))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, map[db.Incarnation]uint64{after: 1}, record.Clock.Entries())
+	require.Equal(t, uint64(1), record.GetWriterVersion(before))
+	require.Equal(t, []byte("old-new"), db.Concat(record.Live()))
+	folded, err := data.Digests([]uint32{0})
+	require.NoError(t, err)
+	require.Equal(t, digests, folded)
+
+	// Peers that have not folded yet still send the old entries, which are not merged twice.
+	require.NoError(t, data.Merge("key", newClock, slices.Concat(old, newer)))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("old-new"), db.Concat(record.Live()))
+
+	// Clocks handed out before folding are still satisfied.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, data.FoldEpochs(func(key string, record *db.Record) bool {
+		return true
+	}))
+	_, _, err = db.AwaitClock(ctx, data, "key", newClock)
+	require.NoError(t, err)
+	require.NoError(t, data.Put("key", []byte("-cas"), time.Now(), newClock))
+
+	// Collecting the chunks that entries were folded into brings the entries back.
+	require.NoError(t, data.Delete("key", time.Now()))
+	require.NoError(t, data.CollectTombstones(func(key string, record *db.Record) bool {
+		return true
+	}))
+	record, _, err = data.Get("key")
+	require.NoError(t, err)
+	require.Empty(t, record.Chunks)
+	require.Equal(t, uint64(1), record.Clock.Entries()[before])
+}
+
+func TestRangeDoesNotBlockWriters(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	for i := range 1000 {
+		require.NoError(t, data.Put(fmt.Sprintf("key-%04d", i), []byte("x"), time.Now(), nil))
+	}
+
+	// Writing from inside the consumer would deadlock if ranging held the lock.
+	var visited []string
+	require.NoError(t, data.Range(func(key string, record *db.Record) error {
+		visited = append(visited, key)
+		return data.Put(key, []byte("y"), time.Now(), nil)
+	}))
+	require.Len(t, visited, 1000)
+	require.True(t, slices.IsSorted(visited))
+
+	var changed int
+	_, err := data.Changes(0, func(key string, record *db.Record) error {
+		changed++
+		require.NoError(t, data.Delete(key, time.Now()))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1000, changed)
+}
+
+func TestRangeVisitsRecordsAsCopies(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	require.NoError(t, data.Put("key", []byte("a"), time.Now(), nil))
+	var seen *db.Record
+	require.NoError(t, data.Range(func(key string, record *db.Record) error {
+		seen = record
+		return nil
+	}))
+	require.NoError(t, data.Put("key", []byte("b"), time.Now(), nil))
+	require.Equal(t, "a", string(db.Concat(seen.Chunks)))
+	require.Equal(t, uint64(1), seen.GetWriterVersion(testWriter))
+}
+
+func TestLocalWritesAreOrderedAfterMergedChunks(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	// The remote node's clock runs ahead of ours, but not by so much that we refuse it.
+	ahead := time.Now().Add(time.Millisecond * 500)
+	require.NoError(t, data.Merge("key", db.From(map[uint64]uint64{testNodeId + 1: 1}), []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, ahead, []byte("remote")),
+	}))
+	require.NoError(t, data.Put("key", []byte("local"), time.Now(), nil))
+	require.NoError(t, data.Put("key", []byte("again"), time.Now(), nil))
+
+	record, _, err := data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("remotelocalagain"), db.Concat(record.Live()))
+}
+
+func TestCheckDriftRefusesChunksFromTooFarAhead(t *testing.T) {
+	ahead := []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, time.Now(), []byte("fine")),
+		db.NewChunk(testNodeId+1, 2, time.Now().Add(time.Hour), []byte("remote")),
+	}
+	require.ErrorIs(t, db.CheckDrift(ahead), hlc.ErrClockDrift)
+	require.NoError(t, db.CheckDrift(ahead[:1]))
+
+	// Merging does not check, since Raft applies its log through it regardless of local time.
+	data := db.NewDatabase(testWriter)
+	require.NoError(t, data.Merge("key", db.From(map[uint64]uint64{testNodeId + 1: 2}), ahead))
+	require.NoError(t, data.Put("key", []byte("local"), time.Now(), nil))
+	record, _, err := data.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("fineremotelocal"), db.Concat(record.Live()))
+}
+
+func TestClockIsRestoredAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	// Adopting a remote time ahead of ours stamps later local writes ahead too.
+	ahead := time.Now().Add(time.Millisecond * 900)
+	require.NoError(t, data.Merge("key", db.From(map[uint64]uint64{testNodeId + 1: 1}), []*db.Chunk{
+		db.NewChunk(testNodeId+1, 1, ahead, []byte("remote")),
+	}))
+	require.NoError(t, data.Put("key", []byte("local"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(1))
+	require.NoError(t, data.Put("other", []byte("logged"), time.Now(), nil))
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.NoError(t, reopened.Delete("key", time.Now()))
+	require.NoError(t, reopened.Put("other", []byte("-again"), time.Now(), nil))
+
+	record, _, err := reopened.Get("key")
+	require.NoError(t, err)
+	require.Empty(t, record.Live())
+	record, _, err = reopened.Get("other")
+	require.NoError(t, err)
+	require.Equal(t, []byte("logged-again"), db.Concat(record.Live()))
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
+)
+
+// Rewrite the data file once it holds more than this many bytes of superseded records and
+// those outweigh the live ones.
+const compactionThreshold = 1 << 20
+
+type location struct {
+	offset int64
+	size   int64
+}
+
+// fileEngine keeps every record in an append-only data file. Only the sorted key index and
+// the location of each key's latest record are held in memory, records are read back from
+// disk on demand.
+type fileEngine struct {
+	path    string
+	file    *os.File
+	keys    []string
+	index   map[string]location
+	end     int64
+	garbage int64
+	// Clocks read back from disk number their writers here.
+	writers *writerTable
+}
+
+func OpenFileEngine(path string) (Engine, error) {
+	e := &fileEngine{
+		path:    path,
+		writers: sharedWriters,
+	}
+	if err := e.load(); err != nil {
+		return nil, fmt.Errorf("loading data file: %w", err)
+	}
+	return e, nil
+}
+
+func (e *fileEngine) load() error {
+	file, err := os.OpenFile(e.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening data file: %w", err)
+	}
+
+	e.file = file
+	e.keys = nil
+	e.index = map[string]location{}
+	e.end = 0
+	e.garbage = 0
+
+	return scanEntries(file, func(kind entryKind, msg *clockspb.PublishRequest, offset, size int64) error {
+		if kind != recordEntry {
+			return fmt.Errorf("unexpected entry kind %d at offset %d", kind, offset)
+		}
+		e.track(msg.GetKey(), location{offset: offset, size: size})
+		e.end = offset + size
+		return nil
+	})
+}
+
+func (e *fileEngine) track(key string, loc location) {
+	if prev, exists := e.index[key]; exists {
+		e.garbage += prev.size
+	} else {
+		i, _ := slices.BinarySearch(e.keys, key)
+		e.keys = slices.Insert(e.keys, i, key)
+	}
+	e.index[key] = loc
+}
+
+func (e *fileEngine) read(loc location) (*Record, error) {
+	buf := make([]byte, loc.size)
+	if _, err := e.file.ReadAt(buf, loc.offset); err != nil {
+		return nil, fmt.Errorf("reading record at offset %d: %w", loc.offset, err)
+	}
+	_, msg, _, err := readEntry(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("decoding record at offset %d: %w", loc.offset, err)
+	}
+	return NewRecord(e.writers.clockOf(wireEntries(msg.GetClock())), ChunksFromWireType(msg.GetChunks())), nil
+}
+
+func (e *fileEngine) useWriters(table *writerTable) {
+	e.writers = table
+}
+
+func (e *fileEngine) Get(key string) (*Record, bool, error) {
+	loc, exists := e.index[key]
+	if !exists {
+		return nil, false, nil
+	}
+	record, err := e.read(loc)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, true, nil
+}
+
+func (e *fileEngine) Set(key string, record *Record) error {
+	entry, err := encodeEntry(recordEntry, key, record.Clock, record.Chunks)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+	if _, err := e.file.WriteAt(entry, e.end); err != nil {
+		return fmt.Errorf("writing record: %w", err)
+	}
+	if err := e.file.Sync(); err != nil {
+		return fmt.Errorf("syncing data file: %w", err)
+	}
+	e.track(key, location{offset: e.end, size: int64(len(entry))})
+	e.end += int64(len(entry))
+
+	if e.garbage > compactionThreshold && e.garbage > e.end-e.garbage {
+		if err := e.compact(); err != nil {
+			return fmt.Errorf("compacting data file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *fileEngine) Range(consumer func(key string, record *Record) error) error {
+	return e.RangeFrom("", consumer)
+}
+
+func (e *fileEngine) RangeFrom(start string, consumer func(key string, record *Record) error) error {
+	i, _ := slices.BinarySearch(e.keys, start)
+	for _, key := range e.keys[i:] {
+		record, err := e.read(e.index[key])
+		if err != nil {
+			return err
+		}
+		if err := consumer(key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact rewrites the data file with only the latest record for each key.
+func (e *fileEngine) compact() error {
+	tmpPath := e.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("creating compacted file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	writer := bufio.NewWriter(tmp)
+	for _, key := range e.keys {
+		loc := e.index[key]
+		if _, err := io.Copy(writer, io.NewSectionReader(e.file, loc.offset, loc.size)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("copying record %s: %w", key, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("flushing compacted file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing compacted file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing compacted file: %w", err)
+	}
+	if err := os.Rename(tmpPath, e.path); err != nil {
+		return fmt.Errorf("replacing data file: %w", err)
+	}
+	if err := e.file.Close(); err != nil {
+		return fmt.Errorf("closing old data file: %w", err)
+	}
+	return e.load()
+}
+
+func (e *fileEngine) Close() error {
+	return e.file.Close()
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const identityFile = "node-id"
+
+// NewNodeId returns a random node id. Ids are drawn from the whole uint64 space so that
+// independently started nodes practically never collide.
+func NewNodeId() uint64 {
+	var buf [8]byte
+	for {
+		rand.Read(buf[:])
+		if id := binary.LittleEndian.Uint64(buf[:]); id != 0 {
+			return id
+		}
+	}
+}
+
+// NewIncarnation starts a new epoch for nodeId, or for a random node id if nodeId is zero.
+// Epochs are taken from the wall clock so that a node that lost its state cannot pick an
+// epoch it used before.
+func NewIncarnation(nodeId uint64) Incarnation {
+	if nodeId == 0 {
+		nodeId = NewNodeId()
+	}
+	return Incarnation{
+		NodeId: nodeId,
+		Epoch:  uint64(time.Now().UnixMilli()),
+	}
+}
+
+// LoadIncarnation returns the incarnation stored in dir, starting and storing a new one the
+// first time a node starts with that directory. The incarnation lives alongside the data it
+// wrote, so losing one loses the other and the node comes back as a new writer. A non-zero
+// nodeId pins the node id, which must then match any stored incarnation.
+func LoadIncarnation(dir string, nodeId uint64) (Incarnation, error) {
+	path := filepath.Join(dir, identityFile)
+	contents, err := os.ReadFile(path)
+	if err == nil {
+		stored, err := parseIncarnation(string(contents))
+		if err != nil {
+			return Incarnation{}, fmt.Errorf("parsing identity file: %w", err)
+		}
+		if nodeId != 0 && stored.NodeId != nodeId {
+			return Incarnation{}, fmt.Errorf("data directory belongs to node %d, not %d", stored.NodeId, nodeId)
+		}
+		return stored, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return Incarnation{}, fmt.Errorf("reading identity file: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Incarnation{}, fmt.Errorf("creating data directory: %w", err)
+	}
+	incarnation := NewIncarnation(nodeId)
+	tmpPath := path + ".tmp"
+	contents = fmt.Appendf(nil, "%d %d\n", incarnation.NodeId, incarnation.Epoch)
+	if err := os.WriteFile(tmpPath, contents, 0o644); err != nil {
+		return Incarnation{}, fmt.Errorf("writing identity file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return Incarnation{}, fmt.Errorf("publishing identity file: %w", err)
+	}
+	if err := syncDir(dir); err != nil {
+		return Incarnation{}, err
+	}
+	return incarnation, nil
+}
+
+// parseIncarnation reads "<node id> <epoch>". Files written before epochs existed only hold
+// the node id, and the data next to them was written in epoch zero.
+func parseIncarnation(contents string) (Incarnation, error) {
+	fields := strings.Fields(contents)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Incarnation{}, fmt.Errorf("expected a node id and epoch, got %q", contents)
+	}
+	var result Incarnation
+	var err error
+	if result.NodeId, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return Incarnation{}, fmt.Errorf("parsing node id: %w", err)
+	}
+	if len(fields) == 2 {
+		if result.Epoch, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return Incarnation{}, fmt.Errorf("parsing epoch: %w", err)
+		}
+	}
+	return result, nil
+}
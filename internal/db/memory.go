@@ -0,0 +1,61 @@
+package db
+
+import (
+	"maps"
+	"slices"
+)
+
+type memoryEngine struct {
+	records map[string]*Record
+	// Every key in order, or nil if keys were added since they were last sorted.
+	sorted []string
+}
+
+func NewMemoryEngine() Engine {
+	return newMemoryEngine(map[string]*Record{})
+}
+
+func newMemoryEngine(records map[string]*Record) *memoryEngine {
+	return &memoryEngine{
+		records: records,
+	}
+}
+
+func (e *memoryEngine) Get(key string) (*Record, bool, error) {
+	record, exists := e.records[key]
+	return record, exists, nil
+}
+
+func (e *memoryEngine) Set(key string, record *Record) error {
+	if _, exists := e.records[key]; !exists {
+		e.sorted = nil
+	}
+	e.records[key] = record
+	return nil
+}
+
+func (e *memoryEngine) Range(consumer func(key string, record *Record) error) error {
+	for key, record := range e.records {
+		if err := consumer(key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *memoryEngine) RangeFrom(start string, consumer func(key string, record *Record) error) error {
+	if e.sorted == nil {
+		e.sorted = slices.Sorted(maps.Keys(e.records))
+	}
+	i, _ := slices.BinarySearch(e.sorted, start)
+	for _, key := range e.sorted[i:] {
+		if err := consumer(key, e.records[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *memoryEngine) Close() error {
+	return nil
+}
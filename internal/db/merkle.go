@@ -0,0 +1,166 @@
+package db
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+)
+
+// The Merkle tree summarises the clock of every key, so that replicas can find the keys they
+// disagree on by comparing digests from the root down instead of exchanging every clock. Keys
+// are spread over the leaves by their hash. A node's digest is the XOR of the digests of all
+// keys below it, so a write only has to update the nodes on the path to its key's leaf.
+//
+// Nodes are numbered breadth first from the root at 0, so the children of node n are
+// n*MerkleFanout+1 through n*MerkleFanout+MerkleFanout.
+const (
+	MerkleFanout = 16
+
+	merkleLeaves    = MerkleFanout * MerkleFanout * MerkleFanout
+	merkleLeafStart = (merkleLeaves - 1) / (MerkleFanout - 1)
+	merkleNodes     = merkleLeafStart + merkleLeaves
+)
+
+// Each child of the root heads a subtree with a lock of its own, which guards the digests and
+// keys of every node below it, so that writes under different children do not contend. The
+// root's digest is worked out from its children when asked for.
+type merkleTree struct {
+	subtrees [MerkleFanout]sync.Mutex
+	digests  []uint64
+	keys     []map[string]uint64
+}
+
+func newMerkleTree() *merkleTree {
+	return &merkleTree{
+		digests: make([]uint64, merkleNodes),
+		keys:    make([]map[string]uint64, merkleLeaves),
+	}
+}
+
+// MerkleChildren returns the children of node, or nil if node is a leaf.
+func MerkleChildren(node uint32) []uint32 {
+	if node >= merkleLeafStart {
+		return nil
+	}
+	children := make([]uint32, MerkleFanout)
+	for i := range children {
+		children[i] = node*MerkleFanout + uint32(i) + 1
+	}
+	return children
+}
+
+// MerklePath returns the nodes from the root down to the leaf that holds key.
+func MerklePath(key string) []uint32 {
+	var path []uint32
+	for node := merkleLeaf(key); ; node = (node - 1) / MerkleFanout {
+		path = append(path, node)
+		if node == 0 {
+			break
+		}
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// subtree returns the lock of the subtree that node, which must not be the root, is in.
+func (t *merkleTree) subtree(node uint32) *sync.Mutex {
+	for node > MerkleFanout {
+		node = (node - 1) / MerkleFanout
+	}
+	return &t.subtrees[node-1]
+}
+
+func merkleLeaf(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return merkleLeafStart + h.Sum32()%merkleLeaves
+}
+
+func (t *merkleTree) update(key string, clock *Clock) {
+	leaf := merkleLeaf(key)
+	digest := keyDigest(key, clock)
+
+	lock := t.subtree(leaf)
+	lock.Lock()
+	defer lock.Unlock()
+	keys := t.keys[leaf-merkleLeafStart]
+	if keys == nil {
+		keys = map[string]uint64{}
+		t.keys[leaf-merkleLeafStart] = keys
+	}
+	delta := keys[key] ^ digest
+	keys[key] = digest
+	for node := leaf; node != 0; node = (node - 1) / MerkleFanout {
+		t.digests[node] ^= delta
+	}
+}
+
+func (t *merkleTree) digest(node uint32) uint64 {
+	if node != 0 {
+		lock := t.subtree(node)
+		lock.Lock()
+		defer lock.Unlock()
+		return t.digests[node]
+	}
+	var digest uint64
+	for _, child := range MerkleChildren(0) {
+		digest ^= t.digest(child)
+	}
+	return digest
+}
+
+func (t *merkleTree) digestsOf(nodes []uint32) ([]uint64, error) {
+	digests := make([]uint64, len(nodes))
+	for i, node := range nodes {
+		if node >= merkleNodes {
+			return nil, fmt.Errorf("merkle node %d out of range", node)
+		}
+		digests[i] = t.digest(node)
+	}
+	return digests, nil
+}
+
+// keysIn returns the keys under leaves in lexicographic order.
+func (t *merkleTree) keysIn(leaves []uint32) ([]string, error) {
+	var keys []string
+	for _, leaf := range leaves {
+		if leaf < merkleLeafStart || leaf >= merkleNodes {
+			return nil, fmt.Errorf("merkle node %d is not a leaf", leaf)
+		}
+		lock := t.subtree(leaf)
+		lock.Lock()
+		for key := range t.keys[leaf-merkleLeafStart] {
+			keys = append(keys, key)
+		}
+		lock.Unlock()
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// keyDigest hashes key together with every entry of its clock. Entries are combined with XOR
+// so that the digest does not depend on the order they are visited in.
+func keyDigest(key string, clock *Clock) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	base := h.Sum64()
+	var digest uint64
+	clock.visit(func(writer Incarnation, version uint64) {
+		// A zero version is the same as a missing entry.
+		if version != 0 {
+			digest ^= mix(base ^ mix(writer.NodeId^mix(writer.Epoch^mix(version))))
+		}
+	})
+	return digest
+}
+
+// mix is the finalizer of splitmix64, which spreads every input bit over the whole output.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
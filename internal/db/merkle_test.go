@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func root(t *testing.T, data db.Storage) uint64 {
+	digests, err := data.Digests([]uint32{0})
+	require.NoError(t, err)
+	return digests[0]
+}
+
+func TestMerkleTreeFindsDivergentKeys(t *testing.T) {
+	first := db.NewDatabase(testWriter)
+	second := db.NewDatabase(db.Incarnation{NodeId: testNodeId + 1})
+	for i := range 100 {
+		key := fmt.Sprintf("key-%d", i)
+		require.NoError(t, first.Put(key, []byte("data"), time.Now(), nil))
+		record, _, err := first.Get(key)
+		require.NoError(t, err)
+		require.NoError(t, second.Merge(key, record.Clock, record.Chunks))
+	}
+	require.Equal(t, root(t, first), root(t, second))
+
+	require.NoError(t, first.Put("key-7", []byte("more"), time.Now(), nil))
+	require.NotEqual(t, root(t, first), root(t, second))
+
+	// Only the path to the changed key's leaf differs.
+	var divergent []uint32
+	for _, node := range db.MerklePath("key-7") {
+		left, err := first.Digests([]uint32{node})
+		require.NoError(t, err)
+		right, err := second.Digests([]uint32{node})
+		require.NoError(t, err)
+		require.NotEqual(t, left, right)
+		siblings := slices.DeleteFunc(db.MerkleChildren(node), func(child uint32) bool {
+			return slices.Contains(db.MerklePath("key-7"), child)
+		})
+		if len(siblings) == 0 {
+			divergent = append(divergent, node)
+			continue
+		}
+		left, err = first.Digests(siblings)
+		require.NoError(t, err)
+		right, err = second.Digests(siblings)
+		require.NoError(t, err)
+		require.Equal(t, left, right)
+	}
+	require.Len(t, divergent, 1)
+
+	var keys []string
+	require.NoError(t, first.RangeLeaves(divergent, func(key string, record *db.Record) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	require.Contains(t, keys, "key-7")
+
+	record, _, err := first.Get("key-7")
+	require.NoError(t, err)
+	require.NoError(t, second.Merge("key-7", record.Clock, record.Chunks))
+	require.Equal(t, root(t, first), root(t, second))
+}
+
+func TestMerkleTreeIsRebuiltOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	data, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	require.NoError(t, data.Put("a", []byte("first"), time.Now(), nil))
+	require.NoError(t, data.Snapshot(1))
+	require.NoError(t, data.Put("b", []byte("second"), time.Now(), nil))
+	expected := root(t, data)
+	require.NoError(t, data.Close())
+
+	reopened, err := db.OpenDatabase(testWriter, dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Equal(t, expected, root(t, reopened))
+}
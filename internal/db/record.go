@@ -1,11 +1,13 @@
 package db
 
 import (
+	"cmp"
 	"fmt"
 	"slices"
 	"time"
 
 	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/hlc"
 )
 
 type Record struct {
@@ -14,10 +16,11 @@ type Record struct {
 }
 
 type Chunk struct {
-	writeTime time.Time
-	nodeId    uint64
+	writeTime hlc.Timestamp
+	writer    Incarnation
 	version   uint64
 	data      []byte
+	tombstone bool
 }
 
 func Concat(chunks []*Chunk) []byte {
@@ -35,15 +38,43 @@ func NewRecord(clock *Clock, chunks []*Chunk) *Record {
 	}
 }
 
+// NewChunk builds a chunk written by nodeId in its first epoch.
 func NewChunk(nodeId, version uint64, writeTime time.Time, data []byte) *Chunk {
+	return NewChunkFrom(Incarnation{NodeId: nodeId}, version, writeTime, data)
+}
+
+func NewChunkFrom(writer Incarnation, version uint64, writeTime time.Time, data []byte) *Chunk {
 	return &Chunk{
-		writeTime: writeTime,
-		nodeId:    nodeId,
+		writeTime: hlc.FromTime(writeTime),
+		writer:    writer,
 		version:   version,
 		data:      data,
 	}
 }
 
+// NewTombstone builds a tombstone written by nodeId in its first epoch.
+func NewTombstone(nodeId, version uint64, writeTime time.Time) *Chunk {
+	return NewTombstoneFrom(Incarnation{NodeId: nodeId}, version, writeTime)
+}
+
+func NewTombstoneFrom(writer Incarnation, version uint64, writeTime time.Time) *Chunk {
+	return &Chunk{
+		writeTime: hlc.FromTime(writeTime),
+		writer:    writer,
+		version:   version,
+		tombstone: true,
+	}
+}
+
+// clone copies r so that the copy can be changed without affecting readers of r. Chunks are
+// never modified once written, so the copy shares them.
+func (r *Record) clone() *Record {
+	return &Record{
+		Clock:  r.Clock.clone(),
+		Chunks: slices.Clone(r.Chunks),
+	}
+}
+
 func FromChunk(clock *Clock, update *Chunk) *Record {
 	return &Record{
 		Clock:  clock,
@@ -54,7 +85,10 @@ func FromChunk(clock *Clock, update *Chunk) *Record {
 func ChunksFromWireType(chunks []*clockspb.Chunk) []*Chunk {
 	result := make([]*Chunk, len(chunks))
 	for i, c := range chunks {
-		result[i] = NewChunk(c.GetNodeId(), c.GetVersion(), asTime(c.GetWriteTimeUnixMillis()), c.GetData())
+		writer := Incarnation{NodeId: c.GetNodeId(), Epoch: c.GetEpoch()}
+		result[i] = NewChunkFrom(writer, c.GetVersion(), asTime(c.GetWriteTimeUnixMillis()), c.GetData())
+		result[i].writeTime.Logical = c.GetWriteTimeLogical()
+		result[i].tombstone = c.GetTombstone()
 	}
 	return result
 }
@@ -63,17 +97,88 @@ func ChunksToWireType(chunks []*Chunk) []*clockspb.Chunk {
 	result := make([]*clockspb.Chunk, len(chunks))
 	for i, c := range chunks {
 		result[i] = &clockspb.Chunk{
-			NodeId:              c.nodeId,
+			NodeId:              c.writer.NodeId,
+			Epoch:               c.writer.Epoch,
 			Version:             c.version,
-			WriteTimeUnixMillis: uint64(c.writeTime.UnixMilli()),
+			WriteTimeUnixMillis: uint64(c.writeTime.Wall),
+			WriteTimeLogical:    c.writeTime.Logical,
 			Data:                c.data,
+			Tombstone:           c.tombstone,
 		}
 	}
 	return result
 }
 
+// GetVersion returns the newest version written by nodeId in its first epoch.
 func (r *Record) GetVersion(nodeId uint64) uint64 {
-	return r.Clock.getVersion(nodeId)
+	return r.Clock.getVersion(Incarnation{NodeId: nodeId})
+}
+
+// GetWriterVersion returns the newest version written by writer, including writers whose
+// clock entry has been folded into the record's chunks.
+func (r *Record) GetWriterVersion(writer Incarnation) uint64 {
+	if version := r.Clock.getVersion(writer); version != 0 {
+		return version
+	}
+	var version uint64
+	for _, c := range r.Chunks {
+		if c.writer == writer {
+			version = max(version, c.version)
+		}
+	}
+	return version
+}
+
+// fold drops the clock entries of writers that a newer epoch of the same node has replaced,
+// as long as the record still holds the chunk with their newest version, so that the entry
+// can be read back from the chunks. It returns nil if there is nothing to fold.
+func (r *Record) fold() *Record {
+	latest := map[uint64]uint64{}
+	r.Clock.visit(func(writer Incarnation, _ uint64) {
+		latest[writer.NodeId] = max(latest[writer.NodeId], writer.Epoch)
+	})
+	if len(latest) == len(r.Clock.entries) {
+		return nil
+	}
+	newest := map[Incarnation]uint64{}
+	for _, c := range r.Chunks {
+		newest[c.writer] = max(newest[c.writer], c.version)
+	}
+	folded := &Clock{table: r.Clock.table}
+	writers := r.Clock.table.writers()
+	for _, e := range r.Clock.entries {
+		writer := writers[e.writer]
+		if writer.Epoch < latest[writer.NodeId] && newest[writer] == e.version {
+			continue
+		}
+		folded.entries = append(folded.entries, e)
+	}
+	if len(folded.entries) == len(r.Clock.entries) {
+		return nil
+	}
+	return NewRecord(folded, r.Chunks)
+}
+
+// unfold returns the record's clock with the entries that fold dropped read back from its
+// chunks. It returns the record's own clock if nothing was folded.
+func (r *Record) unfold() *Clock {
+	var clock *Clock
+	seen := seenVersions{clock: r.Clock}
+	for _, c := range r.Chunks {
+		if !seen.unseen(c) {
+			continue
+		}
+		if clock == nil {
+			clock = r.Clock.clone()
+		}
+		if c.version > clock.getVersion(c.writer) {
+			clock.set(c.writer, c.version)
+		}
+	}
+	if clock == nil {
+		return r.Clock
+	}
+	return clock
 }
 
 func (r *Record) Merge(remoteClock *Clock, chunks []*Chunk) error {
@@ -81,9 +186,9 @@ func (r *Record) Merge(remoteClock *Clock, chunks []*Chunk) error {
 	switch orderVal {
 	case Before:
 		for _, c := range chunks {
-			alreadySeen := r.Clock.getVersion(c.nodeId)
+			alreadySeen := r.Clock.getVersion(c.writer)
 			if c.version <= alreadySeen {
-				fmt.Printf("encountered old chunk of version %d for node %d, but we have already seen %d\n", c.version, c.nodeId, alreadySeen)
+				fmt.Printf("encountered old chunk of version %d for writer %s, but we have already seen %d\n", c.version, c.writer, alreadySeen)
 				continue
 			}
 			r.Chunks = append(r.Chunks, c)
@@ -96,7 +201,7 @@ func (r *Record) Merge(remoteClock *Clock, chunks []*Chunk) error {
 	case Concurrent:
 		fmt.Printf("encountered concurrent clock of %s, where our clock is %s\n", remoteClock.toString(), r.Clock.toString())
 		r.Chunks = mergeChunks(r.Clock, r.Chunks, chunks)
-		r.Clock = r.Clock.Merge(remoteClock)
+		r.Clock.Merge(remoteClock)
 		return nil
 	default:
 		return fmt.Errorf("unrecognized order value of %d", orderVal)
@@ -104,26 +209,80 @@ func (r *Record) Merge(remoteClock *Clock, chunks []*Chunk) error {
 }
 
 func (r *Record) Update(nodeId, version uint64, updateTime time.Time, data []byte) {
+	r.insert(NewChunk(nodeId, version, updateTime, data))
+}
+
+func (r *Record) insert(chunk *Chunk) {
 	i := len(r.Chunks)
 	for j, c := range r.Chunks {
-		if updateTime.Before(c.writeTime) {
+		if chunk.before(c) {
 			i = j
 			break
 		}
 	}
 
-	r.Chunks = slices.Insert(r.Chunks, i, NewChunk(nodeId, version, updateTime, data))
-	r.Clock.set(nodeId, version)
+	r.Chunks = slices.Insert(r.Chunks, i, chunk)
+	r.Clock.set(chunk.writer, chunk.version)
+}
+
+// Live returns the chunks written after the most recent tombstone. A record without any
+// live chunks has been deleted.
+func (r *Record) Live() []*Chunk {
+	last := r.lastTombstone()
+	if last < 0 {
+		return r.Chunks
+	}
+	return r.Chunks[last+1:]
 }
 
-func (c *Chunk) Visit(f func(writeTime time.Time, nodeId uint64, version uint64, data []byte)) {
-	f(c.writeTime, c.nodeId, c.version, c.data)
+func (r *Record) lastTombstone() int {
+	for i := len(r.Chunks) - 1; i >= 0; i-- {
+		if r.Chunks[i].tombstone {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Chunk) Visit(f func(writeTime time.Time, writer Incarnation, version uint64, data []byte)) {
+	f(c.writeTime.Time(), c.writer, c.version, c.data)
+}
+
+// before orders chunks by the hybrid time they were written at. Chunks from different writers
+// can share a timestamp, and are then ordered by writer so that every replica agrees.
+func (c *Chunk) before(other *Chunk) bool {
+	return cmp.Or(
+		c.writeTime.Compare(other.writeTime),
+		cmp.Compare(c.writer.NodeId, other.writer.NodeId),
+		cmp.Compare(c.writer.Epoch, other.writer.Epoch),
+	) < 0
+}
+
+func (c *Chunk) IsTombstone() bool {
+	return c.tombstone
+}
+
+// seenVersions tells which chunks a clock has not seen yet. Neighbouring chunks usually share
+// a writer, so the clock is only searched again when the writer changes.
+type seenVersions struct {
+	clock   *Clock
+	writer  Incarnation
+	version uint64
+	found   bool
+}
+
+func (s *seenVersions) unseen(c *Chunk) bool {
+	if !s.found || c.writer != s.writer {
+		s.writer, s.version, s.found = c.writer, s.clock.getVersion(c.writer), true
+	}
+	return c.version > s.version
 }
 
 func (r *Record) GetChunksSince(alreadySeenData *Clock) []*Chunk {
 	var result []*Chunk
+	seen := seenVersions{clock: alreadySeenData}
 	for _, c := range r.Chunks {
-		if c.version > alreadySeenData.getVersion(c.nodeId) {
+		if seen.unseen(c) {
 			result = append(result, c)
 		}
 	}
@@ -136,18 +295,19 @@ func asTime(millis uint64) time.Time {
 
 func mergeChunks(clock *Clock, a, b []*Chunk) []*Chunk {
 	var result []*Chunk
+	seen := seenVersions{clock: clock}
 	pa := 0
 	pb := 0
 	for {
 		if pa == len(a) || pb == len(b) {
 			break
 		}
-		if a[pa].writeTime.Before(b[pb].writeTime) {
+		if a[pa].before(b[pb]) {
 			result = append(result, a[pa])
 			pa += 1
 		} else {
 			c := b[pb]
-			if c.version > clock.getVersion(c.nodeId) {
+			if seen.unseen(c) {
 				result = append(result, c)
 			}
 			pb += 1
@@ -155,7 +315,7 @@ func mergeChunks(clock *Clock, a, b []*Chunk) []*Chunk {
 	}
 	if pa == len(a) {
 		for _, c := range b[pb:] {
-			if c.version > clock.getVersion(c.nodeId) {
+			if seen.unseen(c) {
 				result = append(result, c)
 			}
 		}
@@ -10,6 +10,7 @@ import (
 
 var (
 	testNodeId = uint64(101)
+	testWriter = db.Incarnation{NodeId: testNodeId}
 )
 
 func TestAcceptNewChunksIntoEmptyRecord(t *testing.T) {
@@ -141,3 +142,27 @@ func TestMergingRepeatedData(t *testing.T) {
 	require.Equal(t, db.Equal, db.Order(newClock, current.Clock))
 	require.Equal(t, newChunks, current.Chunks)
 }
+
+func TestMergedTombstoneHidesEarlierChunks(t *testing.T) {
+	currentTime := time.Now()
+	startingClock := db.From(map[uint64]uint64{
+		testNodeId: 1,
+	})
+	chunks := []*db.Chunk{
+		db.NewChunk(testNodeId, 1, currentTime, []byte("test-data")),
+	}
+
+	current := db.NewRecord(startingClock, chunks)
+
+	newChunks := []*db.Chunk{
+		db.NewTombstone(testNodeId+1, 1, currentTime.Add(time.Second)),
+		db.NewChunk(testNodeId+1, 2, currentTime.Add(time.Second*2), []byte("more-data")),
+	}
+	newClock := db.From(map[uint64]uint64{
+		testNodeId + 1: 2,
+	})
+	require.NoError(t, current.Merge(newClock, newChunks))
+
+	require.Len(t, current.Chunks, 3)
+	require.Equal(t, []*db.Chunk{newChunks[1]}, current.Live())
+}
@@ -36,6 +36,8 @@ func (r *RemoteClocks) Get(nodeId uint64, key string) *Clock {
 func (r *RemoteClocks) Accept(nodeId uint64, key string, newClock *Clock) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	// Records update their clocks in place, so the clock may be a record's and must be copied.
+	newClock = newClock.clone()
 
 	record, exists := r.clocks[key]
 	if !exists {
@@ -56,5 +58,37 @@ func (r *RemoteClocks) Accept(nodeId uint64, key string, newClock *Clock) {
 		record[nodeId] = newClock
 		return
 	}
-	record[nodeId] = oldClock.Merge(newClock)
+	// Readers may still hold the old clock, so it is replaced rather than merged into.
+	newClock.Merge(oldClock)
+	record[nodeId] = newClock
+}
+
+// AckedByAll reports whether every node in nodeIds has acknowledged a clock for key that is
+// at least as new as clock.
+func (r *RemoteClocks) AckedByAll(nodeIds []uint64, key string, clock *Clock) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record := r.clocks[key]
+	for _, nodeId := range nodeIds {
+		acked, exists := record[nodeId]
+		if !exists {
+			return false
+		}
+		if !Dominates(acked, clock) {
+			return false
+		}
+	}
+	return true
+}
+
+// Forget drops every clock acknowledged by nodeId. Used when the node comes back in a new
+// epoch, since whatever its previous incarnation acknowledged may since have been lost.
+func (r *RemoteClocks) Forget(nodeId uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, record := range r.clocks {
+		delete(record, nodeId)
+	}
 }
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/WadeCappa/consensus/internal/db"
 	"github.com/stretchr/testify/require"
@@ -22,3 +23,50 @@ func TestAcceptNew(t *testing.T) {
 	require.Equal(t, db.Equal, order)
 	require.Equal(t, testClock.ToWireType(), result.ToWireType())
 }
+
+func TestAckedByAll(t *testing.T) {
+	clocks := db.NewRemoteClocks()
+	testKey := "test-key"
+	local := db.From(map[uint64]uint64{
+		1: 2,
+	})
+	peers := []uint64{2, 3}
+
+	require.True(t, clocks.AckedByAll(nil, testKey, local))
+	require.False(t, clocks.AckedByAll(peers, testKey, local))
+
+	clocks.Accept(2, testKey, local)
+	clocks.Accept(3, testKey, db.From(map[uint64]uint64{
+		1: 1,
+	}))
+	require.False(t, clocks.AckedByAll(peers, testKey, local))
+
+	clocks.Accept(3, testKey, db.From(map[uint64]uint64{
+		1: 2,
+		3: 1,
+	}))
+	require.True(t, clocks.AckedByAll(peers, testKey, local))
+}
+
+func TestForgetDropsAcks(t *testing.T) {
+	clocks := db.NewRemoteClocks()
+	clock := db.From(map[uint64]uint64{testNodeId: 1})
+	clocks.Accept(testNodeId+1, "key", clock)
+	clocks.Accept(testNodeId+2, "key", clock)
+
+	clocks.Forget(testNodeId + 1)
+	require.Nil(t, clocks.Get(testNodeId+1, "key"))
+	require.NotNil(t, clocks.Get(testNodeId+2, "key"))
+}
+
+func TestAcceptCopiesClock(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	require.NoError(t, data.Put("key", []byte("first"), time.Now(), nil))
+	record, _, err := data.Get("key")
+	require.NoError(t, err)
+	clocks := db.NewRemoteClocks()
+	clocks.Accept(2, "key", record.Clock)
+
+	require.NoError(t, data.Put("key", []byte("second"), time.Now(), nil))
+	require.Equal(t, uint64(1), clocks.Get(2, "key").ToWireType().GetClock()[testNodeId])
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The keyspace is split into this many shards by key hash. Each shard has its own lock, so
+// changes to keys in different shards run in parallel.
+const shardCount = 16
+
+type shard struct {
+	lock   sync.Mutex
+	engine Engine
+}
+
+// memoryShards gives every shard an engine of its own.
+func memoryShards(records map[string]*Record) []*shard {
+	split := make([]map[string]*Record, shardCount)
+	for i := range split {
+		split[i] = map[string]*Record{}
+	}
+	for key, record := range records {
+		split[shardIndex(key)][key] = record
+	}
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{engine: newMemoryEngine(split[i])}
+	}
+	return shards
+}
+
+// sharedShards has every shard use engine, which is locked on its own since it is not safe
+// for concurrent use. Shard locks still keep changes to a key from interleaving.
+func sharedShards(engine Engine) []*shard {
+	locked := &lockedEngine{engine: engine}
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{engine: locked}
+	}
+	return shards
+}
+
+func shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % shardCount)
+}
+
+func (d *Database) shardFor(key string) *shard {
+	return d.shards[shardIndex(key)]
+}
+
+// partitions returns the shards whose engines together hold every record exactly once.
+func (d *Database) partitions() []*shard {
+	if _, shared := d.shards[0].engine.(*lockedEngine); shared {
+		return d.shards[:1]
+	}
+	return d.shards
+}
+
+// lockAll stops every change to the database, for operations that need all of it to stand
+// still.
+func (d *Database) lockAll() {
+	for _, s := range d.shards {
+		s.lock.Lock()
+	}
+}
+
+func (d *Database) unlockAll() {
+	for _, s := range d.shards {
+		s.lock.Unlock()
+	}
+}
+
+// rangeAll visits every record in no particular order. The caller must hold every shard's
+// lock, or otherwise know that nothing changes the database.
+func (d *Database) rangeAll(consumer func(key string, record *Record) error) error {
+	for _, s := range d.partitions() {
+		if err := s.engine.Range(consumer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageFrom collects up to rangePageSize records at or after start, in key order, locking
+// one shard at a time.
+func (d *Database) pageFrom(start string) ([]keyedRecord, error) {
+	var page []keyedRecord
+	for _, s := range d.partitions() {
+		shardPage, err := s.pageFrom(start)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, shardPage...)
+	}
+	slices.SortFunc(page, func(a, b keyedRecord) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return page[:min(len(page), rangePageSize)], nil
+}
+
+func (s *shard) pageFrom(start string) ([]keyedRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var page []keyedRecord
+	if err := s.engine.RangeFrom(start, func(key string, record *Record) error {
+		if len(page) == rangePageSize {
+			return errPageFull
+		}
+		page = append(page, keyedRecord{key: key, record: record})
+		return nil
+	}); err != nil && !errors.Is(err, errPageFull) {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *shard) get(key string) (*Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.engine.Get(key)
+}
+
+type lockedEngine struct {
+	lock   sync.Mutex
+	engine Engine
+}
+
+func (e *lockedEngine) Get(key string) (*Record, bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.engine.Get(key)
+}
+
+func (e *lockedEngine) Set(key string, record *Record) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.engine.Set(key, record)
+}
+
+func (e *lockedEngine) Range(consumer func(key string, record *Record) error) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.engine.Range(consumer)
+}
+
+func (e *lockedEngine) RangeFrom(start string, consumer func(key string, record *Record) error) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.engine.RangeFrom(start, consumer)
+}
+
+func (e *lockedEngine) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.engine.Close()
+}
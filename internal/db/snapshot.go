@@ -0,0 +1,203 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".snap"
+)
+
+func snapshotName(seq uint64) string {
+	return fmt.Sprintf("%s%020d%s", snapshotPrefix, seq, snapshotSuffix)
+}
+
+// Snapshot writes every record to a new snapshot file and starts a fresh log segment.
+// A snapshot covers all log segments older than it, so only the newest retain snapshots
+// and the segments they still depend on are kept on disk.
+func (d *Database) Snapshot(retain int) error {
+	if retain < 1 {
+		return fmt.Errorf("must retain at least one snapshot, got %d", retain)
+	}
+
+	d.snapshotLock.Lock()
+	defer d.snapshotLock.Unlock()
+	records, seq, err := d.rotate()
+	if err != nil {
+		return err
+	}
+
+	// Records are never changed in place, so the ones collected still hold exactly what the
+	// new segment starts from while writes carry on. Until the snapshot is in place, recovery
+	// replays the previous segment along with the new one.
+	if err := writeSnapshot(filepath.Join(d.dir, snapshotName(seq)), func(consumer func(key string, record *Record) error) error {
+		return visit(records, consumer)
+	}); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := removeCoveredFiles(d.dir, retain); err != nil {
+		return fmt.Errorf("removing covered history: %w", err)
+	}
+	return nil
+}
+
+// rotate starts the next log segment and returns every record as of the start of it, along
+// with its sequence number.
+func (d *Database) rotate() ([]keyedRecord, uint64, error) {
+	d.lockAll()
+	defer d.unlockAll()
+	if d.log == nil {
+		return nil, 0, fmt.Errorf("database has no data directory to snapshot into")
+	}
+
+	var records []keyedRecord
+	if err := d.rangeAll(func(key string, record *Record) error {
+		records = append(records, keyedRecord{key: key, record: record})
+		return nil
+	}); err != nil {
+		return nil, 0, fmt.Errorf("collecting records: %w", err)
+	}
+	seq := d.segment + 1
+	nextLog, err := openWal(filepath.Join(d.dir, segmentName(seq)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening next log segment: %w", err)
+	}
+	if err := d.log.close(); err != nil {
+		fmt.Printf("failed to close log segment %d: %s\n", d.segment, err.Error())
+	}
+	d.log = nextLog
+	d.segment = seq
+	return records, seq, nil
+}
+
+func writeSnapshot(path string, rangeAll func(consumer func(key string, record *Record) error) error) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("creating snapshot file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := rangeAll(func(key string, record *Record) error {
+		entry, err := encodeEntry(recordEntry, key, record.Clock, record.Chunks)
+		if err != nil {
+			return fmt.Errorf("encoding record %s: %w", key, err)
+		}
+		if _, err := writer.Write(entry); err != nil {
+			return fmt.Errorf("writing record %s: %w", key, err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("iterating over records: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("flushing snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("syncing snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("publishing snapshot: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func loadSnapshot(path string) (map[string]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	records := map[string]*Record{}
+	reader := bufio.NewReader(file)
+	for {
+		kind, msg, _, err := readEntry(reader)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshot entry: %w", err)
+		}
+		if kind != recordEntry {
+			return nil, fmt.Errorf("unexpected entry kind %d in snapshot", kind)
+		}
+		records[msg.GetKey()] = NewRecord(FromWireType(msg.GetClock()), ChunksFromWireType(msg.GetChunks()))
+	}
+}
+
+func removeCoveredFiles(dir string, retain int) error {
+	snapshots, err := listSequences(dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return fmt.Errorf("listing snapshots: %w", err)
+	}
+	if len(snapshots) <= retain {
+		return nil
+	}
+
+	oldestKept := snapshots[len(snapshots)-retain]
+	for _, seq := range snapshots[:len(snapshots)-retain] {
+		if err := os.Remove(filepath.Join(dir, snapshotName(seq))); err != nil {
+			return fmt.Errorf("removing snapshot %d: %w", seq, err)
+		}
+	}
+
+	segments, err := listSequences(dir, walPrefix, walSuffix)
+	if err != nil {
+		return fmt.Errorf("listing log segments: %w", err)
+	}
+	for _, seq := range segments {
+		if seq >= oldestKept {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, segmentName(seq))); err != nil {
+			return fmt.Errorf("removing log segment %d: %w", seq, err)
+		}
+	}
+	return nil
+}
+
+// listSequences returns the sequence numbers of files in dir named prefix<seq>suffix,
+// in ascending order.
+func listSequences(dir, prefix, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+	var result []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, seq)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+func syncDir(dir string) error {
+	handle, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("opening directory: %w", err)
+	}
+	defer handle.Close()
+	if err := handle.Sync(); err != nil {
+		return fmt.Errorf("syncing directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"time"
+
+	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
+)
+
+// Storage is the replicated key-value store that the servers and replication clients
+// read from and write into.
+type Storage interface {
+	Get(key string) (*Record, bool, error)
+	// Put appends update to the record at key. If expected is not nil the update is only
+	// applied when the record's clock is equal to expected, otherwise ErrClockMismatch is
+	// returned.
+	Put(key string, update []byte, updateTime time.Time, expected *Clock) error
+	// Delete writes a tombstone for key, returning ErrNotFound if the key holds no live data.
+	Delete(key string, deleteTime time.Time) error
+	Merge(key string, remoteClock *Clock, chunks []*Chunk) error
+	// FromWireType and FromEntries build clocks that compare with the storage's records
+	// without looking up their writers.
+	FromWireType(clock *clockspb.VectorClock) *Clock
+	FromEntries(entries map[Incarnation]uint64) *Clock
+	CollectTombstones(acked func(key string, record *Record) bool) error
+	// FoldEpochs drops the clock entries of replaced epochs from every record that acked
+	// reports has been acknowledged by every member of the cluster.
+	FoldEpochs(acked func(key string, record *Record) bool) error
+	Range(consumer func(key string, record *Record) error) error
+	// RangeFrom visits every record whose key is at or after start, in lexicographic
+	// key order.
+	RangeFrom(start string, consumer func(key string, record *Record) error) error
+	// Digests returns the digests of the given nodes of the Merkle tree over every key's
+	// clock. See MerkleChildren for how nodes are numbered.
+	Digests(nodes []uint32) ([]uint64, error)
+	// RangeLeaves visits every record under the given leaves of the Merkle tree.
+	RangeLeaves(leaves []uint32, consumer func(key string, record *Record) error) error
+	// Changes visits every record modified after the change numbered since and returns the
+	// number of the latest change.
+	Changes(since uint64, consumer func(key string, record *Record) error) (uint64, error)
+	// ListenForChanges returns a listener that is woken after every change Changes visits.
+	ListenForChanges() *ChangeListener
+	StopListening(l *ChangeListener)
+	Watch(matches func(key string) bool, buffer int) *Watcher
+	Unwatch(w *Watcher)
+	Close() error
+}
+
+// Engine holds the records behind a Database. The database serialises all access to its
+// engine, so implementations do not need to be safe for concurrent use.
+type Engine interface {
+	Get(key string) (*Record, bool, error)
+	Set(key string, record *Record) error
+	Range(consumer func(key string, record *Record) error) error
+	RangeFrom(start string, consumer func(key string, record *Record) error) error
+	Close() error
+}
+
+// decodingEngine is implemented by engines that decode the records they hold rather than
+// keep them in memory, so that the clocks they decode number writers in the database's table.
+type decodingEngine interface {
+	useWriters(table *writerTable)
+}
@@ -0,0 +1,95 @@
+package db_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func engines(t *testing.T) map[string]func() db.Engine {
+	return map[string]func() db.Engine{
+		"memory": db.NewMemoryEngine,
+		"file": func() db.Engine {
+			engine, err := db.OpenFileEngine(filepath.Join(t.TempDir(), "records.db"))
+			require.NoError(t, err)
+			return engine
+		},
+	}
+}
+
+func TestStorageEngines(t *testing.T) {
+	for name, newEngine := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			storage, err := db.NewDatabaseWithEngine(testWriter, newEngine())
+			require.NoError(t, err)
+			defer storage.Close()
+
+			require.NoError(t, storage.Put("b", []byte("first"), time.Now(), nil))
+			require.NoError(t, storage.Put("b", []byte("-second"), time.Now(), nil))
+			require.NoError(t, storage.Put("a", []byte("only"), time.Now(), nil))
+			require.NoError(t, storage.Merge("c", db.From(map[uint64]uint64{
+				testNodeId + 1: 1,
+			}), []*db.Chunk{
+				db.NewChunk(testNodeId+1, 1, time.Now(), []byte("remote")),
+			}))
+
+			record, exists, err := storage.Get("b")
+			require.NoError(t, err)
+			require.True(t, exists)
+			require.Equal(t, []byte("first-second"), db.Concat(record.Chunks))
+			require.Equal(t, uint64(2), record.GetVersion(testNodeId))
+
+			_, exists, err = storage.Get("missing")
+			require.NoError(t, err)
+			require.False(t, exists)
+
+			var keys []string
+			require.NoError(t, storage.RangeFrom("b", func(key string, record *db.Record) error {
+				keys = append(keys, key)
+				return nil
+			}))
+			require.Equal(t, []string{"b", "c"}, keys)
+
+			count := 0
+			require.NoError(t, storage.Range(func(key string, record *db.Record) error {
+				count++
+				return nil
+			}))
+			require.Equal(t, 3, count)
+		})
+	}
+}
+
+func TestFileEngineSurvivesReopenAndCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	engine, err := db.OpenFileEngine(path)
+	require.NoError(t, err)
+	data, err := db.NewDatabaseWithEngine(testWriter, engine)
+	require.NoError(t, err)
+
+	// Rewriting the same keys repeatedly leaves enough superseded records behind to force
+	// at least one compaction.
+	payload := make([]byte, 4096)
+	for i := range 300 {
+		require.NoError(t, data.Put(fmt.Sprintf("key-%d", i%3), payload, time.Now(), nil))
+	}
+	require.NoError(t, data.Close())
+
+	engine, err = db.OpenFileEngine(path)
+	require.NoError(t, err)
+	reopened, err := db.NewDatabaseWithEngine(testWriter, engine)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	for i := range 3 {
+		record, exists, err := reopened.Get(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.Len(t, record.Chunks, 100)
+		require.Equal(t, uint64(100), record.GetVersion(testNodeId))
+	}
+}
@@ -0,0 +1,95 @@
+package db_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertConsistent fails if record holds a chunk its clock has not seen, which is what a
+// reader would find if it caught a record halfway through a change.
+func assertConsistent(t *testing.T, key string, record *db.Record) {
+	for _, c := range record.Chunks {
+		c.Visit(func(writeTime time.Time, writer db.Incarnation, version uint64, data []byte) {
+			assert.LessOrEqual(t, version, record.GetWriterVersion(writer), "key %s", key)
+		})
+	}
+	record.Clock.ToWireType()
+	db.Concat(record.Live())
+}
+
+// Run with -race to check that readers never share memory with writers. Everything here runs
+// off the test goroutine, so failures are reported with assert rather than require.
+func TestConcurrentReadsSeeConsistentVersions(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	const keys = 16
+	var stop atomic.Bool
+	var writers, readers sync.WaitGroup
+
+	writers.Go(func() {
+		for i := 0; !stop.Load(); i++ {
+			key := fmt.Sprintf("key-%d", i%keys)
+			if !assert.NoError(t, data.Put(key, []byte("local"), time.Now(), nil)) {
+				return
+			}
+			if i%5 == 0 && !assert.NoError(t, data.Delete(key, time.Now())) {
+				return
+			}
+		}
+	})
+	for remote := range uint64(2) {
+		writers.Go(func() {
+			nodeId := testNodeId + 1 + remote
+			for version := uint64(1); !stop.Load(); version++ {
+				key := fmt.Sprintf("key-%d", version%keys)
+				if !assert.NoError(t, data.Merge(key, db.From(map[uint64]uint64{nodeId: version}), []*db.Chunk{
+					db.NewChunk(nodeId, version, time.Now(), []byte("remote")),
+				})) {
+					return
+				}
+			}
+		})
+	}
+	writers.Go(func() {
+		for !stop.Load() {
+			if !assert.NoError(t, data.CollectTombstones(func(string, *db.Record) bool { return true })) {
+				return
+			}
+		}
+	})
+
+	for i := range keys {
+		readers.Go(func() {
+			key := fmt.Sprintf("key-%d", i)
+			for range 200 {
+				record, exists, err := data.Get(key)
+				if assert.NoError(t, err) && exists {
+					assertConsistent(t, key, record)
+				}
+			}
+		})
+	}
+	readers.Go(func() {
+		var since uint64
+		for range 50 {
+			assert.NoError(t, data.Range(func(key string, record *db.Record) error {
+				assertConsistent(t, key, record)
+				return nil
+			}))
+			var err error
+			since, err = data.Changes(since, func(key string, record *db.Record) error {
+				assertConsistent(t, key, record)
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+	})
+	readers.Wait()
+	stop.Store(true)
+	writers.Wait()
+}
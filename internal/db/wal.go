@@ -0,0 +1,256 @@
+package db
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	clockspb "github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type entryKind byte
+
+const (
+	putEntry entryKind = iota + 1
+	mergeEntry
+	recordEntry
+	// Records that the tombstones of a key were collected while it had the entry's clock.
+	collectEntry
+)
+
+const (
+	walPrefix = "wal-"
+	walSuffix = ".log"
+)
+
+// Each entry is framed as a kind byte, the payload length, a crc of the payload and a crc of
+// the header so far, followed by the payload itself. Checking the header on its own means a
+// corrupt length cannot pass for an entry cut short by the end of the file.
+const entryHeaderSize = 13
+
+// Entries may not be larger than this, so that a corrupt length read back from disk cannot
+// make us allocate without bound.
+const maxEntrySize = 256 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errTornEntry = errors.New("entry runs past the end of the file")
+
+// wal appends entries to a log segment. Entries appended while a batch is being synced are
+// written and synced together in the next batch, so concurrent writers share an fsync
+// instead of taking turns.
+type wal struct {
+	file *os.File
+
+	lock sync.Mutex
+	// Broadcast whenever a batch has been synced or has failed.
+	done    *sync.Cond
+	pending []byte
+	spare   []byte
+	syncing bool
+	// Appends are numbered in the order they are queued. Every append up to durable has been
+	// synced.
+	queued, durable uint64
+	// Once a batch fails, what was written of it cannot be trusted, so every later append
+	// fails as well.
+	err error
+}
+
+func openWal(path string) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+	w := &wal{
+		file: file,
+	}
+	w.done = sync.NewCond(&w.lock)
+	return w, nil
+}
+
+// append returns once the entry has been synced. Whichever append finds no batch being
+// synced writes out everything queued so far.
+func (w *wal) append(kind entryKind, key string, clock *Clock, chunks []*Chunk) error {
+	entry, err := encodeEntry(kind, key, clock, chunks)
+	if err != nil {
+		return fmt.Errorf("encoding log entry: %w", err)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.pending = append(w.pending, entry...)
+	w.queued++
+	ticket := w.queued
+	for w.durable < ticket {
+		switch {
+		case w.err != nil:
+			return w.err
+		case w.syncing:
+			w.done.Wait()
+		default:
+			w.flush()
+		}
+	}
+	return nil
+}
+
+// flush writes and syncs every queued entry. The caller must hold lock, which is released
+// while the batch is written.
+func (w *wal) flush() {
+	batch, upTo := w.pending, w.queued
+	w.pending = w.spare[:0]
+	w.syncing = true
+	w.lock.Unlock()
+	err := w.write(batch)
+	w.lock.Lock()
+	w.syncing = false
+	w.spare = batch
+	if err != nil {
+		w.err = err
+	} else {
+		w.durable = upTo
+	}
+	w.done.Broadcast()
+}
+
+func (w *wal) write(batch []byte) error {
+	if _, err := w.file.Write(batch); err != nil {
+		return fmt.Errorf("writing log entries: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("syncing log: %w", err)
+	}
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%s%020d%s", walPrefix, seq, walSuffix)
+}
+
+func encodeEntry(kind entryKind, key string, clock *Clock, chunks []*Chunk) ([]byte, error) {
+	msg := &clockspb.PublishRequest{
+		Key:    key,
+		Chunks: ChunksToWireType(chunks),
+	}
+	if clock != nil {
+		msg.Clock = clock.ToWireType()
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling entry: %w", err)
+	}
+	if len(payload) > maxEntrySize {
+		return nil, fmt.Errorf("entry of %d bytes exceeds the maximum of %d", len(payload), maxEntrySize)
+	}
+	entry := make([]byte, entryHeaderSize, entryHeaderSize+len(payload))
+	entry[0] = byte(kind)
+	binary.LittleEndian.PutUint32(entry[1:5], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(entry[5:9], crc32.Checksum(payload, crcTable))
+	binary.LittleEndian.PutUint32(entry[9:13], crc32.Checksum(entry[:9], crcTable))
+	return append(entry, payload...), nil
+}
+
+// replayWal feeds every complete entry in the log to apply.
+func replayWal(path string, apply func(kind entryKind, msg *clockspb.PublishRequest) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	defer file.Close()
+
+	return scanEntries(file, func(kind entryKind, msg *clockspb.PublishRequest, offset, size int64) error {
+		if err := apply(kind, msg); err != nil {
+			return fmt.Errorf("applying log entry at offset %d: %w", offset, err)
+		}
+		return nil
+	})
+}
+
+// scanEntries visits every entry in file in order. A crash mid-append can only leave a
+// partial entry at the end of the file, so a bad entry that runs to the end is truncated
+// away. A bad entry followed by more data means the file is corrupt, and is reported rather
+// than dropping everything written after it.
+func scanEntries(file *os.File, visit func(kind entryKind, msg *clockspb.PublishRequest, offset, size int64) error) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading file size: %w", err)
+	}
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		kind, msg, size, err := readEntry(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// A torn entry is cut short by the end of the file or ends exactly at it. A bad entry
+			// anywhere else was damaged after it was written.
+			if !errors.Is(err, errTornEntry) && offset+size != info.Size() {
+				return fmt.Errorf("corrupt entry at offset %d of %s: %w", offset, file.Name(), err)
+			}
+			fmt.Printf("truncating %s at offset %d: %s\n", file.Name(), offset, err.Error())
+			if err := file.Truncate(offset); err != nil {
+				return fmt.Errorf("truncating torn tail: %w", err)
+			}
+			return nil
+		}
+		if err := visit(kind, msg, offset, size); err != nil {
+			return err
+		}
+		offset += size
+	}
+}
+
+// readEntry reads the next entry. Once the header has been read and checked, the size of the
+// whole entry is returned even if the entry turns out to be bad.
+func readEntry(reader io.Reader) (entryKind, *clockspb.PublishRequest, int64, error) {
+	header := make([]byte, entryHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, 0, fmt.Errorf("reading entry header: %w", errTornEntry)
+		}
+		return 0, nil, 0, err
+	}
+	if crc32.Checksum(header[:9], crcTable) != binary.LittleEndian.Uint32(header[9:13]) {
+		return 0, nil, 0, fmt.Errorf("entry header checksum mismatch")
+	}
+	kind := entryKind(header[0])
+	length := binary.LittleEndian.Uint32(header[1:5])
+	checksum := binary.LittleEndian.Uint32(header[5:9])
+	size := int64(entryHeaderSize) + int64(length)
+
+	if length > maxEntrySize {
+		return 0, nil, size, fmt.Errorf("entry of %d bytes exceeds the maximum of %d", length, maxEntrySize)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, size, fmt.Errorf("reading entry payload: %w", errTornEntry)
+		}
+		return 0, nil, size, fmt.Errorf("reading entry payload: %w", err)
+	}
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return 0, nil, size, fmt.Errorf("entry checksum mismatch")
+	}
+	if kind != putEntry && kind != mergeEntry && kind != recordEntry && kind != collectEntry {
+		return 0, nil, size, fmt.Errorf("unrecognized entry kind %d", kind)
+	}
+
+	msg := &clockspb.PublishRequest{}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return 0, nil, size, fmt.Errorf("unmarshaling entry: %w", err)
+	}
+	return kind, msg, size, nil
+}
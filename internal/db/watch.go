@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrWatcherOverflow = errors.New("watcher fell too far behind")
+
+// Event is a chunk that was newly applied to the record at Key, either by a local write or
+// by merging remote data.
+type Event struct {
+	Key   string
+	Chunk *Chunk
+}
+
+type Watcher struct {
+	matches func(key string) bool
+	events  chan Event
+	err     error
+}
+
+// Events is closed when the watcher is removed. If the watcher could not keep up with the
+// rate of new chunks Err reports ErrWatcherOverflow once the channel is closed.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// Watch registers a watcher that receives every new chunk for keys accepted by matches.
+// Events are buffered up to buffer chunks, beyond that the watcher is dropped rather than
+// stalling writers.
+func (d *Database) Watch(matches func(key string) bool, buffer int) *Watcher {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	w := &Watcher{
+		matches: matches,
+		events:  make(chan Event, buffer),
+	}
+	if d.watchers == nil {
+		d.watchers = map[*Watcher]struct{}{}
+	}
+	d.watchers[w] = struct{}{}
+	d.watching.Add(1)
+	return w
+}
+
+func (d *Database) Unwatch(w *Watcher) {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	d.removeWatcher(w, nil)
+}
+
+func (d *Database) removeWatcher(w *Watcher, err error) {
+	if _, exists := d.watchers[w]; !exists {
+		return
+	}
+	delete(d.watchers, w)
+	d.watching.Add(-1)
+	w.err = err
+	close(w.events)
+}
+
+// announce tells watchers and change listeners about a change to key. Changes to a key are
+// announced in the order they are made, since its shard's lock is held throughout. Only
+// watchers need watchLock, so writes take no lock here while nobody is watching. A watcher
+// added after the count was read reads the record after this change was stored.
+func (d *Database) announce(key string, chunks []*Chunk) {
+	if d.watching.Load() > 0 {
+		d.watchLock.Lock()
+		d.notify(key, chunks)
+		d.watchLock.Unlock()
+	}
+	d.wakeListeners()
+}
+
+func (d *Database) notify(key string, chunks []*Chunk) {
+	for w := range d.watchers {
+		if !w.matches(key) {
+			continue
+		}
+		for _, c := range chunks {
+			select {
+			case w.events <- Event{Key: key, Chunk: c}:
+			default:
+				d.removeWatcher(w, ErrWatcherOverflow)
+			}
+			if w.err != nil {
+				break
+			}
+		}
+	}
+}
+
+// AwaitClock blocks until the record at key has a clock that dominates min, returning that
+// record, or until ctx is done.
+func AwaitClock(ctx context.Context, storage Storage, key string, min *Clock) (*Record, bool, error) {
+	matches := func(k string) bool {
+		return k == key
+	}
+	for {
+		// Watch before reading so that a change landing in between still wakes us up.
+		watcher := storage.Watch(matches, 1)
+		record, exists, err := storage.Get(key)
+		if err != nil {
+			storage.Unwatch(watcher)
+			return nil, false, fmt.Errorf("reading record: %w", err)
+		}
+		current := EmptyClock()
+		if exists {
+			current = record.unfold()
+		}
+		if Dominates(current, min) {
+			storage.Unwatch(watcher)
+			return record, exists, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			storage.Unwatch(watcher)
+			return nil, false, fmt.Errorf("waiting for clock %s: %w", min.toString(), ctx.Err())
+		case <-watcher.Events():
+		}
+		storage.Unwatch(watcher)
+	}
+}
@@ -0,0 +1,54 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherReceivesLocalAndMergedChunks(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	watcher := data.Watch(func(key string) bool {
+		return strings.HasPrefix(key, "watched/")
+	}, 10)
+
+	require.NoError(t, data.Put("watched/a", []byte("local"), time.Now(), nil))
+	require.NoError(t, data.Put("ignored", []byte("local"), time.Now(), nil))
+	remoteChunk := db.NewChunk(testNodeId+1, 1, time.Now(), []byte("remote"))
+	require.NoError(t, data.Merge("watched/a", db.From(map[uint64]uint64{
+		testNodeId + 1: 1,
+	}), []*db.Chunk{remoteChunk}))
+
+	// Merging the same data again adds nothing new, so no event should be raised.
+	require.NoError(t, data.Merge("watched/a", db.From(map[uint64]uint64{
+		testNodeId + 1: 1,
+	}), []*db.Chunk{remoteChunk}))
+
+	first := <-watcher.Events()
+	require.Equal(t, "watched/a", first.Key)
+	second := <-watcher.Events()
+	require.Equal(t, remoteChunk, second.Chunk)
+
+	data.Unwatch(watcher)
+	_, open := <-watcher.Events()
+	require.False(t, open)
+	require.NoError(t, watcher.Err())
+}
+
+func TestSlowWatcherIsDropped(t *testing.T) {
+	data := db.NewDatabase(testWriter)
+	watcher := data.Watch(func(key string) bool {
+		return true
+	}, 1)
+
+	require.NoError(t, data.Put("key", []byte("first"), time.Now(), nil))
+	require.NoError(t, data.Put("key", []byte("second"), time.Now(), nil))
+
+	<-watcher.Events()
+	_, open := <-watcher.Events()
+	require.False(t, open)
+	require.ErrorIs(t, watcher.Err(), db.ErrWatcherOverflow)
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Clocks built outside of a database number their writers here.
+var sharedWriters = newWriterTable()
+
+// writerTable numbers writers in the order they are first seen, so that clocks can keep their
+// versions in a slice sorted by number instead of a map. Numbers are never reused. The index
+// is replaced rather than changed when a writer is added, so lookups take no lock.
+type writerTable struct {
+	lock  sync.Mutex
+	index atomic.Pointer[writerIndex]
+}
+
+type writerIndex struct {
+	numbers map[Incarnation]uint32
+	writers []Incarnation
+}
+
+func newWriterTable() *writerTable {
+	t := &writerTable{}
+	t.index.Store(&writerIndex{numbers: map[Incarnation]uint32{}})
+	return t
+}
+
+func (t *writerTable) lookup(writer Incarnation) (uint32, bool) {
+	number, exists := t.index.Load().numbers[writer]
+	return number, exists
+}
+
+// writers returns every writer in the table, indexed by number.
+func (t *writerTable) writers() []Incarnation {
+	return t.index.Load().writers
+}
+
+func (t *writerTable) number(writer Incarnation) uint32 {
+	if number, exists := t.lookup(writer); exists {
+		return number
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	current := t.index.Load()
+	if number, exists := current.numbers[writer]; exists {
+		return number
+	}
+	number := uint32(len(current.writers))
+	next := &writerIndex{
+		numbers: maps.Clone(current.numbers),
+		writers: append(slices.Clip(current.writers), writer),
+	}
+	next.numbers[writer] = number
+	t.index.Store(next)
+	return number
+}
+
+func (t *writerTable) clockOf(versions map[Incarnation]uint64) *Clock {
+	entries := make([]entry, 0, len(versions))
+	for writer, version := range versions {
+		entries = append(entries, entry{writer: t.number(writer), version: version})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.writer, b.writer)
+	})
+	return &Clock{table: t, entries: entries}
+}
@@ -0,0 +1,86 @@
+// Package hlc implements hybrid logical clocks. A hybrid clock follows physical time while it
+// moves forward, and falls back on a counter when it stands still or goes backwards, so that
+// every event is stamped after every event it could have seen.
+package hlc
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrClockDrift = errors.New("timestamp is too far ahead of the local clock")
+
+// Timestamp is a hybrid clock reading. Wall is the latest physical time seen, in unix
+// milliseconds, and Logical orders events that share it.
+type Timestamp struct {
+	Wall    int64
+	Logical uint32
+}
+
+// FromTime stamps an event at physical time t.
+func FromTime(t time.Time) Timestamp {
+	return Timestamp{Wall: t.UnixMilli()}
+}
+
+func (t Timestamp) Time() time.Time {
+	return time.UnixMilli(t.Wall)
+}
+
+func (t Timestamp) Compare(other Timestamp) int {
+	return cmp.Or(cmp.Compare(t.Wall, other.Wall), cmp.Compare(t.Logical, other.Logical))
+}
+
+func (t Timestamp) Before(other Timestamp) bool {
+	return t.Compare(other) < 0
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.Wall, t.Logical)
+}
+
+type Clock struct {
+	lock sync.Mutex
+	last Timestamp
+}
+
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Now stamps a local event that happened at physical time now.
+func (c *Clock) Now(now time.Time) Timestamp {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.last = c.after(FromTime(now))
+	return c.last
+}
+
+// Observe moves the clock past an event stamped elsewhere, so that local events are stamped
+// after it.
+func (c *Clock) Observe(remote Timestamp) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.last.Before(remote) {
+		c.last = remote
+	}
+}
+
+// CheckDrift refuses a timestamp more than maxDrift ahead of physical time now. Checking
+// timestamps before observing them keeps a node whose clock runs fast from dragging every
+// other node's clock along with it.
+func CheckDrift(remote Timestamp, now time.Time, maxDrift time.Duration) error {
+	if ahead := remote.Time().Sub(now); ahead > maxDrift {
+		return fmt.Errorf("%w: %s is %s ahead", ErrClockDrift, remote, ahead)
+	}
+	return nil
+}
+
+func (c *Clock) after(physical Timestamp) Timestamp {
+	if c.last.Before(physical) {
+		return physical
+	}
+	return Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1}
+}
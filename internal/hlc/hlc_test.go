@@ -0,0 +1,44 @@
+package hlc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/hlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNowNeverGoesBackwards(t *testing.T) {
+	clock := hlc.NewClock()
+	now := time.UnixMilli(1_000_000)
+	first := clock.Now(now)
+	require.Equal(t, hlc.FromTime(now), first)
+
+	second := clock.Now(now)
+	third := clock.Now(now.Add(-time.Minute))
+	require.True(t, first.Before(second))
+	require.True(t, second.Before(third))
+	require.Equal(t, first.Wall, third.Wall)
+
+	later := clock.Now(now.Add(time.Millisecond))
+	require.Equal(t, hlc.FromTime(now.Add(time.Millisecond)), later)
+}
+
+func TestObserveStampsLocalEventsAfterRemoteOnes(t *testing.T) {
+	clock := hlc.NewClock()
+	now := time.UnixMilli(1_000_000)
+	remote := hlc.Timestamp{Wall: now.Add(time.Millisecond * 500).UnixMilli(), Logical: 3}
+	clock.Observe(remote)
+	require.True(t, remote.Before(clock.Now(now)))
+
+	// Older timestamps leave the clock where it is.
+	clock.Observe(hlc.FromTime(now.Add(-time.Hour)))
+	require.Equal(t, hlc.Timestamp{Wall: remote.Wall, Logical: 5}, clock.Now(now))
+}
+
+func TestCheckDriftRefusesTimestampsTooFarAhead(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	require.NoError(t, hlc.CheckDrift(hlc.FromTime(now.Add(time.Second)), now, time.Second))
+	require.NoError(t, hlc.CheckDrift(hlc.FromTime(now.Add(-time.Hour)), now, time.Second))
+	require.ErrorIs(t, hlc.CheckDrift(hlc.FromTime(now.Add(time.Minute)), now, time.Second), hlc.ErrClockDrift)
+}
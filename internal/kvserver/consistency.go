@@ -0,0 +1,91 @@
+package kvserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// How long a request without a deadline may wait for its peers to respond.
+const maxQuorumWait = time.Second * 5
+
+// Coordinator reaches the other replicas of the keyspace.
+type Coordinator interface {
+	// ClusterSize is the number of replicas, this node included, whether or not they can be
+	// reached.
+	ClusterSize() int
+	// Replicate returns once required peers have merged record into their copy of key.
+	Replicate(ctx context.Context, key string, record *db.Record, required int) error
+	// Gather returns once the copies of key held by required peers have been merged into
+	// the local database.
+	Gather(ctx context.Context, key string, required int) error
+}
+
+// requiredPeers is how many peers, on top of this node, must take part to satisfy the
+// consistency level. It counts every replica in the cluster rather than those that are
+// reachable, so that quorums always overlap. Requests that cannot reach enough peers fail
+// as Unavailable.
+func (s *kvserver) requiredPeers(consistency kvstorepb.Consistency) (int, error) {
+	replicas := 1
+	if s.peers != nil {
+		replicas = s.peers.ClusterSize()
+	}
+	switch consistency {
+	case kvstorepb.Consistency_ONE:
+		return 0, nil
+	case kvstorepb.Consistency_QUORUM:
+		// A majority of the replicas, one of which is this node.
+		return replicas / 2, nil
+	case kvstorepb.Consistency_ALL:
+		return replicas - 1, nil
+	default:
+		return 0, status.Errorf(codes.InvalidArgument, "unrecognized consistency level %d", consistency)
+	}
+}
+
+func (s *kvserver) replicate(ctx context.Context, key string, consistency kvstorepb.Consistency) error {
+	required, err := s.requiredPeers(consistency)
+	if err != nil || required == 0 {
+		return err
+	}
+	record, exists, err := s.data.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("record %s vanished after writing it", key)
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx, maxQuorumWait)
+	defer cancel()
+	if err := s.peers.Replicate(ctx, key, record, required); err != nil {
+		return status.Errorf(codes.Unavailable, "replicating to %s peers: %s", consistency, err.Error())
+	}
+	return nil
+}
+
+func (s *kvserver) gather(ctx context.Context, key string, consistency kvstorepb.Consistency) error {
+	required, err := s.requiredPeers(consistency)
+	if err != nil || required == 0 {
+		return err
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx, maxQuorumWait)
+	defer cancel()
+	if err := s.peers.Gather(ctx, key, required); err != nil {
+		return status.Errorf(codes.Unavailable, "reading from %s peers: %s", consistency, err.Error())
+	}
+	return nil
+}
+
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,106 @@
+package kvserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type coordinator struct {
+	size      int
+	available int
+	required  []int
+}
+
+func (c *coordinator) ClusterSize() int {
+	return c.size
+}
+
+func (c *coordinator) Replicate(ctx context.Context, key string, record *db.Record, required int) error {
+	return c.respond(required)
+}
+
+func (c *coordinator) Gather(ctx context.Context, key string, required int) error {
+	return c.respond(required)
+}
+
+func (c *coordinator) respond(required int) error {
+	c.required = append(c.required, required)
+	if required > c.available {
+		return errors.New("not enough peers")
+	}
+	return nil
+}
+
+func TestConsistencyLevels(t *testing.T) {
+	peers := &coordinator{
+		size:      5,
+		available: 2,
+	}
+	server := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), peers, nil, nil)
+
+	for _, consistency := range []kvstorepb.Consistency{
+		kvstorepb.Consistency_ONE,
+		kvstorepb.Consistency_QUORUM,
+	} {
+		_, err := server.Put(context.Background(), &kvstorepb.PutRequest{
+			Key:         "key",
+			Update:      []byte("data"),
+			Consistency: consistency,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := server.Put(context.Background(), &kvstorepb.PutRequest{
+		Key:         "key",
+		Update:      []byte("data"),
+		Consistency: kvstorepb.Consistency_ALL,
+	})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	responses := newStream[kvstorepb.GetResponse](context.Background())
+	require.NoError(t, server.Get(&kvstorepb.GetRequest{
+		Key:         "key",
+		Consistency: kvstorepb.Consistency_QUORUM,
+	}, responses))
+
+	// ONE never reaches out to peers, a quorum of five replicas needs two peers on top of
+	// this node and ALL needs every peer.
+	require.Equal(t, []int{2, 4, 2}, peers.required)
+}
+
+func TestQuorumCountsUnreachableReplicas(t *testing.T) {
+	// None of the other two replicas can be reached, so there is no quorum even though this
+	// node is the only one left.
+	peers := &coordinator{
+		size: 3,
+	}
+	server := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), peers, nil, nil)
+	_, err := server.Put(context.Background(), &kvstorepb.PutRequest{
+		Key:         "key",
+		Update:      []byte("data"),
+		Consistency: kvstorepb.Consistency_QUORUM,
+	})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, []int{1}, peers.required)
+}
+
+func TestQuorumWithoutPeers(t *testing.T) {
+	server := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), nil, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := server.Put(ctx, &kvstorepb.PutRequest{
+		Key:         "key",
+		Update:      []byte("data"),
+		Consistency: kvstorepb.Consistency_ALL,
+	})
+	require.NoError(t, err)
+}
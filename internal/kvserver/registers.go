@@ -0,0 +1,41 @@
+package kvserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Registers agrees with the other nodes on the values of compare-and-set registers.
+type Registers interface {
+	// CompareAndSet sets the register at key to update if it holds expected, where a nil
+	// expected matches a register that has never been written.
+	CompareAndSet(ctx context.Context, key string, expected, update []byte) (paxos.Result, error)
+}
+
+func (s *kvserver) CompareAndSet(
+	ctx context.Context,
+	request *kvstorepb.CompareAndSetRequest,
+) (*kvstorepb.CompareAndSetResponse, error) {
+	if s.registers == nil {
+		return nil, status.Error(codes.Unimplemented, "compare-and-set is not enabled on this node")
+	}
+	ctx, cancel := withDefaultTimeout(ctx, maxQuorumWait)
+	defer cancel()
+	result, err := s.registers.CompareAndSet(ctx, request.GetKey(), request.Expected, request.GetUpdate())
+	if errors.Is(err, paxos.ErrOutcomeUnknown) {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "agreeing on register: %s", err.Error())
+	}
+	return &kvstorepb.CompareAndSetResponse{
+		Succeeded: result.Succeeded,
+		Exists:    result.Exists,
+		Current:   result.Current,
+	}, nil
+}
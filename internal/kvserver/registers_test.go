@@ -0,0 +1,50 @@
+package kvserver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompareAndSet(t *testing.T) {
+	ctx := context.Background()
+	proposer := paxos.NewProposer(1, paxos.NewAcceptor(), nil)
+	server := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), nil, nil, proposer)
+
+	response, err := server.CompareAndSet(ctx, &kvstorepb.CompareAndSetRequest{Key: "lock", Update: []byte("a")})
+	require.NoError(t, err)
+	require.True(t, response.GetSucceeded())
+
+	response, err = server.CompareAndSet(ctx, &kvstorepb.CompareAndSetRequest{Key: "lock", Update: []byte("b")})
+	require.NoError(t, err)
+	require.False(t, response.GetSucceeded())
+	require.True(t, response.GetExists())
+	require.Equal(t, "a", string(response.GetCurrent()))
+
+	response, err = server.CompareAndSet(ctx, &kvstorepb.CompareAndSetRequest{
+		Key:      "lock",
+		Expected: []byte("a"),
+		Update:   []byte("b"),
+	})
+	require.NoError(t, err)
+	require.True(t, response.GetSucceeded())
+	require.Equal(t, "b", string(response.GetCurrent()))
+
+	// Registers are kept apart from the keys written by Put.
+	responses := newStream[kvstorepb.GetResponse](ctx)
+	err = server.Get(&kvstorepb.GetRequest{Key: "lock"}, responses)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCompareAndSetWithoutRegisters(t *testing.T) {
+	server := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), nil, nil, nil)
+	_, err := server.CompareAndSet(context.Background(), &kvstorepb.CompareAndSetRequest{Key: "lock"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -2,23 +2,46 @@ package kvserver
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/WadeCappa/consensus/internal/db"
 	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+var errStopScan = errors.New("scan complete")
+
 type kvserver struct {
 	kvstorepb.KvstoreServer
 
-	data *db.Database
+	data      db.Storage
+	peers     Coordinator
+	strong    StrongKeyspace
+	registers Registers
 }
 
-func NewKvServer(db *db.Database) kvstorepb.KvstoreServer {
+// NewKvServer serves requests from db. Requests asking for more than ONE replica are
+// coordinated with the other nodes through peers, which may be nil for a node without
+// any peers. Keys owned by strong are served from it instead, strong may be nil if no keys
+// are strongly consistent. Compare-and-sets are served by registers, which may be nil to
+// disable them.
+func NewKvServer(db db.Storage, peers Coordinator, strong StrongKeyspace, registers Registers) kvstorepb.KvstoreServer {
 	return &kvserver{
-		data: db,
+		data:      db,
+		peers:     peers,
+		strong:    strong,
+		registers: registers,
 	}
 }
 
@@ -26,32 +49,151 @@ func (s *kvserver) Put(
 	ctx context.Context,
 	request *kvstorepb.PutRequest,
 ) (*kvstorepb.PutResponse, error) {
-	if err := s.data.Put(request.GetKey(), request.GetUpdate(), time.Now()); err != nil {
+	if s.isStrong(request.GetKey()) {
+		return s.putStrong(ctx, request)
+	}
+	var expected *db.Clock
+	if request.GetExpectedClock() != nil {
+		expected = fromPublicClock(s.data, request.GetExpectedClock())
+	}
+	if err := s.data.Put(request.GetKey(), request.GetUpdate(), time.Now(), expected); err != nil {
+		if errors.Is(err, db.ErrClockMismatch) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
 		return nil, fmt.Errorf("putting record: %w", err)
 	}
+	if err := s.replicate(ctx, request.GetKey(), request.GetConsistency()); err != nil {
+		return nil, err
+	}
 
-	return &kvstorepb.PutResponse{}, nil
+	// Any clock read after the put dominates the put itself, so it is a valid token for it.
+	token, err := s.tokenFor(request.GetKey())
+	if err != nil {
+		return nil, fmt.Errorf("building token: %w", err)
+	}
+	return &kvstorepb.PutResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *kvserver) Delete(
+	ctx context.Context,
+	request *kvstorepb.DeleteRequest,
+) (*kvstorepb.DeleteResponse, error) {
+	if s.isStrong(request.GetKey()) {
+		return s.deleteStrong(ctx, request)
+	}
+	if err := s.data.Delete(request.GetKey(), time.Now()); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, fmt.Errorf("deleting record: %w", err)
+	}
+
+	token, err := s.tokenFor(request.GetKey())
+	if err != nil {
+		return nil, fmt.Errorf("building token: %w", err)
+	}
+	return &kvstorepb.DeleteResponse{
+		Token: token,
+	}, nil
 }
 
 func (s *kvserver) Get(
 	request *kvstorepb.GetRequest,
 	stream grpc.ServerStreamingServer[kvstorepb.GetResponse],
 ) error {
-	data, exists := s.data.Get(request.Key)
-	if !exists {
-		return fmt.Errorf("failed to find data for key %s", request.Key)
+	data, exists, err := s.read(stream.Context(), request)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists || len(data.Live()) == 0 {
+		return status.Errorf(codes.NotFound, "failed to find data for key %s", request.Key)
+	}
+	token, err := encodeToken(data.Clock)
+	if err != nil {
+		return fmt.Errorf("building token: %w", err)
 	}
 
-	for _, c := range data.Chunks {
-		c.Visit(func(writeTime time.Time, nodeId, version uint64, data []byte) {
+	for _, c := range data.Live() {
+		c.Visit(func(writeTime time.Time, writer db.Incarnation, version uint64, data []byte) {
 			stream.Send(&kvstorepb.GetResponse{
 				Data:                data,
 				WriteTimeUnixMillis: uint64(writeTime.UnixMilli()),
 				Version:             version,
-				NodeId:              nodeId,
+				NodeId:              writer.NodeId,
+				Epoch:               writer.Epoch,
+				Token:               token,
 			})
 		})
 	}
 
 	return nil
 }
+
+func (s *kvserver) read(ctx context.Context, request *kvstorepb.GetRequest) (*db.Record, bool, error) {
+	if s.isStrong(request.GetKey()) {
+		return s.readStrong(ctx, request.GetKey())
+	}
+	if err := s.gather(ctx, request.GetKey(), request.GetConsistency()); err != nil {
+		return nil, false, err
+	}
+	return s.readAtLeast(ctx, request.GetKey(), request.GetMinToken())
+}
+
+func (s *kvserver) Scan(
+	ctx context.Context,
+	request *kvstorepb.ScanRequest,
+) (*kvstorepb.ScanResponse, error) {
+	pageSize := int(request.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	start := max(request.GetStartKey(), request.GetPrefix())
+	if request.GetPageToken() != "" {
+		lastKey, err := base64.RawURLEncoding.DecodeString(request.GetPageToken())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "malformed page token: %s", err.Error())
+		}
+		// Resume strictly after the last key of the previous page.
+		start = max(start, string(lastKey)+"\x00")
+	}
+
+	response := &kvstorepb.ScanResponse{}
+	err := s.storageFor(request.GetPrefix()).RangeFrom(start, func(key string, record *db.Record) error {
+		// Keys are visited in order starting at or after the prefix, so the first key without
+		// the prefix ends the scan.
+		if !strings.HasPrefix(key, request.GetPrefix()) {
+			return errStopScan
+		}
+		if request.GetEndKey() != "" && key >= request.GetEndKey() {
+			return errStopScan
+		}
+		live := record.Live()
+		if len(live) == 0 {
+			return nil
+		}
+		if len(response.Keys) == pageSize {
+			last := response.Keys[len(response.Keys)-1].GetKey()
+			response.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			return errStopScan
+		}
+
+		info := &kvstorepb.KeyInfo{
+			Key: key,
+		}
+		if request.GetIncludeDetails() {
+			info.Clock = publicClock(record.Clock)
+			info.ChunkCount = uint64(len(live))
+		}
+		response.Keys = append(response.Keys, info)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return nil, fmt.Errorf("scanning records: %w", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,174 @@
+package kvserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type stream[T any] struct {
+	grpc.ServerStream
+
+	ctx       context.Context
+	responses chan *T
+}
+
+func newStream[T any](ctx context.Context) *stream[T] {
+	return &stream[T]{
+		ctx:       ctx,
+		responses: make(chan *T, 10),
+	}
+}
+
+func (s *stream[T]) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream[T]) Send(response *T) error {
+	s.responses <- response
+	return nil
+}
+
+func scanKeys(response *kvstorepb.ScanResponse) []string {
+	var keys []string
+	for _, k := range response.GetKeys() {
+		keys = append(keys, k.GetKey())
+	}
+	return keys
+}
+
+func TestScanPages(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 1})
+	for _, key := range []string{"b/2", "a/1", "b/1", "b/3", "c/1", "b/deleted"} {
+		require.NoError(t, data.Put(key, []byte("data"), time.Now(), nil))
+	}
+	require.NoError(t, data.Delete("b/deleted", time.Now()))
+	server := kvserver.NewKvServer(data, nil, nil, nil)
+
+	first, err := server.Scan(context.Background(), &kvstorepb.ScanRequest{
+		Prefix:   "b/",
+		PageSize: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b/1", "b/2"}, scanKeys(first))
+	require.NotEmpty(t, first.GetNextPageToken())
+
+	second, err := server.Scan(context.Background(), &kvstorepb.ScanRequest{
+		Prefix:    "b/",
+		PageSize:  2,
+		PageToken: first.GetNextPageToken(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b/3"}, scanKeys(second))
+	require.Empty(t, second.GetNextPageToken())
+}
+
+func TestScanRangeWithDetails(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 1})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, data.Put(key, []byte("data"), time.Now(), nil))
+	}
+	require.NoError(t, data.Put("b", []byte("more"), time.Now(), nil))
+	server := kvserver.NewKvServer(data, nil, nil, nil)
+
+	response, err := server.Scan(context.Background(), &kvstorepb.ScanRequest{
+		StartKey:       "b",
+		EndKey:         "d",
+		IncludeDetails: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, scanKeys(response))
+	require.Equal(t, uint64(2), response.GetKeys()[0].GetChunkCount())
+	require.Equal(t, map[uint64]uint64{1: 2}, response.GetKeys()[0].GetClock().GetClock())
+	require.Empty(t, response.GetNextPageToken())
+}
+
+// failingEngine holds no records and fails every read.
+type failingEngine struct{}
+
+func (failingEngine) Get(string) (*db.Record, bool, error) {
+	return nil, false, errors.New("disk on fire")
+}
+
+func (failingEngine) Set(string, *db.Record) error {
+	return nil
+}
+
+func (failingEngine) Range(func(string, *db.Record) error) error {
+	return nil
+}
+
+func (failingEngine) RangeFrom(string, func(string, *db.Record) error) error {
+	return nil
+}
+
+func (failingEngine) Close() error {
+	return nil
+}
+
+func TestGetWithTokenReportsStorageFailures(t *testing.T) {
+	writer := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), nil, nil, nil)
+	put, err := writer.Put(context.Background(), &kvstorepb.PutRequest{
+		Key:    "key",
+		Update: []byte("data"),
+	})
+	require.NoError(t, err)
+
+	reader, err := db.NewDatabaseWithEngine(db.Incarnation{NodeId: 2}, failingEngine{})
+	require.NoError(t, err)
+	err = kvserver.NewKvServer(reader, nil, nil, nil).Get(&kvstorepb.GetRequest{
+		Key:      "key",
+		MinToken: put.GetToken(),
+	}, newStream[kvstorepb.GetResponse](context.Background()))
+	require.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGetWaitsForToken(t *testing.T) {
+	writer := kvserver.NewKvServer(db.NewDatabase(db.Incarnation{NodeId: 1}), nil, nil, nil)
+	put, err := writer.Put(context.Background(), &kvstorepb.PutRequest{
+		Key:    "key",
+		Update: []byte("data"),
+	})
+	require.NoError(t, err)
+
+	reader := db.NewDatabase(db.Incarnation{NodeId: 2})
+	server := kvserver.NewKvServer(reader, nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err = server.Get(&kvstorepb.GetRequest{
+		Key:      "key",
+		MinToken: put.GetToken(),
+	}, newStream[kvstorepb.GetResponse](ctx))
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	responses := newStream[kvstorepb.GetResponse](context.Background())
+	done := make(chan error)
+	go func() {
+		done <- server.Get(&kvstorepb.GetRequest{
+			Key:      "key",
+			MinToken: put.GetToken(),
+		}, responses)
+	}()
+
+	// Replicate the write to the reading node while the Get is waiting for it.
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, reader.Merge("key", db.From(map[uint64]uint64{
+		1: 1,
+	}), []*db.Chunk{
+		db.NewChunk(1, 1, time.Now(), []byte("data")),
+	}))
+	require.NoError(t, <-done)
+	response := <-responses.responses
+	require.Equal(t, "data", string(response.GetData()))
+	require.Equal(t, put.GetToken(), response.GetToken())
+}
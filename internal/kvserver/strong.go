@@ -0,0 +1,67 @@
+package kvserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StrongKeyspace serves keys whose writes are ordered through consensus rather than merged
+// with vector clocks. Reads of those keys are linearizable, so consistency levels and
+// causality tokens do not apply to them.
+type StrongKeyspace interface {
+	// Owns reports whether key, or every key starting with a prefix, is strongly consistent.
+	Owns(key string) bool
+	Data() db.Storage
+	// Barrier returns once Data reflects every write acknowledged before the call.
+	Barrier(ctx context.Context) error
+	Put(ctx context.Context, key string, data []byte, writeTime time.Time) error
+	Delete(ctx context.Context, key string, deleteTime time.Time) error
+}
+
+func (s *kvserver) isStrong(key string) bool {
+	return s.strong != nil && s.strong.Owns(key)
+}
+
+// storageFor picks the records a scan or watch of prefix reads from. Only scans and watches
+// that stay within the strongly consistent keyspace see its keys.
+func (s *kvserver) storageFor(prefix string) db.Storage {
+	if s.isStrong(prefix) {
+		return s.strong.Data()
+	}
+	return s.data
+}
+
+func (s *kvserver) putStrong(ctx context.Context, request *kvstorepb.PutRequest) (*kvstorepb.PutResponse, error) {
+	if request.GetExpectedClock() != nil {
+		return nil, status.Error(codes.InvalidArgument, "conditional puts are not supported on strongly consistent keys")
+	}
+	if err := s.strong.Put(ctx, request.GetKey(), request.GetUpdate(), time.Now()); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "putting record: %s", err.Error())
+	}
+	return &kvstorepb.PutResponse{}, nil
+}
+
+func (s *kvserver) deleteStrong(ctx context.Context, request *kvstorepb.DeleteRequest) (*kvstorepb.DeleteResponse, error) {
+	if err := s.strong.Delete(ctx, request.GetKey(), time.Now()); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Unavailable, "deleting record: %s", err.Error())
+	}
+	return &kvstorepb.DeleteResponse{}, nil
+}
+
+func (s *kvserver) readStrong(ctx context.Context, key string) (*db.Record, bool, error) {
+	ctx, cancel := withDefaultTimeout(ctx, maxQuorumWait)
+	defer cancel()
+	if err := s.strong.Barrier(ctx); err != nil {
+		return nil, false, status.Errorf(codes.Unavailable, "waiting for read barrier: %s", err.Error())
+	}
+	return s.strong.Data().Get(key)
+}
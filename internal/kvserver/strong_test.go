@@ -0,0 +1,56 @@
+package kvserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/internal/raft"
+	"github.com/WadeCappa/consensus/internal/strong"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStrongKeysBypassVectorClocks(t *testing.T) {
+	local := db.Incarnation{NodeId: 1}
+	node, err := raft.NewNode(local.NodeId, "localhost:0", "", raft.Config{
+		HeartbeatInterval: time.Millisecond * 10,
+		ElectionTimeout:   time.Millisecond * 20,
+	})
+	require.NoError(t, err)
+	require.NoError(t, node.Bootstrap())
+	keyspace := strong.NewKeyspace("strong/", local, node)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go node.Run(ctx, keyspace)
+
+	data := db.NewDatabase(local)
+	server := kvserver.NewKvServer(data, nil, keyspace, nil)
+	_, err = server.Put(ctx, &kvstorepb.PutRequest{Key: "strong/a", Update: []byte("linearizable")})
+	require.NoError(t, err)
+	_, err = server.Put(ctx, &kvstorepb.PutRequest{Key: "weak", Update: []byte("eventual")})
+	require.NoError(t, err)
+
+	_, exists, err := data.Get("strong/a")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	responses := newStream[kvstorepb.GetResponse](ctx)
+	require.NoError(t, server.Get(&kvstorepb.GetRequest{Key: "strong/a"}, responses))
+	require.Equal(t, "linearizable", string((<-responses.responses).GetData()))
+
+	scan, err := server.Scan(ctx, &kvstorepb.ScanRequest{Prefix: "strong/"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"strong/a"}, scanKeys(scan))
+
+	_, err = server.Put(ctx, &kvstorepb.PutRequest{
+		Key:           "strong/a",
+		Update:        []byte("conditional"),
+		ExpectedClock: &kvstorepb.VectorClock{},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,108 @@
+package kvserver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// How long a Get without a deadline may wait for this node to catch up with its minToken.
+const maxTokenWait = time.Second * 5
+
+// Tokens are serialised vector clocks. Clients are expected to treat them as opaque so that
+// the representation can change without breaking them.
+func encodeToken(clock *db.Clock) ([]byte, error) {
+	token, err := proto.Marshal(publicClock(clock))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling token: %w", err)
+	}
+	return token, nil
+}
+
+func decodeToken(storage db.Storage, token []byte) (*db.Clock, error) {
+	clock := &kvstorepb.VectorClock{}
+	if err := proto.Unmarshal(token, clock); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "malformed token: %s", err.Error())
+	}
+	return fromPublicClock(storage, clock), nil
+}
+
+func publicClock(clock *db.Clock) *kvstorepb.VectorClock {
+	result := &kvstorepb.VectorClock{
+		Clock: map[uint64]uint64{},
+	}
+	for writer, version := range clock.Entries() {
+		if writer.Epoch == 0 {
+			result.Clock[writer.NodeId] = version
+			continue
+		}
+		result.Entries = append(result.Entries, &kvstorepb.VersionEntry{
+			NodeId:  writer.NodeId,
+			Epoch:   writer.Epoch,
+			Version: version,
+		})
+	}
+	slices.SortFunc(result.Entries, func(a, b *kvstorepb.VersionEntry) int {
+		return cmp.Or(cmp.Compare(a.GetNodeId(), b.GetNodeId()), cmp.Compare(a.GetEpoch(), b.GetEpoch()))
+	})
+	return result
+}
+
+// fromPublicClock builds a clock numbered like the clocks of storage's records.
+func fromPublicClock(storage db.Storage, clock *kvstorepb.VectorClock) *db.Clock {
+	return storage.FromEntries(publicEntries(clock))
+}
+
+func publicEntries(clock *kvstorepb.VectorClock) map[db.Incarnation]uint64 {
+	entries := map[db.Incarnation]uint64{}
+	for nodeId, version := range clock.GetClock() {
+		entries[db.Incarnation{NodeId: nodeId}] = version
+	}
+	for _, e := range clock.GetEntries() {
+		entries[db.Incarnation{NodeId: e.GetNodeId(), Epoch: e.GetEpoch()}] = e.GetVersion()
+	}
+	return entries
+}
+
+func (s *kvserver) tokenFor(key string) ([]byte, error) {
+	record, exists, err := s.data.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("reading record: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("record %s vanished after writing it", key)
+	}
+	return encodeToken(record.Clock)
+}
+
+// readAtLeast reads the record at key once this node has caught up with minToken.
+func (s *kvserver) readAtLeast(ctx context.Context, key string, minToken []byte) (*db.Record, bool, error) {
+	if len(minToken) == 0 {
+		return s.data.Get(key)
+	}
+	min, err := decodeToken(s.data, minToken)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := withDefaultTimeout(ctx, maxTokenWait)
+	defer cancel()
+	record, exists, err := db.AwaitClock(ctx, s.data, key, min)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, false, status.Errorf(codes.DeadlineExceeded, "node has not caught up with token: %s", err.Error())
+	case errors.Is(err, context.Canceled):
+		return nil, false, status.Errorf(codes.Canceled, "waiting for token: %s", err.Error())
+	case err != nil:
+		return nil, false, status.Errorf(codes.Internal, "waiting for token: %s", err.Error())
+	}
+	return record, exists, nil
+}
@@ -0,0 +1,151 @@
+package kvserver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const watchBuffer = 1024
+
+// watchStream tracks the newest version from each writer that has been sent for each key,
+// so that chunks seen both while replaying and on the live feed are only sent once.
+type watchStream struct {
+	stream grpc.ServerStreamingServer[kvstorepb.WatchResponse]
+	sent   map[string]map[db.Incarnation]uint64
+}
+
+func (s *kvserver) Watch(
+	request *kvstorepb.WatchRequest,
+	stream grpc.ServerStreamingServer[kvstorepb.WatchResponse],
+) error {
+	matches := func(key string) bool {
+		return strings.HasPrefix(key, request.GetPrefix())
+	}
+	if request.GetKey() != "" {
+		matches = func(key string) bool {
+			return key == request.GetKey()
+		}
+	}
+
+	storage := s.storageFor(request.GetPrefix())
+	if request.GetKey() != "" {
+		storage = s.storageFor(request.GetKey())
+	}
+
+	// Register before replaying so that nothing written during the replay is missed.
+	watcher := storage.Watch(matches, watchBuffer)
+	defer storage.Unwatch(watcher)
+
+	w := &watchStream{
+		stream: stream,
+		sent:   map[string]map[db.Incarnation]uint64{},
+	}
+	for key, clock := range request.GetResumeClocks() {
+		w.sent[key] = publicEntries(clock)
+	}
+	if err := replay(storage, request, matches, w); err != nil {
+		return fmt.Errorf("replaying existing chunks: %w", err)
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-watcher.Events():
+			if !ok {
+				if errors.Is(watcher.Err(), db.ErrWatcherOverflow) {
+					return status.Error(codes.ResourceExhausted, "watcher fell behind, resume from the last received clocks")
+				}
+				return nil
+			}
+			if err := w.send(event.Key, event.Chunk); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func replay(
+	storage db.Storage,
+	request *kvstorepb.WatchRequest,
+	matches func(key string) bool,
+	w *watchStream,
+) error {
+	if !request.GetReplay() && len(request.GetResumeClocks()) == 0 {
+		return nil
+	}
+
+	visit := func(key string, record *db.Record) error {
+		chunks := record.Live()
+		if _, resumed := request.GetResumeClocks()[key]; resumed {
+			chunks = record.Chunks
+		} else if !request.GetReplay() {
+			return nil
+		}
+		for _, c := range chunks {
+			if err := w.send(key, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if request.GetKey() != "" {
+		record, exists, err := storage.Get(request.GetKey())
+		if err != nil {
+			return fmt.Errorf("reading record: %w", err)
+		}
+		if !exists {
+			return nil
+		}
+		return visit(request.GetKey(), record)
+	}
+
+	err := storage.RangeFrom(request.GetPrefix(), func(key string, record *db.Record) error {
+		if !matches(key) {
+			return errStopScan
+		}
+		return visit(key, record)
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return err
+	}
+	return nil
+}
+
+func (w *watchStream) send(key string, chunk *db.Chunk) error {
+	sent, exists := w.sent[key]
+	if !exists {
+		sent = map[db.Incarnation]uint64{}
+		w.sent[key] = sent
+	}
+
+	var err error
+	chunk.Visit(func(writeTime time.Time, writer db.Incarnation, version uint64, data []byte) {
+		if version <= sent[writer] {
+			return
+		}
+		sent[writer] = version
+		err = w.stream.Send(&kvstorepb.WatchResponse{
+			Key:                 key,
+			Data:                data,
+			NodeId:              writer.NodeId,
+			Epoch:               writer.Epoch,
+			Version:             version,
+			WriteTimeUnixMillis: uint64(writeTime.UnixMilli()),
+			Tombstone:           chunk.IsTombstone(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("sending chunk: %w", err)
+	}
+	return nil
+}
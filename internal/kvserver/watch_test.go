@@ -0,0 +1,43 @@
+package kvserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/kvserver"
+	"github.com/WadeCappa/consensus/pkg/go/kvstore/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchResumesWithoutDuplicates(t *testing.T) {
+	data := db.NewDatabase(db.Incarnation{NodeId: 1})
+	require.NoError(t, data.Put("key", []byte("first"), time.Now(), nil))
+	require.NoError(t, data.Put("key", []byte("second"), time.Now(), nil))
+	server := kvserver.NewKvServer(data, nil, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newStream[kvstorepb.WatchResponse](ctx)
+	done := make(chan error)
+	go func() {
+		done <- server.Watch(&kvstorepb.WatchRequest{
+			Key: "key",
+			ResumeClocks: map[string]*kvstorepb.VectorClock{
+				"key": {Clock: map[uint64]uint64{1: 1}},
+			},
+		}, stream)
+	}()
+
+	replayed := <-stream.responses
+	require.Equal(t, "second", string(replayed.GetData()))
+
+	require.NoError(t, data.Put("key", []byte("third"), time.Now(), nil))
+	live := <-stream.responses
+	require.Equal(t, "third", string(live.GetData()))
+	require.Equal(t, uint64(3), live.GetVersion())
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Empty(t, stream.responses)
+}
@@ -0,0 +1,483 @@
+package membership
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/membership/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// The most membership updates piggybacked on a single message.
+const maxPiggyback = 8
+
+// Listener is told when members become reachable and when they die or leave.
+type Listener interface {
+	AddPeer(address string)
+	RemovePeer(address string)
+}
+
+type Config struct {
+	// How often a random member is probed.
+	ProbeInterval time.Duration
+	// How long to wait for a direct or indirect probe to be answered.
+	ProbeTimeout time.Duration
+	// How long a member may stay suspected before it is declared dead.
+	SuspicionTimeout time.Duration
+	// How many other members are asked to probe a member that missed a direct probe.
+	IndirectProbes int
+	Secure         bool
+}
+
+func DefaultConfig(secure bool) Config {
+	return Config{
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     time.Millisecond * 500,
+		SuspicionTimeout: time.Second * 5,
+		IndirectProbes:   3,
+		Secure:           secure,
+	}
+}
+
+type member struct {
+	info    *membershippb.Member
+	changed time.Time
+}
+
+type broadcast struct {
+	update    *membershippb.Member
+	transmits int
+}
+
+// change is the latest known state of a member, to be reported to the listener.
+type change struct {
+	address string
+	up      bool
+}
+
+// Membership tracks the members of the cluster using the SWIM protocol. Every member is
+// probed in turn, members that miss both a direct and indirect probe are suspected, and
+// suspects that do not refute the suspicion in time are declared dead. Changes are spread
+// by piggybacking them on the probes themselves.
+type Membership struct {
+	config   Config
+	listener Listener
+
+	lock       sync.Mutex
+	self       *membershippb.Member
+	members    map[uint64]*member
+	broadcasts []*broadcast
+	probeOrder []uint64
+	// The latest state of every member that changed since the listener was last told. Only
+	// the latest state matters, so changes are never dropped however far behind it falls.
+	changes map[uint64]change
+	wake    chan struct{}
+}
+
+func NewMembership(id uint64, address string, config Config, listener Listener) *Membership {
+	return &Membership{
+		config:   config,
+		listener: listener,
+		self: &membershippb.Member{
+			Id:      id,
+			Address: address,
+			// Starting from the clock means a restarted node outranks whatever its previous
+			// run was last known as, including dead.
+			Incarnation: uint64(time.Now().UnixNano()),
+			State:       membershippb.State_ALIVE,
+		},
+		members: map[uint64]*member{},
+		changes: map[uint64]change{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Run probes members and reports membership changes to the listener until ctx is done.
+func (m *Membership) Run(ctx context.Context) {
+	go m.dispatch(ctx)
+	ticker := time.NewTicker(m.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.expireSuspects()
+		m.probe(ctx)
+	}
+}
+
+// Join announces this node to the first seed that answers and learns the cluster from it.
+func (m *Membership) Join(ctx context.Context, seeds []string) error {
+	var lastErr error
+	for _, seed := range seeds {
+		err := withClient(seed, m.config.Secure, func(client membershippb.MembershipClient) error {
+			response, err := client.Join(ctx, &membershippb.JoinRequest{
+				Member: m.Self(),
+			})
+			if err != nil {
+				return fmt.Errorf("joining through %s: %w", seed, err)
+			}
+			m.apply(response.GetMembers())
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("no seed accepted the join: %w", lastErr)
+}
+
+// JoinWithRetry keeps trying to join through seeds until it succeeds or ctx is done.
+func (m *Membership) JoinWithRetry(ctx context.Context, seeds []string) {
+	ticker := time.NewTicker(m.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		err := m.Join(ctx, seeds)
+		if err == nil {
+			return
+		}
+		fmt.Printf("Failed to join cluster: %s\n", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Leave tells every live member that this node is leaving, so that they stop replicating
+// to it straight away instead of waiting to detect its failure.
+func (m *Membership) Leave(ctx context.Context) {
+	m.lock.Lock()
+	m.self.Incarnation++
+	m.self.State = membershippb.State_LEFT
+	update := proto.Clone(m.self).(*membershippb.Member)
+	var addresses []string
+	for _, mem := range m.members {
+		if isUp(mem.info.GetState()) {
+			addresses = append(addresses, mem.info.GetAddress())
+		}
+	}
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, m.config.ProbeTimeout)
+			defer cancel()
+			if err := withClient(address, m.config.Secure, func(client membershippb.MembershipClient) error {
+				_, err := client.Ping(ctx, &membershippb.PingRequest{
+					Updates: []*membershippb.Member{update},
+				})
+				return err
+			}); err != nil {
+				fmt.Printf("Failed to tell %s that we are leaving: %s\n", address, err.Error())
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func (m *Membership) Self() *membershippb.Member {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return proto.Clone(m.self).(*membershippb.Member)
+}
+
+// Members returns every member this node knows of, including itself and members that are
+// dead or have left.
+func (m *Membership) Members() []*membershippb.Member {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := []*membershippb.Member{proto.Clone(m.self).(*membershippb.Member)}
+	for _, mem := range m.members {
+		result = append(result, proto.Clone(mem.info).(*membershippb.Member))
+	}
+	return result
+}
+
+// dispatch reports changes to the listener. A member that is still up but moved to a new
+// address is removed at the old one and added at the new one.
+func (m *Membership) dispatch(ctx context.Context) {
+	// The address each member the listener was told is up is at.
+	reported := map[uint64]string{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		}
+		m.lock.Lock()
+		changes := m.changes
+		m.changes = map[uint64]change{}
+		m.lock.Unlock()
+
+		for id, c := range changes {
+			address, wasUp := reported[id]
+			if wasUp && (!c.up || address != c.address) {
+				m.listener.RemovePeer(address)
+				delete(reported, id)
+			}
+			if c.up && (!wasUp || address != c.address) {
+				m.listener.AddPeer(c.address)
+				reported[id] = c.address
+			}
+		}
+	}
+}
+
+func (m *Membership) probe(ctx context.Context) {
+	target, ok := m.nextTarget()
+	if !ok {
+		return
+	}
+
+	if err := m.ping(ctx, target.GetAddress()); err == nil {
+		return
+	}
+
+	if m.probeIndirectly(ctx, target.GetAddress()) {
+		return
+	}
+
+	fmt.Printf("suspecting member %d at %s\n", target.GetId(), target.GetAddress())
+	m.apply([]*membershippb.Member{{
+		Id:          target.GetId(),
+		Address:     target.GetAddress(),
+		Incarnation: target.GetIncarnation(),
+		State:       membershippb.State_SUSPECT,
+	}})
+}
+
+func (m *Membership) ping(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.config.ProbeTimeout)
+	defer cancel()
+	return withClient(address, m.config.Secure, func(client membershippb.MembershipClient) error {
+		response, err := client.Ping(ctx, &membershippb.PingRequest{
+			Updates: m.gossip(),
+		})
+		if err != nil {
+			return fmt.Errorf("pinging %s: %w", address, err)
+		}
+		m.apply(response.GetUpdates())
+		return nil
+	})
+}
+
+func (m *Membership) probeIndirectly(ctx context.Context, target string) bool {
+	helpers := m.randomMembers(m.config.IndirectProbes, target)
+	if len(helpers) == 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.ProbeTimeout*2)
+	defer cancel()
+	acks := make(chan bool, len(helpers))
+	for _, helper := range helpers {
+		go func() {
+			acks <- withClient(helper, m.config.Secure, func(client membershippb.MembershipClient) error {
+				response, err := client.PingReq(ctx, &membershippb.PingReqRequest{
+					Target:  target,
+					Updates: m.gossip(),
+				})
+				if err != nil {
+					return err
+				}
+				m.apply(response.GetUpdates())
+				return nil
+			}) == nil
+		}()
+	}
+	for range helpers {
+		if <-acks {
+			return true
+		}
+	}
+	return false
+}
+
+// nextTarget walks the members in a random order, reshuffling after every full pass, so that
+// each member is probed within a bounded number of rounds.
+func (m *Membership) nextTarget() (*membershippb.Member, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for {
+		if len(m.probeOrder) == 0 {
+			for id, mem := range m.members {
+				if isUp(mem.info.GetState()) {
+					m.probeOrder = append(m.probeOrder, id)
+				}
+			}
+			if len(m.probeOrder) == 0 {
+				return nil, false
+			}
+			rand.Shuffle(len(m.probeOrder), func(i, j int) {
+				m.probeOrder[i], m.probeOrder[j] = m.probeOrder[j], m.probeOrder[i]
+			})
+		}
+		id := m.probeOrder[0]
+		m.probeOrder = m.probeOrder[1:]
+		if mem, exists := m.members[id]; exists && isUp(mem.info.GetState()) {
+			return proto.Clone(mem.info).(*membershippb.Member), true
+		}
+	}
+}
+
+func (m *Membership) randomMembers(count int, exclude string) []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var candidates []string
+	for _, mem := range m.members {
+		if mem.info.GetState() == membershippb.State_ALIVE && mem.info.GetAddress() != exclude {
+			candidates = append(candidates, mem.info.GetAddress())
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates[:min(count, len(candidates))]
+}
+
+func (m *Membership) expireSuspects() {
+	m.lock.Lock()
+	var expired []*membershippb.Member
+	for _, mem := range m.members {
+		if mem.info.GetState() == membershippb.State_SUSPECT && time.Since(mem.changed) > m.config.SuspicionTimeout {
+			expired = append(expired, &membershippb.Member{
+				Id:          mem.info.GetId(),
+				Address:     mem.info.GetAddress(),
+				Incarnation: mem.info.GetIncarnation(),
+				State:       membershippb.State_DEAD,
+			})
+		}
+	}
+	m.lock.Unlock()
+
+	for _, e := range expired {
+		fmt.Printf("declaring member %d at %s dead\n", e.GetId(), e.GetAddress())
+	}
+	m.apply(expired)
+}
+
+// gossip picks the updates that have been piggybacked the fewest times. Each update is
+// retransmitted a number of times that grows with the log of the cluster size, which is
+// enough for it to reach every member with high probability.
+func (m *Membership) gossip() []*membershippb.Member {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	limit := 3 * int(math.Ceil(math.Log2(float64(len(m.members)+2))))
+	slices.SortStableFunc(m.broadcasts, func(a, b *broadcast) int {
+		return a.transmits - b.transmits
+	})
+
+	var result []*membershippb.Member
+	for _, b := range m.broadcasts[:min(maxPiggyback, len(m.broadcasts))] {
+		result = append(result, b.update)
+		b.transmits++
+	}
+	m.broadcasts = slices.DeleteFunc(m.broadcasts, func(b *broadcast) bool {
+		return b.transmits >= limit
+	})
+	return result
+}
+
+func (m *Membership) enqueue(update *membershippb.Member) {
+	update = proto.Clone(update).(*membershippb.Member)
+	for i, b := range m.broadcasts {
+		if b.update.GetId() == update.GetId() {
+			m.broadcasts[i] = &broadcast{update: update}
+			return
+		}
+	}
+	m.broadcasts = append(m.broadcasts, &broadcast{update: update})
+}
+
+// apply merges membership updates into our view of the cluster and queues every update that
+// changed that view to be gossiped onwards.
+func (m *Membership) apply(updates []*membershippb.Member) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, update := range updates {
+		if update.GetId() == m.self.GetId() {
+			m.refute(update)
+			continue
+		}
+
+		existing, exists := m.members[update.GetId()]
+		if exists && !supersedes(update, existing.info) {
+			continue
+		}
+		if !exists && !isUp(update.GetState()) {
+			// Remember departed members we have never seen, so that stale gossip about them
+			// being alive cannot add them back.
+			m.members[update.GetId()] = &member{
+				info:    proto.Clone(update).(*membershippb.Member),
+				changed: time.Now(),
+			}
+			m.enqueue(update)
+			continue
+		}
+
+		m.members[update.GetId()] = &member{
+			info:    proto.Clone(update).(*membershippb.Member),
+			changed: time.Now(),
+		}
+		m.enqueue(update)
+		m.notify(update.GetId(), change{address: update.GetAddress(), up: isUp(update.GetState())})
+	}
+}
+
+// refute answers gossip claiming that this node is suspect or dead by outranking it with a
+// higher incarnation.
+func (m *Membership) refute(update *membershippb.Member) {
+	if m.self.GetState() == membershippb.State_LEFT {
+		return
+	}
+	if update.GetState() == membershippb.State_ALIVE || update.GetIncarnation() < m.self.GetIncarnation() {
+		return
+	}
+	m.self.Incarnation = update.GetIncarnation() + 1
+	m.enqueue(m.self)
+}
+
+// notify records c as the latest state of member id for dispatch to report. The caller must
+// hold lock.
+func (m *Membership) notify(id uint64, c change) {
+	m.changes[id] = c
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func isUp(state membershippb.State) bool {
+	return state == membershippb.State_ALIVE || state == membershippb.State_SUSPECT
+}
+
+// supersedes applies SWIM's precedence rules. A higher incarnation always wins. Within the
+// same incarnation suspicion overrides alive, and dead or left override everything.
+func supersedes(update, existing *membershippb.Member) bool {
+	if update.GetIncarnation() != existing.GetIncarnation() {
+		return update.GetIncarnation() > existing.GetIncarnation()
+	}
+	return rank(update.GetState()) > rank(existing.GetState())
+}
+
+func rank(state membershippb.State) int {
+	switch state {
+	case membershippb.State_ALIVE:
+		return 0
+	case membershippb.State_SUSPECT:
+		return 1
+	default:
+		return 2
+	}
+}
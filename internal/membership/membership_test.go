@@ -0,0 +1,173 @@
+package membership_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/membership/v1"
+	"github.com/WadeCappa/consensus/internal/membership"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type listener struct {
+	lock  sync.Mutex
+	peers []string
+}
+
+func (l *listener) AddPeer(address string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.peers = append(l.peers, address)
+}
+
+func (l *listener) RemovePeer(address string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.peers = slices.DeleteFunc(l.peers, func(p string) bool {
+		return p == address
+	})
+}
+
+func (l *listener) current() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return slices.Sorted(slices.Values(l.peers))
+}
+
+type node struct {
+	address    string
+	membership *membership.Membership
+	listener   *listener
+	server     *grpc.Server
+	cancel     context.CancelFunc
+}
+
+func (n *node) stop() {
+	n.cancel()
+	n.server.Stop()
+}
+
+func startNode(t *testing.T, id uint64) *node {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	l := &listener{}
+	m := membership.NewMembership(id, lis.Addr().String(), membership.Config{
+		ProbeInterval:    time.Millisecond * 20,
+		ProbeTimeout:     time.Millisecond * 50,
+		SuspicionTimeout: time.Millisecond * 200,
+		IndirectProbes:   2,
+	}, l)
+	server := grpc.NewServer()
+	membershippb.RegisterMembershipServer(server, membership.NewMembershipServer(m))
+	go server.Serve(lis)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.Run(ctx)
+	n := &node{
+		address:    lis.Addr().String(),
+		membership: m,
+		listener:   l,
+		server:     server,
+		cancel:     cancel,
+	}
+	t.Cleanup(n.stop)
+	return n
+}
+
+func TestMembersConvergeThroughSeed(t *testing.T) {
+	seed := startNode(t, 1)
+	second := startNode(t, 2)
+	third := startNode(t, 3)
+	require.NoError(t, second.membership.Join(context.Background(), []string{seed.address}))
+	require.NoError(t, third.membership.Join(context.Background(), []string{seed.address}))
+
+	// The second node never talked to the third directly, it learns of it through gossip.
+	require.Eventually(t, func() bool {
+		return slices.Equal(second.listener.current(), slices.Sorted(slices.Values([]string{seed.address, third.address})))
+	}, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool {
+		return len(seed.listener.current()) == 2 && len(third.listener.current()) == 2
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestFailedMemberIsRemoved(t *testing.T) {
+	seed := startNode(t, 1)
+	second := startNode(t, 2)
+	third := startNode(t, 3)
+	require.NoError(t, second.membership.Join(context.Background(), []string{seed.address}))
+	require.NoError(t, third.membership.Join(context.Background(), []string{seed.address}))
+	require.Eventually(t, func() bool {
+		return len(seed.listener.current()) == 2 && len(second.listener.current()) == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	third.stop()
+	require.Eventually(t, func() bool {
+		return slices.Equal(seed.listener.current(), []string{second.address}) &&
+			slices.Equal(second.listener.current(), []string{seed.address})
+	}, time.Second*5, time.Millisecond*10)
+
+	for _, m := range seed.membership.Members() {
+		if m.GetId() == 3 {
+			require.Equal(t, membershippb.State_DEAD, m.GetState())
+		}
+	}
+}
+
+func TestLeaveRemovesMemberImmediately(t *testing.T) {
+	seed := startNode(t, 1)
+	leaving := startNode(t, 2)
+	require.NoError(t, leaving.membership.Join(context.Background(), []string{seed.address}))
+	require.Eventually(t, func() bool {
+		return len(seed.listener.current()) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	leaving.membership.Leave(context.Background())
+	require.Eventually(t, func() bool {
+		return len(seed.listener.current()) == 0
+	}, time.Millisecond*100, time.Millisecond*5)
+	for _, m := range seed.membership.Members() {
+		if m.GetId() == 2 {
+			require.Equal(t, membershippb.State_LEFT, m.GetState())
+		}
+	}
+}
+
+func TestMemberMovingToNewAddressIsReplaced(t *testing.T) {
+	seed := startNode(t, 1)
+	moving := startNode(t, 2)
+	require.NoError(t, moving.membership.Join(context.Background(), []string{seed.address}))
+	require.Eventually(t, func() bool {
+		return slices.Equal(seed.listener.current(), []string{moving.address})
+	}, time.Second*5, time.Millisecond*10)
+
+	// The member restarts at a new address and rejoins through another node, which spreads
+	// word of the move to the seed, possibly before the seed has noticed it went away.
+	moving.stop()
+	moved := startNode(t, 2)
+	other := startNode(t, 3)
+	require.NoError(t, moved.membership.Join(context.Background(), []string{other.address}))
+	require.NoError(t, other.membership.Join(context.Background(), []string{seed.address}))
+	require.Eventually(t, func() bool {
+		return slices.Equal(seed.listener.current(), slices.Sorted(slices.Values([]string{moved.address, other.address})))
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestJoinRejectsDuplicateId(t *testing.T) {
+	seed := startNode(t, 1)
+	duplicate := startNode(t, 1)
+	require.Error(t, duplicate.membership.Join(context.Background(), []string{seed.address}))
+}
+
+func TestJoinRejectsIdOfAnotherMember(t *testing.T) {
+	seed := startNode(t, 1)
+	member := startNode(t, 2)
+	require.NoError(t, member.membership.Join(context.Background(), []string{seed.address}))
+	duplicate := startNode(t, 2)
+	require.Error(t, duplicate.membership.Join(context.Background(), []string{seed.address}))
+}
@@ -0,0 +1,71 @@
+package membership
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/WadeCappa/consensus/gen/go/membership/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type membershipServer struct {
+	membershippb.UnimplementedMembershipServer
+
+	membership *Membership
+}
+
+func NewMembershipServer(membership *Membership) membershippb.MembershipServer {
+	return &membershipServer{membership: membership}
+}
+
+func (s *membershipServer) Join(ctx context.Context, request *membershippb.JoinRequest) (*membershippb.JoinResponse, error) {
+	joiner := request.GetMember()
+	for _, member := range s.membership.Members() {
+		// A member that is gone may come back at a new address under its pinned id.
+		if member.GetId() == joiner.GetId() && member.GetAddress() != joiner.GetAddress() && isUp(member.GetState()) {
+			return nil, status.Errorf(codes.AlreadyExists, "node id %d is already in use by %s", member.GetId(), member.GetAddress())
+		}
+	}
+	fmt.Printf("member %d at %s is joining\n", request.GetMember().GetId(), request.GetMember().GetAddress())
+	s.membership.apply([]*membershippb.Member{request.GetMember()})
+	return &membershippb.JoinResponse{
+		Members: s.membership.Members(),
+	}, nil
+}
+
+func (s *membershipServer) Ping(ctx context.Context, request *membershippb.PingRequest) (*membershippb.PingResponse, error) {
+	s.membership.apply(request.GetUpdates())
+	return &membershippb.PingResponse{
+		Updates: s.membership.gossip(),
+	}, nil
+}
+
+func (s *membershipServer) PingReq(ctx context.Context, request *membershippb.PingReqRequest) (*membershippb.PingResponse, error) {
+	s.membership.apply(request.GetUpdates())
+	if err := s.membership.ping(ctx, request.GetTarget()); err != nil {
+		return nil, fmt.Errorf("probing %s on behalf of a peer: %w", request.GetTarget(), err)
+	}
+	return &membershippb.PingResponse{
+		Updates: s.membership.gossip(),
+	}, nil
+}
+
+func withClient(hostname string, secure bool, consumer func(membershippb.MembershipClient) error) error {
+	var creds credentials.TransportCredentials
+	if secure {
+		creds = credentials.NewTLS(&tls.Config{})
+	} else {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(hostname, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("connecting to grpc server: %w", err)
+	}
+	defer conn.Close()
+	return consumer(membershippb.NewMembershipClient(conn))
+}
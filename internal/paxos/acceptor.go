@@ -0,0 +1,278 @@
+package paxos
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	stateFile = "paxos"
+
+	// Each state is framed as [length uint32][crc32c uint32][header crc32c uint32] followed by
+	// the state. The header crc covers the length and state crc, so that a corrupt length is
+	// not mistaken for a state cut short by a crash.
+	stateHeaderSize = 12
+
+	// States may not be larger than this, so that a corrupt length cannot make us allocate
+	// without bound.
+	maxStateSize = 64 << 20
+
+	// The state file is rewritten once it holds this many superseded states.
+	compactThreshold = 1024
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Acceptor keeps the promises and accepted proposals of this node for every register. A
+// promise must outlive a restart for Paxos to be safe, so with a directory every change is
+// synced to disk before it is acknowledged.
+type Acceptor struct {
+	lock       sync.Mutex
+	path       string
+	file       *os.File
+	states     map[string]*clockspb.PaxosState
+	superseded int
+}
+
+// NewAcceptor returns an acceptor that only keeps its state in memory.
+func NewAcceptor() *Acceptor {
+	return &Acceptor{
+		states: map[string]*clockspb.PaxosState{},
+	}
+}
+
+// OpenAcceptor loads the acceptor state kept in dir, creating it if needed.
+func OpenAcceptor(dir string) (*Acceptor, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating paxos directory: %w", err)
+	}
+	a := NewAcceptor()
+	a.path = filepath.Join(dir, stateFile)
+	if err := a.load(); err != nil {
+		return nil, fmt.Errorf("loading acceptor state: %w", err)
+	}
+	// Rewriting drops superseded states and any torn write at the tail.
+	if err := a.rewrite(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Acceptor) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// Handle runs one phase of a round for the register at the request's key.
+func (a *Acceptor) Handle(request *clockspb.PaxosRequest) (*clockspb.PaxosResponse, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	// States are never modified once stored, so that a failed write leaves the last one intact.
+	state := &clockspb.PaxosState{Key: request.GetKey()}
+	if previous, exists := a.states[request.GetKey()]; exists {
+		state = proto.Clone(previous).(*clockspb.PaxosState)
+	}
+	ballot := request.GetBallot()
+
+	var ok, changed bool
+	switch request.GetPhase() {
+	case clockspb.PaxosPhase_PREPARE:
+		if compare(ballot, state.GetPromised()) > 0 {
+			state.Promised = ballot
+			ok, changed = true, true
+		}
+	case clockspb.PaxosPhase_ACCEPT:
+		if compare(ballot, state.GetPromised()) >= 0 {
+			state.Promised = ballot
+			state.Accepted = request.GetProposal()
+			ok, changed = true, true
+		}
+	case clockspb.PaxosPhase_COMMIT:
+		ok = true
+		if compare(ballot, state.GetCommitted().GetBallot()) > 0 {
+			state.Committed = request.GetProposal()
+			changed = true
+		}
+		if state.GetAccepted() != nil && compare(state.GetAccepted().GetBallot(), ballot) <= 0 {
+			state.Accepted = nil
+			changed = true
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized paxos phase %d", request.GetPhase())
+	}
+
+	if changed {
+		if err := a.persist(state); err != nil {
+			return nil, fmt.Errorf("persisting acceptor state: %w", err)
+		}
+		a.states[request.GetKey()] = state
+	}
+	return &clockspb.PaxosResponse{
+		Ok:        ok,
+		Promised:  state.GetPromised(),
+		Accepted:  state.GetAccepted(),
+		Committed: state.GetCommitted(),
+	}, nil
+}
+
+func (a *Acceptor) persist(state *clockspb.PaxosState) error {
+	if a.file == nil {
+		return nil
+	}
+	frame, err := encodeState(state)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(frame); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return fmt.Errorf("syncing state: %w", err)
+	}
+	a.superseded++
+	if a.superseded > len(a.states)+compactThreshold {
+		return a.rewrite()
+	}
+	return nil
+}
+
+func (a *Acceptor) rewrite() error {
+	var buf []byte
+	for _, state := range a.states {
+		frame, err := encodeState(state)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, frame...)
+	}
+	if err := writeFileSynced(a.path+".tmp", buf); err != nil {
+		return fmt.Errorf("writing acceptor state: %w", err)
+	}
+	if a.file != nil {
+		a.file.Close()
+	}
+	if err := os.Rename(a.path+".tmp", a.path); err != nil {
+		return fmt.Errorf("publishing acceptor state: %w", err)
+	}
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening acceptor state: %w", err)
+	}
+	a.file = file
+	a.superseded = 0
+	return nil
+}
+
+func (a *Acceptor) load() error {
+	file, err := os.Open(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening acceptor state: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading acceptor state size: %w", err)
+	}
+
+	// Only the last state can have been cut short by a crash, and it was never acknowledged,
+	// so it is dropped. Anything else that cannot be read held promises we may have made, so
+	// loading fails rather than forget them.
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		var header [stateHeaderSize]byte
+		_, err := io.ReadFull(reader, header[:])
+		switch {
+		case err == io.EOF:
+			return nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			fmt.Printf("dropping torn paxos state header at offset %d\n", offset)
+			return nil
+		case err != nil:
+			return fmt.Errorf("reading state header at offset %d: %w", offset, err)
+		}
+		if crc32.Checksum(header[:8], crcTable) != binary.LittleEndian.Uint32(header[8:]) {
+			return fmt.Errorf("corrupt state header at offset %d", offset)
+		}
+		length := binary.LittleEndian.Uint32(header[:4])
+		if length > maxStateSize {
+			return fmt.Errorf("state at offset %d of %d bytes exceeds the maximum of %d", offset, length, maxStateSize)
+		}
+		end := offset + stateHeaderSize + int64(length)
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) || err == io.EOF {
+				fmt.Printf("dropping torn paxos state at offset %d\n", offset)
+				return nil
+			}
+			return fmt.Errorf("reading state at offset %d: %w", offset, err)
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			if end == info.Size() {
+				fmt.Printf("dropping torn paxos state at offset %d, checksum mismatch\n", offset)
+				return nil
+			}
+			return fmt.Errorf("corrupt state at offset %d, checksum mismatch", offset)
+		}
+		state := &clockspb.PaxosState{}
+		if err := proto.Unmarshal(payload, state); err != nil {
+			return fmt.Errorf("decoding state: %w", err)
+		}
+		// Later states of a register supersede earlier ones.
+		a.states[state.GetKey()] = state
+		offset = end
+	}
+}
+
+func encodeState(state *clockspb.PaxosState) ([]byte, error) {
+	payload, err := proto.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("encoding state of %s: %w", state.GetKey(), err)
+	}
+	if len(payload) > maxStateSize {
+		return nil, fmt.Errorf("state of %s of %d bytes exceeds the maximum of %d", state.GetKey(), len(payload), maxStateSize)
+	}
+	frame := make([]byte, stateHeaderSize, stateHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	binary.LittleEndian.PutUint32(frame[8:], crc32.Checksum(frame[:8], crcTable))
+	return append(frame, payload...), nil
+}
+
+func writeFileSynced(path string, contents []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(contents); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// compare orders ballots by counter and then node id. A nil ballot is lower than any other.
+func compare(a, b *clockspb.Ballot) int {
+	if c := cmp.Compare(a.GetCounter(), b.GetCounter()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.GetNodeId(), b.GetNodeId())
+}
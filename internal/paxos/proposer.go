@@ -0,0 +1,256 @@
+package paxos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+)
+
+// ErrOutcomeUnknown is returned when a round was cut short after some acceptors accepted its
+// proposal. The proposal may still be chosen by a later round.
+var ErrOutcomeUnknown = errors.New("compare-and-set may or may not have been applied")
+
+// Proposers that lose a round wait up to this long before retrying, so that racing proposers
+// stop pre-empting each other.
+const maxRetryDelay = time.Millisecond * 20
+
+// Peers reaches the acceptors on every other node.
+type Peers interface {
+	// ClusterSize is the number of nodes in the cluster, this one included, whether or not
+	// they can be reached.
+	ClusterSize() int
+	// Paxos sends request to every peer. It returns one response per peer, nil for peers that
+	// did not answer, once required of them have answered with ok or all of them are done.
+	Paxos(ctx context.Context, request *clockspb.PaxosRequest, required int) []*clockspb.PaxosResponse
+}
+
+// Result is the outcome of a compare-and-set.
+type Result struct {
+	Succeeded bool
+	// Whether the register has been written, and its value after the compare-and-set.
+	Exists  bool
+	Current []byte
+}
+
+// Proposer coordinates compare-and-sets on the node that receives them. Every compare-and-set
+// is a fresh single-decree Paxos round over the register's key, with this node's acceptor and
+// those of all known peers voting. Each phase needs a majority of the whole cluster, not just
+// of the nodes that can be reached, so that both sides of a partition cannot win the same
+// compare-and-set.
+type Proposer struct {
+	nodeId uint64
+	local  *Acceptor
+	peers  Peers
+
+	lock    sync.Mutex
+	counter uint64
+}
+
+func NewProposer(nodeId uint64, local *Acceptor, peers Peers) *Proposer {
+	return &Proposer{
+		nodeId: nodeId,
+		local:  local,
+		peers:  peers,
+	}
+}
+
+// CompareAndSet sets the register at key to update if it currently holds expected. A nil
+// expected matches a register that has never been written.
+func (p *Proposer) CompareAndSet(ctx context.Context, key string, expected, update []byte) (Result, error) {
+	// Once our proposal may have been accepted anywhere, a later round, ours or another
+	// proposer's, may still choose it, so the next value of the register decides our outcome.
+	var pending *clockspb.Proposal
+	for {
+		result, done, err := p.round(ctx, key, expected, update, &pending)
+		if err != nil || done {
+			return result, err
+		}
+		select {
+		case <-ctx.Done():
+			if pending != nil {
+				return Result{}, ErrOutcomeUnknown
+			}
+			return Result{}, fmt.Errorf("agreeing on %s: %w", key, ctx.Err())
+		case <-time.After(rand.N(maxRetryDelay)):
+		}
+	}
+}
+
+// round runs a single round, reporting whether it decided the compare-and-set.
+func (p *Proposer) round(
+	ctx context.Context,
+	key string,
+	expected, update []byte,
+	pending **clockspb.Proposal,
+) (Result, bool, error) {
+	ballot := p.nextBallot()
+	promises, err := p.broadcast(ctx, &clockspb.PaxosRequest{
+		Phase:  clockspb.PaxosPhase_PREPARE,
+		Key:    key,
+		Ballot: ballot,
+	})
+	if err != nil || !p.quorum(promises) {
+		return Result{}, false, err
+	}
+
+	committed, inProgress := latest(promises)
+	if inProgress != nil {
+		// An earlier round may already have chosen this proposal, so it has to be finished
+		// before the register can be read.
+		proposal := &clockspb.Proposal{
+			Ballot:   ballot,
+			Value:    inProgress.GetValue(),
+			Origin:   inProgress.GetOrigin(),
+			Sequence: inProgress.GetSequence(),
+		}
+		if chosen, err := p.choose(ctx, key, proposal); err != nil || !chosen {
+			return Result{}, false, err
+		}
+		committed = proposal
+	}
+
+	if *pending != nil {
+		switch sequence := committed.GetSequence(); {
+		case sequence == (*pending).GetSequence():
+			won := compare(committed.GetOrigin(), (*pending).GetOrigin()) == 0
+			return Result{Succeeded: won, Exists: true, Current: committed.GetValue()}, true, nil
+		case sequence > (*pending).GetSequence():
+			return Result{}, true, ErrOutcomeUnknown
+		}
+		// A majority has promised not to accept our old proposal and none of them holds it,
+		// so it can no longer be chosen.
+		*pending = nil
+	}
+	if inProgress != nil {
+		// Our ballot has already been used for the finished proposal.
+		return Result{}, false, nil
+	}
+
+	current := Result{Exists: committed != nil, Current: committed.GetValue()}
+	if current.Exists != (expected != nil) || !bytes.Equal(current.Current, expected) {
+		return current, true, nil
+	}
+	proposal := &clockspb.Proposal{
+		Ballot:   ballot,
+		Value:    update,
+		Origin:   ballot,
+		Sequence: committed.GetSequence() + 1,
+	}
+	chosen, err := p.choose(ctx, key, proposal)
+	if err != nil {
+		return Result{}, false, err
+	}
+	if !chosen {
+		*pending = proposal
+		return Result{}, false, nil
+	}
+	return Result{Succeeded: true, Exists: true, Current: update}, true, nil
+}
+
+// latest returns the most recently committed proposal among the promises, and the most
+// recently accepted one if it is newer than that.
+func latest(promises []*clockspb.PaxosResponse) (*clockspb.Proposal, *clockspb.Proposal) {
+	var committed, accepted *clockspb.Proposal
+	for _, response := range promises {
+		if !response.GetOk() {
+			continue
+		}
+		if compare(response.GetCommitted().GetBallot(), committed.GetBallot()) > 0 {
+			committed = response.GetCommitted()
+		}
+		if compare(response.GetAccepted().GetBallot(), accepted.GetBallot()) > 0 {
+			accepted = response.GetAccepted()
+		}
+	}
+	if compare(accepted.GetBallot(), committed.GetBallot()) <= 0 {
+		return committed, nil
+	}
+	return committed, accepted
+}
+
+// choose asks the acceptors to accept proposal and commits it once a majority has. It
+// reports false if the proposal was not accepted by a majority.
+func (p *Proposer) choose(ctx context.Context, key string, proposal *clockspb.Proposal) (bool, error) {
+	accepted, err := p.broadcast(ctx, &clockspb.PaxosRequest{
+		Phase:    clockspb.PaxosPhase_ACCEPT,
+		Key:      key,
+		Ballot:   proposal.GetBallot(),
+		Proposal: proposal,
+	})
+	if err != nil || !p.quorum(accepted) {
+		return false, err
+	}
+	committed, err := p.broadcast(ctx, &clockspb.PaxosRequest{
+		Phase:    clockspb.PaxosPhase_COMMIT,
+		Key:      key,
+		Ballot:   proposal.GetBallot(),
+		Proposal: proposal,
+	})
+	if err != nil {
+		return false, err
+	}
+	// The value is chosen once a majority accepted it, but only reporting success once a
+	// majority knows about it lets a reader that misses the commit see it as in progress.
+	return p.quorum(committed), nil
+}
+
+// broadcast runs request on the local acceptor and every peer, returning once a majority
+// of them has answered with ok or all of them are done. The local response comes first.
+func (p *Proposer) broadcast(ctx context.Context, request *clockspb.PaxosRequest) ([]*clockspb.PaxosResponse, error) {
+	local, err := p.local.Handle(request)
+	if err != nil {
+		return nil, fmt.Errorf("running paxos phase locally: %w", err)
+	}
+	responses := []*clockspb.PaxosResponse{local}
+	if p.peers != nil {
+		required := p.majority()
+		if local.GetOk() {
+			required--
+		}
+		responses = append(responses, p.peers.Paxos(ctx, request, required)...)
+	}
+	for _, response := range responses {
+		p.observe(response.GetPromised())
+	}
+	return responses, nil
+}
+
+// majority is how many acceptors make up a majority of the cluster.
+func (p *Proposer) majority() int {
+	if p.peers == nil {
+		return 1
+	}
+	return p.peers.ClusterSize()/2 + 1
+}
+
+// quorum reports whether a majority of the cluster answered with ok.
+func (p *Proposer) quorum(responses []*clockspb.PaxosResponse) bool {
+	var ok int
+	for _, response := range responses {
+		if response.GetOk() {
+			ok++
+		}
+	}
+	return ok >= p.majority()
+}
+
+// nextBallot returns a ballot higher than any this proposer has used or seen. Counters
+// start from the clock so that a restarted proposer does not reuse its old ballots.
+func (p *Proposer) nextBallot() *clockspb.Ballot {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.counter = max(p.counter+1, uint64(time.Now().UnixMicro()))
+	return &clockspb.Ballot{Counter: p.counter, NodeId: p.nodeId}
+}
+
+func (p *Proposer) observe(ballot *clockspb.Ballot) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.counter = max(p.counter, ballot.GetCounter())
+}
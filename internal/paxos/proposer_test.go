@@ -0,0 +1,237 @@
+package paxos_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/paxos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// cluster connects the acceptors of several nodes in memory.
+type cluster struct {
+	lock      sync.Mutex
+	acceptors []*paxos.Acceptor
+	down      map[int]bool
+	// Nodes that others have stopped counting as peers, as when membership removes them.
+	removed map[int]bool
+}
+
+type peers struct {
+	self    int
+	cluster *cluster
+}
+
+func (p *peers) ClusterSize() int {
+	return len(p.cluster.acceptors)
+}
+
+func (p *peers) Paxos(ctx context.Context, request *clockspb.PaxosRequest, required int) []*clockspb.PaxosResponse {
+	var responses []*clockspb.PaxosResponse
+	for i, acceptor := range p.cluster.acceptors {
+		if i == p.self {
+			continue
+		}
+		p.cluster.lock.Lock()
+		down, removed := p.cluster.down[i], p.cluster.removed[i] || p.cluster.removed[p.self]
+		p.cluster.lock.Unlock()
+		if removed {
+			continue
+		}
+		if down {
+			responses = append(responses, nil)
+			continue
+		}
+		response, err := acceptor.Handle(proto.Clone(request).(*clockspb.PaxosRequest))
+		if err != nil {
+			response = nil
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+func newCluster(size int) (*cluster, []*paxos.Proposer) {
+	c := &cluster{down: map[int]bool{}, removed: map[int]bool{}}
+	for range size {
+		c.acceptors = append(c.acceptors, paxos.NewAcceptor())
+	}
+	var proposers []*paxos.Proposer
+	for i, acceptor := range c.acceptors {
+		proposers = append(proposers, paxos.NewProposer(uint64(i+1), acceptor, &peers{self: i, cluster: c}))
+	}
+	return c, proposers
+}
+
+func TestExactlyOneRacingWriterSucceeds(t *testing.T) {
+	_, proposers := newCluster(5)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	results := make([]paxos.Result, len(proposers))
+	var wg sync.WaitGroup
+	for i, proposer := range proposers {
+		wg.Go(func() {
+			result, err := proposer.CompareAndSet(ctx, "lease", nil, []byte(fmt.Sprintf("owner-%d", i)))
+			assert.NoError(t, err)
+			results[i] = result
+		})
+	}
+	wg.Wait()
+
+	var winners []int
+	for i, result := range results {
+		if result.Succeeded {
+			winners = append(winners, i)
+		}
+	}
+	require.Len(t, winners, 1)
+	for _, result := range results {
+		require.True(t, result.Exists)
+		require.Equal(t, fmt.Sprintf("owner-%d", winners[0]), string(result.Current))
+	}
+}
+
+func TestCompareAndSetFailsOnMismatch(t *testing.T) {
+	_, proposers := newCluster(3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	result, err := proposers[0].CompareAndSet(ctx, "counter", nil, []byte("1"))
+	require.NoError(t, err)
+	require.True(t, result.Succeeded)
+
+	result, err = proposers[1].CompareAndSet(ctx, "counter", nil, []byte("1"))
+	require.NoError(t, err)
+	require.False(t, result.Succeeded)
+	require.Equal(t, "1", string(result.Current))
+
+	result, err = proposers[2].CompareAndSet(ctx, "counter", []byte("1"), []byte("2"))
+	require.NoError(t, err)
+	require.True(t, result.Succeeded)
+
+	result, err = proposers[0].CompareAndSet(ctx, "counter", []byte("1"), []byte("3"))
+	require.NoError(t, err)
+	require.False(t, result.Succeeded)
+	require.Equal(t, "2", string(result.Current))
+}
+
+func TestCompareAndSetNeedsAMajority(t *testing.T) {
+	c, proposers := newCluster(3)
+	c.down[2] = true
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	result, err := proposers[0].CompareAndSet(ctx, "key", nil, []byte("value"))
+	require.NoError(t, err)
+	require.True(t, result.Succeeded)
+
+	c.down[1] = true
+	short, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = proposers[0].CompareAndSet(short, "key", []byte("value"), []byte("other"))
+	require.Error(t, err)
+}
+
+func TestCompareAndSetNeedsAMajorityOfTheWholeCluster(t *testing.T) {
+	c, proposers := newCluster(3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	result, err := proposers[0].CompareAndSet(ctx, "lease", nil, []byte("first"))
+	require.NoError(t, err)
+	require.True(t, result.Succeeded)
+
+	// Node 0 is partitioned from the others, which stop counting it as a peer and which it
+	// stops counting as peers.
+	c.lock.Lock()
+	c.removed[0] = true
+	c.lock.Unlock()
+
+	short, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = proposers[0].CompareAndSet(short, "lease", []byte("first"), []byte("isolated"))
+	require.Error(t, err)
+
+	result, err = proposers[1].CompareAndSet(ctx, "lease", []byte("first"), []byte("majority"))
+	require.NoError(t, err)
+	require.True(t, result.Succeeded)
+}
+
+func TestPromisesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	acceptor, err := paxos.OpenAcceptor(dir)
+	require.NoError(t, err)
+	response, err := acceptor.Handle(&clockspb.PaxosRequest{
+		Phase:  clockspb.PaxosPhase_PREPARE,
+		Key:    "key",
+		Ballot: &clockspb.Ballot{Counter: 10, NodeId: 1},
+	})
+	require.NoError(t, err)
+	require.True(t, response.GetOk())
+	require.NoError(t, acceptor.Close())
+
+	reopened, err := paxos.OpenAcceptor(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	response, err = reopened.Handle(&clockspb.PaxosRequest{
+		Phase:  clockspb.PaxosPhase_PREPARE,
+		Key:    "key",
+		Ballot: &clockspb.Ballot{Counter: 9, NodeId: 2},
+	})
+	require.NoError(t, err)
+	require.False(t, response.GetOk())
+	require.Equal(t, uint64(10), response.GetPromised().GetCounter())
+}
+
+func promise(t *testing.T, acceptor *paxos.Acceptor, key string, counter uint64) {
+	response, err := acceptor.Handle(&clockspb.PaxosRequest{
+		Phase:  clockspb.PaxosPhase_PREPARE,
+		Key:    key,
+		Ballot: &clockspb.Ballot{Counter: counter, NodeId: 1},
+	})
+	require.NoError(t, err)
+	require.True(t, response.GetOk())
+}
+
+func TestTornStateIsDroppedButCorruptStateFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	acceptor, err := paxos.OpenAcceptor(dir)
+	require.NoError(t, err)
+	promise(t, acceptor, "first", 10)
+	promise(t, acceptor, "second", 10)
+	require.NoError(t, acceptor.Close())
+
+	path := filepath.Join(dir, "paxos")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Cutting the last state short loses only that state.
+	require.NoError(t, os.WriteFile(path, data[:len(data)-2], 0o644))
+	reopened, err := paxos.OpenAcceptor(dir)
+	require.NoError(t, err)
+	response, err := reopened.Handle(&clockspb.PaxosRequest{
+		Phase:  clockspb.PaxosPhase_PREPARE,
+		Key:    "first",
+		Ballot: &clockspb.Ballot{Counter: 9, NodeId: 2},
+	})
+	require.NoError(t, err)
+	require.False(t, response.GetOk())
+	require.NoError(t, reopened.Close())
+
+	// A corrupt length or state ahead of other states is not taken for the end of the file.
+	for _, at := range []int{0, 12} {
+		corrupt := append([]byte(nil), data...)
+		corrupt[at] ^= 0xff
+		require.NoError(t, os.WriteFile(path, corrupt, 0o644))
+		_, err := paxos.OpenAcceptor(dir)
+		require.Error(t, err, "corrupting byte %d", at)
+	}
+}
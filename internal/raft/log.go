@@ -0,0 +1,294 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/WadeCappa/consensus/gen/go/raft/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	stateFile = "state"
+	logFile   = "log"
+
+	// Each log entry is framed as [length uint32][crc32c uint32][header crc32c uint32] followed
+	// by the entry. The header crc covers the length and entry crc, so that a corrupt length
+	// is not mistaken for a frame torn by a crash.
+	entryHeaderSize = 12
+	// Frames may not be larger than this, so that a corrupt length read back from disk cannot
+	// make us allocate without bound.
+	maxEntrySize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// raftLog holds the state Raft needs to survive restarts: the current term, who we voted for
+// in it and the log itself. Without a directory it only lives in memory.
+type raftLog struct {
+	dir     string
+	file    *os.File
+	term    uint64
+	vote    uint64
+	entries []*raftpb.Entry
+}
+
+func openLog(dir string) (*raftLog, error) {
+	l := &raftLog{dir: dir}
+	if dir == "" {
+		return l, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating raft directory: %w", err)
+	}
+
+	if err := l.loadState(); err != nil {
+		return nil, fmt.Errorf("loading term and vote: %w", err)
+	}
+	entries, err := loadEntries(filepath.Join(dir, logFile))
+	if err != nil {
+		return nil, fmt.Errorf("loading log entries: %w", err)
+	}
+	l.entries = entries
+	// Rewriting drops any torn entry at the tail, so that appends start from a clean frame.
+	if err := l.rewrite(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *raftLog) close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+func (l *raftLog) lastIndex() uint64 {
+	return uint64(len(l.entries))
+}
+
+func (l *raftLog) lastTerm() uint64 {
+	return l.termAt(l.lastIndex())
+}
+
+// termAt returns the term of the entry at index, where index 0 is the empty prefix of the
+// log and has term 0.
+func (l *raftLog) termAt(index uint64) uint64 {
+	if index == 0 || index > l.lastIndex() {
+		return 0
+	}
+	return l.entries[index-1].GetTerm()
+}
+
+func (l *raftLog) entry(index uint64) *raftpb.Entry {
+	return l.entries[index-1]
+}
+
+// from returns up to limit entries starting at index.
+func (l *raftLog) from(index uint64, limit int) []*raftpb.Entry {
+	if index > l.lastIndex() {
+		return nil
+	}
+	entries := l.entries[index-1:]
+	return entries[:min(limit, len(entries))]
+}
+
+func (l *raftLog) setState(term, vote uint64) error {
+	if term == l.term && vote == l.vote {
+		return nil
+	}
+	l.term = term
+	l.vote = vote
+	if l.dir == "" {
+		return nil
+	}
+
+	path := filepath.Join(l.dir, stateFile)
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], term)
+	binary.LittleEndian.PutUint64(buf[8:], vote)
+	if err := writeFileSynced(path+".tmp", buf[:]); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("publishing state: %w", err)
+	}
+	return nil
+}
+
+func (l *raftLog) append(entries ...*raftpb.Entry) error {
+	l.entries = append(l.entries, entries...)
+	if l.file == nil {
+		return nil
+	}
+	var buf []byte
+	for _, e := range entries {
+		frame, err := encodeEntry(e)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, frame...)
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return fmt.Errorf("writing entries: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("syncing entries: %w", err)
+	}
+	return nil
+}
+
+// truncate drops every entry from index onwards. Conflicting entries are rare, so the log
+// file is simply rewritten. The slice is clipped so that later appends do not overwrite the
+// dropped entries, which requests built by from may still be sending.
+func (l *raftLog) truncate(index uint64) error {
+	l.entries = slices.Clip(l.entries[:index-1])
+	return l.rewrite()
+}
+
+func (l *raftLog) rewrite() error {
+	if l.dir == "" {
+		return nil
+	}
+	path := filepath.Join(l.dir, logFile)
+	var buf []byte
+	for _, e := range l.entries {
+		frame, err := encodeEntry(e)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, frame...)
+	}
+	if err := writeFileSynced(path+".tmp", buf); err != nil {
+		return fmt.Errorf("writing log: %w", err)
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("publishing log: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log: %w", err)
+	}
+	l.file = file
+	return nil
+}
+
+func (l *raftLog) loadState() error {
+	contents, err := os.ReadFile(filepath.Join(l.dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading state file: %w", err)
+	}
+	if len(contents) != 16 {
+		return fmt.Errorf("state file has %d bytes, expected 16", len(contents))
+	}
+	l.term = binary.LittleEndian.Uint64(contents[:8])
+	l.vote = binary.LittleEndian.Uint64(contents[8:])
+	return nil
+}
+
+// loadEntries reads the log back. A crash mid-append can only tear the last frame, which is
+// dropped. Every other entry may have been acknowledged to the leader and counted towards a
+// commit, so any other damage fails loading rather than silently losing entries.
+func loadEntries(path string) ([]*raftpb.Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening log: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading log size: %w", err)
+	}
+
+	var entries []*raftpb.Entry
+	var offset int64
+	reader := bufio.NewReader(file)
+	for {
+		var header [entryHeaderSize]byte
+		_, err := io.ReadFull(reader, header[:])
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			fmt.Printf("dropping torn raft log frame after index %d\n", len(entries))
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading frame of entry %d: %w", len(entries)+1, err)
+		}
+		if crc32.Checksum(header[:8], crcTable) != binary.LittleEndian.Uint32(header[8:]) {
+			return nil, fmt.Errorf("corrupt frame header of entry %d", len(entries)+1)
+		}
+		length := binary.LittleEndian.Uint32(header[:4])
+		end := offset + entryHeaderSize + int64(length)
+		if length > maxEntrySize {
+			return nil, fmt.Errorf("frame of entry %d has %d bytes, more than the maximum of %d", len(entries)+1, length, maxEntrySize)
+		}
+		payload := make([]byte, length)
+		_, err = io.ReadFull(reader, payload)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			fmt.Printf("dropping torn raft log frame after index %d\n", len(entries))
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading entry %d: %w", len(entries)+1, err)
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			if end == info.Size() {
+				fmt.Printf("dropping torn raft log frame after index %d, checksum mismatch\n", len(entries))
+				return entries, nil
+			}
+			return nil, fmt.Errorf("checksum mismatch in entry %d", len(entries)+1)
+		}
+		entry := &raftpb.Entry{}
+		if err := proto.Unmarshal(payload, entry); err != nil {
+			return nil, fmt.Errorf("decoding entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+		offset = end
+	}
+}
+
+func encodeEntry(entry *raftpb.Entry) ([]byte, error) {
+	payload, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("encoding entry %d: %w", entry.GetIndex(), err)
+	}
+	if len(payload) > maxEntrySize {
+		return nil, fmt.Errorf("entry %d has %d bytes, more than the maximum of %d", entry.GetIndex(), len(payload), maxEntrySize)
+	}
+	frame := make([]byte, entryHeaderSize, entryHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	binary.LittleEndian.PutUint32(frame[8:], crc32.Checksum(frame[:8], crcTable))
+	return append(frame, payload...), nil
+}
+
+func writeFileSynced(path string, contents []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(contents); err != nil {
+		return err
+	}
+	return file.Sync()
+}
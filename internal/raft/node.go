@@ -0,0 +1,888 @@
+package raft
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/raft/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// The most entries sent in a single AppendEntries call.
+const maxAppendEntries = 64
+
+var (
+	ErrNoLeader       = errors.New("no leader is known")
+	ErrLeadershipLost = errors.New("leadership changed before the entry was committed")
+
+	errNotLeader = errors.New("this node is not the leader")
+)
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// StateMachine receives every committed command, in log order, exactly once per process.
+type StateMachine interface {
+	Apply(command []byte) error
+}
+
+type Config struct {
+	// How often a leader sends AppendEntries, even when there is nothing to replicate.
+	HeartbeatInterval time.Duration
+	// A follower that hears nothing from a leader for between one and two election timeouts
+	// starts an election.
+	ElectionTimeout time.Duration
+	Secure          bool
+}
+
+func DefaultConfig(secure bool) Config {
+	return Config{
+		HeartbeatInterval: time.Millisecond * 100,
+		ElectionTimeout:   time.Second,
+		Secure:            secure,
+	}
+}
+
+// Node is a single Raft server. Servers only vote and count towards quorums once a
+// configuration entry naming them has reached their log, so a new node stays passive until
+// an existing member adds it with AddServer, or until it bootstraps a new cluster.
+type Node struct {
+	id      uint64
+	address string
+	config  Config
+
+	lock              sync.Mutex
+	log               *raftLog
+	role              role
+	leaderId          uint64
+	leaderAddress     string
+	lastLeaderContact time.Time
+	electionDeadline  time.Time
+	servers           []*raftpb.Server
+	configIndex       uint64
+	commitIndex       uint64
+	lastApplied       uint64
+	nextIndex         map[uint64]uint64
+	matchIndex        map[uint64]uint64
+	// Closed and replaced whenever the commit index, applied index, role or leader changes.
+	changed chan struct{}
+	kick    chan struct{}
+
+	connsLock sync.Mutex
+	conns     map[string]*grpc.ClientConn
+}
+
+// NewNode opens the Raft state stored in dir. An empty dir keeps everything in memory.
+func NewNode(id uint64, address string, dir string, config Config) (*Node, error) {
+	log, err := openLog(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening raft log: %w", err)
+	}
+	n := &Node{
+		id:      id,
+		address: address,
+		config:  config,
+		log:     log,
+		changed: make(chan struct{}),
+		kick:    make(chan struct{}, 1),
+		conns:   map[string]*grpc.ClientConn{},
+	}
+	n.loadConfiguration()
+	return n, nil
+}
+
+// Bootstrap starts a new cluster with this node as its only member. It does nothing if the
+// node already has a log.
+func (n *Node) Bootstrap() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.log.lastIndex() > 0 {
+		return nil
+	}
+	if err := n.log.append(&raftpb.Entry{
+		Index:   1,
+		Kind:    raftpb.EntryKind_CONFIGURATION,
+		Servers: []*raftpb.Server{{Id: n.id, Address: n.address}},
+	}); err != nil {
+		return fmt.Errorf("appending initial configuration: %w", err)
+	}
+	n.loadConfiguration()
+	return nil
+}
+
+// Run drives elections and replication, applying committed commands to machine, until ctx
+// is done.
+func (n *Node) Run(ctx context.Context, machine StateMachine) {
+	go n.apply(ctx, machine)
+
+	n.lock.Lock()
+	n.resetElectionDeadline()
+	n.lock.Unlock()
+
+	ticker := time.NewTicker(n.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.closeConnections()
+			if err := n.log.close(); err != nil {
+				fmt.Printf("failed to close raft log: %s\n", err.Error())
+			}
+			return
+		case <-ticker.C:
+		case <-n.kick:
+		}
+
+		n.lock.Lock()
+		isLeader := n.role == leader
+		electionDue := !isLeader && n.isVoter(n.id) && time.Now().After(n.electionDeadline)
+		n.lock.Unlock()
+
+		if isLeader {
+			n.replicate(ctx)
+		} else if electionDue {
+			n.campaign(ctx)
+		}
+	}
+}
+
+// Leader returns the id and address of the current leader, if one is known.
+func (n *Node) Leader() (uint64, string, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.leaderId, n.leaderAddress, n.leaderId != 0
+}
+
+func (n *Node) Servers() []*raftpb.Server {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return slices.Clone(n.servers)
+}
+
+// Propose replicates command through the log and returns once this node has applied it.
+// Followers forward the command to the leader.
+func (n *Node) Propose(ctx context.Context, command []byte) error {
+	var index uint64
+	if err := n.onLeader(ctx, func() error {
+		var err error
+		index, err = n.proposeAsLeader(ctx, command)
+		return err
+	}, func(client raftpb.RaftClient) error {
+		response, err := client.Propose(ctx, &raftpb.ProposeRequest{Command: command})
+		if err != nil {
+			return err
+		}
+		index = response.GetIndex()
+		return nil
+	}); err != nil {
+		return err
+	}
+	return n.waitApplied(ctx, index)
+}
+
+// ReadBarrier returns once this node has applied every command that was committed before
+// the call, after which reading the state machine is linearizable.
+func (n *Node) ReadBarrier(ctx context.Context) error {
+	var index uint64
+	if err := n.onLeader(ctx, func() error {
+		var err error
+		index, err = n.readIndexAsLeader(ctx)
+		return err
+	}, func(client raftpb.RaftClient) error {
+		response, err := client.ReadIndex(ctx, &raftpb.ReadIndexRequest{})
+		if err != nil {
+			return err
+		}
+		index = response.GetIndex()
+		return nil
+	}); err != nil {
+		return err
+	}
+	return n.waitApplied(ctx, index)
+}
+
+// AddServer makes server a voting member of the cluster. Membership changes are applied one
+// server at a time, later changes wait for earlier ones to commit.
+func (n *Node) AddServer(ctx context.Context, server *raftpb.Server) error {
+	return n.onLeader(ctx, func() error {
+		return n.addServerAsLeader(ctx, server)
+	}, func(client raftpb.RaftClient) error {
+		_, err := client.AddServer(ctx, &raftpb.AddServerRequest{Server: server})
+		return err
+	})
+}
+
+func (n *Node) RemoveServer(ctx context.Context, id uint64) error {
+	return n.onLeader(ctx, func() error {
+		return n.removeServerAsLeader(ctx, id)
+	}, func(client raftpb.RaftClient) error {
+		_, err := client.RemoveServer(ctx, &raftpb.RemoveServerRequest{Id: id})
+		return err
+	})
+}
+
+// JoinWithRetry asks the cluster, through any of seeds, to add this node until one of them
+// succeeds or ctx is done.
+func (n *Node) JoinWithRetry(ctx context.Context, seeds []string) {
+	self := &raftpb.Server{Id: n.id, Address: n.address}
+	ticker := time.NewTicker(n.config.ElectionTimeout)
+	defer ticker.Stop()
+	for {
+		for _, seed := range seeds {
+			if err := n.call(seed, func(client raftpb.RaftClient) error {
+				_, err := client.AddServer(ctx, &raftpb.AddServerRequest{Server: self})
+				return err
+			}); err != nil {
+				fmt.Printf("Failed to join raft cluster through %s: %s\n", seed, err.Error())
+				continue
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Node) proposeAsLeader(ctx context.Context, command []byte) (uint64, error) {
+	return n.appendAsLeader(ctx, func() (*raftpb.Entry, error) {
+		return &raftpb.Entry{
+			Kind:    raftpb.EntryKind_COMMAND,
+			Command: command,
+		}, nil
+	})
+}
+
+func (n *Node) addServerAsLeader(ctx context.Context, server *raftpb.Server) error {
+	_, err := n.appendAsLeader(ctx, func() (*raftpb.Entry, error) {
+		if slices.ContainsFunc(n.servers, func(s *raftpb.Server) bool {
+			return proto.Equal(s, server)
+		}) {
+			return nil, nil
+		}
+		// A server that comes back at a new address replaces its old entry.
+		servers := slices.DeleteFunc(slices.Clone(n.servers), func(s *raftpb.Server) bool {
+			return s.GetId() == server.GetId()
+		})
+		return &raftpb.Entry{
+			Kind:    raftpb.EntryKind_CONFIGURATION,
+			Servers: append(servers, server),
+		}, nil
+	})
+	return err
+}
+
+func (n *Node) removeServerAsLeader(ctx context.Context, id uint64) error {
+	_, err := n.appendAsLeader(ctx, func() (*raftpb.Entry, error) {
+		servers := slices.DeleteFunc(slices.Clone(n.servers), func(s *raftpb.Server) bool {
+			return s.GetId() == id
+		})
+		if len(servers) == len(n.servers) {
+			return nil, nil
+		}
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("cannot remove the last server")
+		}
+		return &raftpb.Entry{
+			Kind:    raftpb.EntryKind_CONFIGURATION,
+			Servers: servers,
+		}, nil
+	})
+	return err
+}
+
+// appendAsLeader appends the entry built by build to the log and waits for it to commit.
+// A nil entry means there is nothing to do. Configuration changes are only accepted once the
+// previous one has committed, and once this leader has committed an entry of its own term.
+func (n *Node) appendAsLeader(ctx context.Context, build func() (*raftpb.Entry, error)) (uint64, error) {
+	n.lock.Lock()
+	for {
+		if n.role != leader {
+			n.lock.Unlock()
+			return 0, errNotLeader
+		}
+		if n.log.termAt(n.commitIndex) == n.log.term && n.configIndex <= n.commitIndex {
+			break
+		}
+		changed := n.changed
+		n.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("waiting for the leader to settle: %w", ctx.Err())
+		case <-changed:
+		}
+		n.lock.Lock()
+	}
+
+	entry, err := build()
+	if err != nil || entry == nil {
+		n.lock.Unlock()
+		return 0, err
+	}
+	entry.Term = n.log.term
+	entry.Index = n.log.lastIndex() + 1
+	if err := n.log.append(entry); err != nil {
+		n.lock.Unlock()
+		return 0, fmt.Errorf("appending entry: %w", err)
+	}
+	if entry.GetKind() == raftpb.EntryKind_CONFIGURATION {
+		n.loadConfiguration()
+	}
+	n.matchIndex[n.id] = entry.Index
+	n.advanceCommit()
+	n.lock.Unlock()
+	n.triggerReplication()
+
+	if err := n.waitCommitted(ctx, entry.Index, entry.Term); err != nil {
+		return 0, err
+	}
+	return entry.Index, nil
+}
+
+// readIndexAsLeader returns the current commit index once this node has confirmed with a
+// quorum that it is still the leader, so that no newer leader can have committed anything.
+func (n *Node) readIndexAsLeader(ctx context.Context) (uint64, error) {
+	n.lock.Lock()
+	for {
+		if n.role != leader {
+			n.lock.Unlock()
+			return 0, errNotLeader
+		}
+		// Until the leader commits an entry of its own term it may not know the latest
+		// commit index.
+		if n.log.termAt(n.commitIndex) == n.log.term {
+			break
+		}
+		changed := n.changed
+		n.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("waiting for the leader to commit its first entry: %w", ctx.Err())
+		case <-changed:
+		}
+		n.lock.Lock()
+	}
+	index := n.commitIndex
+	needed := n.quorum()
+	if n.isVoter(n.id) {
+		needed--
+	}
+	n.lock.Unlock()
+
+	acks, sent := n.replicate(ctx)
+	confirmed := 0
+	for range sent {
+		if confirmed >= needed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("confirming leadership: %w", ctx.Err())
+		case ok := <-acks:
+			if ok {
+				confirmed++
+			}
+		}
+	}
+	if confirmed < needed {
+		return 0, ErrLeadershipLost
+	}
+	return index, nil
+}
+
+func (n *Node) campaign(ctx context.Context) {
+	n.lock.Lock()
+	if err := n.log.setState(n.log.term+1, n.id); err != nil {
+		n.lock.Unlock()
+		fmt.Printf("Failed to persist raft vote: %s\n", err.Error())
+		return
+	}
+	n.role = candidate
+	n.leaderId = 0
+	n.leaderAddress = ""
+	n.resetElectionDeadline()
+	term := n.log.term
+	request := &raftpb.RequestVoteRequest{
+		Term:         term,
+		CandidateId:  n.id,
+		LastLogIndex: n.log.lastIndex(),
+		LastLogTerm:  n.log.lastTerm(),
+	}
+	others := n.otherServers()
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+	}
+	n.lock.Unlock()
+
+	fmt.Printf("starting raft election for term %d\n", term)
+	for _, server := range others {
+		go func() {
+			var response *raftpb.RequestVoteResponse
+			err := n.call(server.GetAddress(), func(client raftpb.RaftClient) error {
+				ctx, cancel := context.WithTimeout(ctx, n.config.ElectionTimeout)
+				defer cancel()
+				var err error
+				response, err = client.RequestVote(ctx, request)
+				return err
+			})
+			if err != nil {
+				return
+			}
+
+			n.lock.Lock()
+			defer n.lock.Unlock()
+			if response.GetTerm() > n.log.term {
+				n.stepDown(response.GetTerm())
+				return
+			}
+			if n.role != candidate || n.log.term != term || !response.GetVoteGranted() {
+				return
+			}
+			votes++
+			if votes == n.quorum() {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+// becomeLeader must be called with the lock held.
+func (n *Node) becomeLeader() {
+	fmt.Printf("became raft leader for term %d\n", n.log.term)
+	n.role = leader
+	n.leaderId = n.id
+	n.leaderAddress = n.address
+	n.nextIndex = map[uint64]uint64{}
+	n.matchIndex = map[uint64]uint64{}
+	for _, s := range n.servers {
+		n.nextIndex[s.GetId()] = n.log.lastIndex() + 1
+	}
+
+	noop := &raftpb.Entry{
+		Term:  n.log.term,
+		Index: n.log.lastIndex() + 1,
+		Kind:  raftpb.EntryKind_NOOP,
+	}
+	if err := n.log.append(noop); err != nil {
+		fmt.Printf("Failed to append no-op entry, stepping down: %s\n", err.Error())
+		n.role = follower
+		return
+	}
+	n.matchIndex[n.id] = noop.Index
+	n.advanceCommit()
+	n.notifyChanged()
+	n.triggerReplication()
+}
+
+// replicate sends AppendEntries to every other server. Each response reports on acks
+// whether that server still accepts this node as its leader.
+func (n *Node) replicate(ctx context.Context) (<-chan bool, int) {
+	n.lock.Lock()
+	if n.role != leader {
+		n.lock.Unlock()
+		return nil, 0
+	}
+	others := n.otherServers()
+	requests := make([]*raftpb.AppendEntriesRequest, len(others))
+	for i, server := range others {
+		next, known := n.nextIndex[server.GetId()]
+		if !known {
+			next = n.log.lastIndex() + 1
+			n.nextIndex[server.GetId()] = next
+		}
+		requests[i] = &raftpb.AppendEntriesRequest{
+			Term:          n.log.term,
+			LeaderId:      n.id,
+			LeaderAddress: n.address,
+			PrevLogIndex:  next - 1,
+			PrevLogTerm:   n.log.termAt(next - 1),
+			Entries:       n.log.from(next, maxAppendEntries),
+			LeaderCommit:  n.commitIndex,
+		}
+	}
+	n.lock.Unlock()
+
+	acks := make(chan bool, len(others))
+	for i, server := range others {
+		go func() {
+			acks <- n.sendAppendEntries(ctx, server, requests[i])
+		}()
+	}
+	return acks, len(others)
+}
+
+func (n *Node) sendAppendEntries(ctx context.Context, server *raftpb.Server, request *raftpb.AppendEntriesRequest) bool {
+	var response *raftpb.AppendEntriesResponse
+	if err := n.call(server.GetAddress(), func(client raftpb.RaftClient) error {
+		ctx, cancel := context.WithTimeout(ctx, n.config.ElectionTimeout)
+		defer cancel()
+		var err error
+		response, err = client.AppendEntries(ctx, request)
+		return err
+	}); err != nil {
+		return false
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if response.GetTerm() > n.log.term {
+		n.stepDown(response.GetTerm())
+		return false
+	}
+	if n.role != leader || n.log.term != request.GetTerm() {
+		return false
+	}
+
+	id := server.GetId()
+	if response.GetSuccess() {
+		match := request.GetPrevLogIndex() + uint64(len(request.GetEntries()))
+		if match > n.matchIndex[id] {
+			n.matchIndex[id] = match
+		}
+		n.nextIndex[id] = max(n.nextIndex[id], match+1)
+		n.advanceCommit()
+		if n.nextIndex[id] <= n.log.lastIndex() {
+			n.triggerReplication()
+		}
+	} else if n.nextIndex[id] == request.GetPrevLogIndex()+1 {
+		// Jump back to the end of the follower's log if it is shorter, otherwise step back
+		// one entry at a time until the logs agree.
+		n.nextIndex[id] = max(1, min(request.GetPrevLogIndex(), response.GetLastLogIndex()+1))
+		n.triggerReplication()
+	}
+	return true
+}
+
+// advanceCommit commits the newest entry of the current term that a quorum has stored. It
+// must be called with the lock held.
+func (n *Node) advanceCommit() {
+	for index := n.log.lastIndex(); index > n.commitIndex; index-- {
+		if n.log.termAt(index) != n.log.term {
+			return
+		}
+		stored := 0
+		for _, s := range n.servers {
+			if n.matchIndex[s.GetId()] >= index {
+				stored++
+			}
+		}
+		if stored >= n.quorum() {
+			n.setCommitIndex(index)
+			return
+		}
+	}
+}
+
+func (n *Node) setCommitIndex(index uint64) {
+	if index <= n.commitIndex {
+		return
+	}
+	n.commitIndex = index
+	n.notifyChanged()
+	// A leader that removed itself keeps leading until the change commits, then leaves the
+	// remaining servers to elect a new leader.
+	if n.role == leader && !n.isVoter(n.id) && n.configIndex <= n.commitIndex {
+		fmt.Printf("stepping down as raft leader after being removed from the cluster\n")
+		n.role = follower
+		n.leaderId = 0
+		n.leaderAddress = ""
+	}
+}
+
+func (n *Node) handleRequestVote(request *raftpb.RequestVoteRequest) (*raftpb.RequestVoteResponse, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	// Servers that were removed from the cluster no longer hear from the leader and keep
+	// starting elections. Ignoring them while a leader is known to be alive stops them from
+	// disrupting the cluster.
+	leaderAlive := n.role == leader || (n.leaderId != 0 && time.Since(n.lastLeaderContact) < n.config.ElectionTimeout)
+	if request.GetTerm() > n.log.term && leaderAlive {
+		return &raftpb.RequestVoteResponse{Term: n.log.term}, nil
+	}
+	if request.GetTerm() > n.log.term {
+		n.stepDown(request.GetTerm())
+	}
+	if request.GetTerm() < n.log.term {
+		return &raftpb.RequestVoteResponse{Term: n.log.term}, nil
+	}
+
+	upToDate := request.GetLastLogTerm() > n.log.lastTerm() ||
+		(request.GetLastLogTerm() == n.log.lastTerm() && request.GetLastLogIndex() >= n.log.lastIndex())
+	canVote := n.log.vote == 0 || n.log.vote == request.GetCandidateId()
+	if !upToDate || !canVote {
+		return &raftpb.RequestVoteResponse{Term: n.log.term}, nil
+	}
+	if err := n.log.setState(n.log.term, request.GetCandidateId()); err != nil {
+		return nil, fmt.Errorf("persisting vote: %w", err)
+	}
+	n.resetElectionDeadline()
+	return &raftpb.RequestVoteResponse{Term: n.log.term, VoteGranted: true}, nil
+}
+
+func (n *Node) handleAppendEntries(request *raftpb.AppendEntriesRequest) (*raftpb.AppendEntriesResponse, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if request.GetTerm() < n.log.term {
+		return &raftpb.AppendEntriesResponse{Term: n.log.term, LastLogIndex: n.log.lastIndex()}, nil
+	}
+	if request.GetTerm() > n.log.term || n.role != follower {
+		n.stepDown(request.GetTerm())
+	}
+	if n.leaderId != request.GetLeaderId() {
+		n.leaderId = request.GetLeaderId()
+		n.leaderAddress = request.GetLeaderAddress()
+		n.notifyChanged()
+	}
+	n.lastLeaderContact = time.Now()
+	n.resetElectionDeadline()
+
+	prev := request.GetPrevLogIndex()
+	if prev > n.log.lastIndex() {
+		return &raftpb.AppendEntriesResponse{Term: n.log.term, LastLogIndex: n.log.lastIndex()}, nil
+	}
+	if n.log.termAt(prev) != request.GetPrevLogTerm() {
+		return &raftpb.AppendEntriesResponse{Term: n.log.term, LastLogIndex: prev - 1}, nil
+	}
+
+	configChanged := false
+	for i, entry := range request.GetEntries() {
+		index := prev + 1 + uint64(i)
+		if index <= n.log.lastIndex() {
+			if n.log.termAt(index) == entry.GetTerm() {
+				continue
+			}
+			if err := n.log.truncate(index); err != nil {
+				return nil, fmt.Errorf("truncating conflicting entries: %w", err)
+			}
+			configChanged = configChanged || n.configIndex >= index
+		}
+		newEntries := request.GetEntries()[i:]
+		if err := n.log.append(newEntries...); err != nil {
+			return nil, fmt.Errorf("appending entries: %w", err)
+		}
+		configChanged = configChanged || slices.ContainsFunc(newEntries, func(e *raftpb.Entry) bool {
+			return e.GetKind() == raftpb.EntryKind_CONFIGURATION
+		})
+		break
+	}
+	if configChanged {
+		n.loadConfiguration()
+	}
+
+	lastNew := prev + uint64(len(request.GetEntries()))
+	n.setCommitIndex(min(request.GetLeaderCommit(), lastNew))
+	return &raftpb.AppendEntriesResponse{Term: n.log.term, Success: true, LastLogIndex: n.log.lastIndex()}, nil
+}
+
+// stepDown moves to term as a follower. It must be called with the lock held.
+func (n *Node) stepDown(term uint64) {
+	if term > n.log.term {
+		if err := n.log.setState(term, 0); err != nil {
+			fmt.Printf("Failed to persist raft term: %s\n", err.Error())
+		}
+		n.leaderId = 0
+		n.leaderAddress = ""
+	}
+	if n.role != follower {
+		n.role = follower
+		n.notifyChanged()
+	}
+	n.resetElectionDeadline()
+}
+
+// loadConfiguration adopts the newest configuration entry in the log. Raft servers use the
+// newest configuration they have, whether or not it has committed.
+func (n *Node) loadConfiguration() {
+	n.servers = nil
+	n.configIndex = 0
+	for index := n.log.lastIndex(); index > 0; index-- {
+		if entry := n.log.entry(index); entry.GetKind() == raftpb.EntryKind_CONFIGURATION {
+			n.servers = entry.GetServers()
+			n.configIndex = index
+			return
+		}
+	}
+}
+
+func (n *Node) apply(ctx context.Context, machine StateMachine) {
+	for {
+		n.lock.Lock()
+		var entries []*raftpb.Entry
+		if n.lastApplied < n.commitIndex {
+			entries = slices.Clone(n.log.from(n.lastApplied+1, int(n.commitIndex-n.lastApplied)))
+		}
+		changed := n.changed
+		n.lock.Unlock()
+
+		if len(entries) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if entry.GetKind() == raftpb.EntryKind_COMMAND {
+				if err := machine.Apply(entry.GetCommand()); err != nil {
+					fmt.Printf("Failed to apply raft entry %d: %s\n", entry.GetIndex(), err.Error())
+				}
+			}
+			n.lock.Lock()
+			n.lastApplied = entry.GetIndex()
+			n.notifyChanged()
+			n.lock.Unlock()
+		}
+	}
+}
+
+func (n *Node) waitApplied(ctx context.Context, index uint64) error {
+	n.lock.Lock()
+	for n.lastApplied < index {
+		changed := n.changed
+		n.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting to apply index %d: %w", index, ctx.Err())
+		case <-changed:
+		}
+		n.lock.Lock()
+	}
+	n.lock.Unlock()
+	return nil
+}
+
+// waitCommitted waits for the entry at index to commit, failing if it is replaced by an
+// entry from another term first.
+func (n *Node) waitCommitted(ctx context.Context, index, term uint64) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for {
+		if n.log.termAt(index) != term {
+			return ErrLeadershipLost
+		}
+		if n.commitIndex >= index {
+			return nil
+		}
+		changed := n.changed
+		n.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			n.lock.Lock()
+			return fmt.Errorf("waiting to commit index %d: %w", index, ctx.Err())
+		case <-changed:
+		}
+		n.lock.Lock()
+	}
+}
+
+// onLeader runs local if this node is the leader and remote against the leader otherwise.
+// While no leader is known it waits for one to be elected.
+func (n *Node) onLeader(ctx context.Context, local func() error, remote func(raftpb.RaftClient) error) error {
+	for {
+		err := local()
+		if !errors.Is(err, errNotLeader) {
+			return err
+		}
+
+		n.lock.Lock()
+		address := n.leaderAddress
+		changed := n.changed
+		n.lock.Unlock()
+		if address != "" {
+			if err := n.call(address, remote); err != nil {
+				return fmt.Errorf("forwarding to leader at %s: %w", address, err)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrNoLeader, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+func (n *Node) notifyChanged() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+func (n *Node) triggerReplication() {
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) resetElectionDeadline() {
+	timeout := n.config.ElectionTimeout + rand.N(n.config.ElectionTimeout)
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+func (n *Node) isVoter(id uint64) bool {
+	return slices.ContainsFunc(n.servers, func(s *raftpb.Server) bool {
+		return s.GetId() == id
+	})
+}
+
+func (n *Node) quorum() int {
+	return len(n.servers)/2 + 1
+}
+
+func (n *Node) otherServers() []*raftpb.Server {
+	return slices.DeleteFunc(slices.Clone(n.servers), func(s *raftpb.Server) bool {
+		return s.GetId() == n.id
+	})
+}
+
+// call runs f against a connection to address that is kept open between calls.
+func (n *Node) call(address string, f func(raftpb.RaftClient) error) error {
+	n.connsLock.Lock()
+	conn, exists := n.conns[address]
+	if !exists {
+		var creds credentials.TransportCredentials
+		if n.config.Secure {
+			creds = credentials.NewTLS(&tls.Config{})
+		} else {
+			creds = insecure.NewCredentials()
+		}
+		var err error
+		conn, err = grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			n.connsLock.Unlock()
+			return fmt.Errorf("connecting to grpc server: %w", err)
+		}
+		n.conns[address] = conn
+	}
+	n.connsLock.Unlock()
+	return f(raftpb.NewRaftClient(conn))
+}
+
+func (n *Node) closeConnections() {
+	n.connsLock.Lock()
+	defer n.connsLock.Unlock()
+	for address, conn := range n.conns {
+		conn.Close()
+		delete(n.conns, address)
+	}
+}
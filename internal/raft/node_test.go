@@ -0,0 +1,230 @@
+package raft_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/raft/v1"
+	"github.com/WadeCappa/consensus/internal/raft"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type machine struct {
+	lock     sync.Mutex
+	commands []string
+}
+
+func (m *machine) Apply(command []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.commands = append(m.commands, string(command))
+	return nil
+}
+
+func (m *machine) applied() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return slices.Clone(m.commands)
+}
+
+type node struct {
+	*raft.Node
+	id      uint64
+	address string
+	machine *machine
+	server  *grpc.Server
+	cancel  context.CancelFunc
+}
+
+func (n *node) stop() {
+	n.cancel()
+	n.server.Stop()
+}
+
+var testConfig = raft.Config{
+	HeartbeatInterval: time.Millisecond * 10,
+	ElectionTimeout:   time.Millisecond * 100,
+}
+
+func startNode(t *testing.T, id uint64, dir string, listener net.Listener) *node {
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+	}
+	raftNode, err := raft.NewNode(id, listener.Addr().String(), dir, testConfig)
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	raftpb.RegisterRaftServer(server, raft.NewRaftServer(raftNode))
+	go server.Serve(listener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &node{
+		Node:    raftNode,
+		id:      id,
+		address: listener.Addr().String(),
+		machine: &machine{},
+		server:  server,
+		cancel:  cancel,
+	}
+	go raftNode.Run(ctx, n.machine)
+	t.Cleanup(n.stop)
+	return n
+}
+
+// startCluster bootstraps the first node and adds the rest one at a time.
+func startCluster(t *testing.T, size int) []*node {
+	var nodes []*node
+	for i := range size {
+		nodes = append(nodes, startNode(t, uint64(i+1), "", nil))
+	}
+	require.NoError(t, nodes[0].Bootstrap())
+	for _, n := range nodes[1:] {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		require.NoError(t, nodes[0].AddServer(ctx, &raftpb.Server{Id: n.id, Address: n.address}))
+		cancel()
+	}
+	return nodes
+}
+
+func leaderOf(t *testing.T, nodes []*node) *node {
+	var result *node
+	require.Eventually(t, func() bool {
+		for _, n := range nodes {
+			if id, _, ok := n.Leader(); ok && id == n.id {
+				result = n
+				return true
+			}
+		}
+		return false
+	}, time.Second*5, time.Millisecond*10)
+	return result
+}
+
+func TestProposalsAreAppliedEverywhereInOrder(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var expected []string
+	for i := range 10 {
+		command := fmt.Sprintf("command-%d", i)
+		expected = append(expected, command)
+		// Proposals made on followers are forwarded to the leader.
+		require.NoError(t, nodes[i%len(nodes)].Propose(ctx, []byte(command)))
+	}
+	for _, n := range nodes {
+		require.Eventually(t, func() bool {
+			return slices.Equal(expected, n.machine.applied())
+		}, time.Second*5, time.Millisecond*10)
+	}
+}
+
+func TestNewLeaderIsElectedAfterFailure(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, nodes[1].Propose(ctx, []byte("before")))
+
+	old := leaderOf(t, nodes)
+	old.stop()
+	survivors := slices.DeleteFunc(slices.Clone(nodes), func(n *node) bool {
+		return n == old
+	})
+	newLeader := leaderOf(t, survivors)
+	require.NotEqual(t, old.id, newLeader.id)
+
+	require.NoError(t, survivors[0].Propose(ctx, []byte("after")))
+	for _, n := range survivors {
+		require.NoError(t, n.ReadBarrier(ctx))
+		require.Equal(t, []string{"before", "after"}, n.machine.applied())
+	}
+}
+
+func TestReadBarrierSeesEarlierWrites(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	leader := leaderOf(t, nodes)
+	require.NoError(t, leader.Propose(ctx, []byte("write")))
+	for _, n := range nodes {
+		require.NoError(t, n.ReadBarrier(ctx))
+		require.Equal(t, []string{"write"}, n.machine.applied())
+	}
+}
+
+func TestRemovedServerStopsReceivingEntries(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	removed := nodes[2]
+	require.NoError(t, nodes[0].RemoveServer(ctx, removed.id))
+	require.Len(t, nodes[0].Servers(), 2)
+	require.NoError(t, nodes[1].Propose(ctx, []byte("without")))
+
+	// The remaining two still form a quorum on their own.
+	removed.stop()
+	require.NoError(t, nodes[0].Propose(ctx, []byte("still")))
+	require.Empty(t, removed.machine.applied())
+}
+
+func TestLogSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	first := startNode(t, 1, dir, nil)
+	require.NoError(t, first.Bootstrap())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, first.Propose(ctx, []byte("one")))
+	require.NoError(t, first.Propose(ctx, []byte("two")))
+	first.stop()
+
+	// Give the stopped node time to close its log before reopening it.
+	time.Sleep(time.Millisecond * 20)
+	listener, err := net.Listen("tcp", first.address)
+	require.NoError(t, err)
+	restarted := startNode(t, 1, dir, listener)
+	require.NoError(t, restarted.ReadBarrier(ctx))
+	require.Equal(t, []string{"one", "two"}, restarted.machine.applied())
+}
+
+func TestCorruptLogFailsStartup(t *testing.T) {
+	dir := t.TempDir()
+	first := startNode(t, 1, dir, nil)
+	require.NoError(t, first.Bootstrap())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, first.Propose(ctx, []byte("one")))
+	require.NoError(t, first.Propose(ctx, []byte("two")))
+	first.stop()
+	time.Sleep(time.Millisecond * 20)
+
+	path := filepath.Join(dir, "log")
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// A torn final frame is dropped.
+	require.NoError(t, os.WriteFile(path, append(slices.Clone(contents), 10, 0, 0, 0, 1), 0o644))
+	_, err = raft.NewNode(1, first.address, dir, testConfig)
+	require.NoError(t, err)
+
+	// Damage to an entry followed by others is not, even when it makes the first length run
+	// past the end of the file. Frames start with a 12 byte header.
+	for _, at := range []int{2, 12} {
+		corrupt := slices.Clone(contents)
+		corrupt[at] ^= 0xff
+		require.NoError(t, os.WriteFile(path, corrupt, 0o644))
+		_, err = raft.NewNode(1, first.address, dir, testConfig)
+		require.Error(t, err, "corrupting byte %d", at)
+	}
+}
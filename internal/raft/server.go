@@ -0,0 +1,74 @@
+package raft
+
+import (
+	"context"
+	"errors"
+
+	"github.com/WadeCappa/consensus/gen/go/raft/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type raftServer struct {
+	raftpb.UnimplementedRaftServer
+
+	node *Node
+}
+
+func NewRaftServer(node *Node) raftpb.RaftServer {
+	return &raftServer{node: node}
+}
+
+func (s *raftServer) RequestVote(ctx context.Context, request *raftpb.RequestVoteRequest) (*raftpb.RequestVoteResponse, error) {
+	return s.node.handleRequestVote(request)
+}
+
+func (s *raftServer) AppendEntries(ctx context.Context, request *raftpb.AppendEntriesRequest) (*raftpb.AppendEntriesResponse, error) {
+	return s.node.handleAppendEntries(request)
+}
+
+// Propose and ReadIndex are only served by the leader. A follower receiving one refuses it
+// rather than forwarding it again, so that nodes with different ideas of the leader cannot
+// bounce a request between them. Membership changes are forwarded so that joining nodes can
+// go through any member.
+
+func (s *raftServer) Propose(ctx context.Context, request *raftpb.ProposeRequest) (*raftpb.ProposeResponse, error) {
+	index, err := s.node.proposeAsLeader(ctx, request.GetCommand())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &raftpb.ProposeResponse{Index: index}, nil
+}
+
+func (s *raftServer) ReadIndex(ctx context.Context, request *raftpb.ReadIndexRequest) (*raftpb.ReadIndexResponse, error) {
+	index, err := s.node.readIndexAsLeader(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &raftpb.ReadIndexResponse{Index: index}, nil
+}
+
+func (s *raftServer) AddServer(ctx context.Context, request *raftpb.AddServerRequest) (*raftpb.AddServerResponse, error) {
+	if err := s.node.AddServer(ctx, request.GetServer()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &raftpb.AddServerResponse{}, nil
+}
+
+func (s *raftServer) RemoveServer(ctx context.Context, request *raftpb.RemoveServerRequest) (*raftpb.RemoveServerResponse, error) {
+	if err := s.node.RemoveServer(ctx, request.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &raftpb.RemoveServerResponse{}, nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, errNotLeader), errors.Is(err, ErrNoLeader), errors.Is(err, ErrLeadershipLost):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,125 @@
+package strong
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WadeCappa/consensus/gen/go/clocks/v1"
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/raft"
+	"google.golang.org/protobuf/proto"
+)
+
+// Keyspace holds every key under a prefix whose writes go through the Raft log instead of
+// being merged with vector clocks. Each node applies the log to its own copy of the records,
+// so all nodes assign the same versions to the same writes.
+type Keyspace struct {
+	prefix string
+	local  db.Incarnation
+	node   *raft.Node
+	data   *db.Database
+}
+
+func NewKeyspace(prefix string, local db.Incarnation, node *raft.Node) *Keyspace {
+	return &Keyspace{
+		prefix: prefix,
+		local:  local,
+		node:   node,
+		data:   db.NewDatabase(local),
+	}
+}
+
+// Owns reports whether key, or every key starting with a prefix, lives in this keyspace.
+func (k *Keyspace) Owns(key string) bool {
+	return strings.HasPrefix(key, k.prefix)
+}
+
+// Data holds the records applied so far. Call Barrier first for a linearizable read.
+func (k *Keyspace) Data() db.Storage {
+	return k.data
+}
+
+func (k *Keyspace) Barrier(ctx context.Context) error {
+	return k.node.ReadBarrier(ctx)
+}
+
+func (k *Keyspace) Put(ctx context.Context, key string, data []byte, writeTime time.Time) error {
+	return k.propose(ctx, key, &clockspb.Chunk{
+		Data:                data,
+		NodeId:              k.local.NodeId,
+		Epoch:               k.local.Epoch,
+		WriteTimeUnixMillis: uint64(writeTime.UnixMilli()),
+	})
+}
+
+// Delete fails with db.ErrNotFound if the key has no live data. Deletes racing with each
+// other both succeed, the second one does nothing.
+func (k *Keyspace) Delete(ctx context.Context, key string, deleteTime time.Time) error {
+	if err := k.Barrier(ctx); err != nil {
+		return fmt.Errorf("waiting for read barrier: %w", err)
+	}
+	record, exists, err := k.data.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	if !exists || len(record.Live()) == 0 {
+		return fmt.Errorf("%w: %s", db.ErrNotFound, key)
+	}
+	return k.propose(ctx, key, &clockspb.Chunk{
+		NodeId:              k.local.NodeId,
+		Epoch:               k.local.Epoch,
+		WriteTimeUnixMillis: uint64(deleteTime.UnixMilli()),
+		Tombstone:           true,
+	})
+}
+
+func (k *Keyspace) propose(ctx context.Context, key string, chunk *clockspb.Chunk) error {
+	command, err := proto.Marshal(&clockspb.PublishRequest{
+		Key:    key,
+		Chunks: []*clockspb.Chunk{chunk},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding command: %w", err)
+	}
+	if err := k.node.Propose(ctx, command); err != nil {
+		return fmt.Errorf("proposing write: %w", err)
+	}
+	return nil
+}
+
+// Apply appends the chunk in command to its record. The chunk's version is only assigned
+// here, from the record as of this point in the log, so it is the same on every node.
+func (k *Keyspace) Apply(command []byte) error {
+	request := &clockspb.PublishRequest{}
+	if err := proto.Unmarshal(command, request); err != nil {
+		return fmt.Errorf("decoding command: %w", err)
+	}
+	if len(request.GetChunks()) != 1 {
+		return fmt.Errorf("expected a single chunk, got %d", len(request.GetChunks()))
+	}
+	chunk := request.GetChunks()[0]
+
+	record, exists, err := k.data.Get(request.GetKey())
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+	clock := db.EmptyClock()
+	if exists {
+		clock = record.Clock
+	}
+	if chunk.GetTombstone() && (!exists || len(record.Live()) == 0) {
+		return nil
+	}
+
+	writer := db.Incarnation{NodeId: chunk.GetNodeId(), Epoch: chunk.GetEpoch()}
+	entries := clock.Entries()
+	entries[writer]++
+	writeTime := time.UnixMilli(int64(chunk.GetWriteTimeUnixMillis()))
+	applied := db.NewChunkFrom(writer, entries[writer], writeTime, chunk.GetData())
+	if chunk.GetTombstone() {
+		applied = db.NewTombstoneFrom(writer, entries[writer], writeTime)
+	}
+	return k.data.Merge(request.GetKey(), k.data.FromEntries(entries), []*db.Chunk{applied})
+}
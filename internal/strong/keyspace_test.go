@@ -0,0 +1,70 @@
+package strong_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WadeCappa/consensus/internal/db"
+	"github.com/WadeCappa/consensus/internal/raft"
+	"github.com/WadeCappa/consensus/internal/strong"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyspace(t *testing.T) *strong.Keyspace {
+	local := db.Incarnation{NodeId: 1, Epoch: 1}
+	node, err := raft.NewNode(local.NodeId, "localhost:0", "", raft.Config{
+		HeartbeatInterval: time.Millisecond * 10,
+		ElectionTimeout:   time.Millisecond * 20,
+	})
+	require.NoError(t, err)
+	require.NoError(t, node.Bootstrap())
+
+	keyspace := strong.NewKeyspace("strong/", local, node)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go node.Run(ctx, keyspace)
+	return keyspace
+}
+
+func TestKeyspaceAppliesWritesThroughLog(t *testing.T) {
+	keyspace := newKeyspace(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	require.True(t, keyspace.Owns("strong/key"))
+	require.False(t, keyspace.Owns("other"))
+
+	require.NoError(t, keyspace.Put(ctx, "strong/key", []byte("first"), time.Now()))
+	require.NoError(t, keyspace.Put(ctx, "strong/key", []byte("-second"), time.Now()))
+	require.NoError(t, keyspace.Barrier(ctx))
+	record, exists, err := keyspace.Data().Get("strong/key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("first-second"), db.Concat(record.Live()))
+	require.Equal(t, uint64(2), record.GetWriterVersion(db.Incarnation{NodeId: 1, Epoch: 1}))
+
+	require.NoError(t, keyspace.Delete(ctx, "strong/key", time.Now()))
+	record, _, err = keyspace.Data().Get("strong/key")
+	require.NoError(t, err)
+	require.Empty(t, record.Live())
+
+	err = keyspace.Delete(ctx, "strong/key", time.Now())
+	require.True(t, errors.Is(err, db.ErrNotFound))
+}
+
+func TestKeyspaceAppliesWritesRegardlessOfLocalTime(t *testing.T) {
+	keyspace := newKeyspace(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// A proposer whose clock runs far ahead of ours still gets its writes applied here, or
+	// this replica would diverge from the rest.
+	require.NoError(t, keyspace.Put(ctx, "strong/key", []byte("ahead"), time.Now().Add(time.Hour)))
+	require.NoError(t, keyspace.Barrier(ctx))
+	record, exists, err := keyspace.Data().Get("strong/key")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []byte("ahead"), db.Concat(record.Live()))
+}
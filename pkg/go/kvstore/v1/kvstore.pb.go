@@ -21,10 +21,67 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// How many replicas, including the node receiving the request, must take part in a read
+// or write before it completes. Ignored for keys in the strongly consistent keyspace, whose
+// writes always commit to a majority and whose reads are always linearizable.
+type Consistency int32
+
+const (
+	Consistency_ONE    Consistency = 0
+	Consistency_QUORUM Consistency = 1
+	Consistency_ALL    Consistency = 2
+)
+
+// Enum value maps for Consistency.
+var (
+	Consistency_name = map[int32]string{
+		0: "ONE",
+		1: "QUORUM",
+		2: "ALL",
+	}
+	Consistency_value = map[string]int32{
+		"ONE":    0,
+		"QUORUM": 1,
+		"ALL":    2,
+	}
+)
+
+func (x Consistency) Enum() *Consistency {
+	p := new(Consistency)
+	*p = x
+	return p
+}
+
+func (x Consistency) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Consistency) Descriptor() protoreflect.EnumDescriptor {
+	return file_kvstore_v1_kvstore_proto_enumTypes[0].Descriptor()
+}
+
+func (Consistency) Type() protoreflect.EnumType {
+	return &file_kvstore_v1_kvstore_proto_enumTypes[0]
+}
+
+func (x Consistency) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Consistency.Descriptor instead.
+func (Consistency) EnumDescriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{0}
+}
+
 type PutRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Update        []byte                 `protobuf:"bytes,2,opt,name=update,proto3" json:"update,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Key    string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Update []byte                 `protobuf:"bytes,2,opt,name=update,proto3" json:"update,omitempty"`
+	// When set, the put is only applied if the key's current clock is equal to this clock. An
+	// empty clock matches a key that has never been written. Not supported on strongly
+	// consistent keys.
+	ExpectedClock *VectorClock `protobuf:"bytes,3,opt,name=expectedClock,proto3" json:"expectedClock,omitempty"`
+	Consistency   Consistency  `protobuf:"varint,4,opt,name=consistency,proto3,enum=kvstore.Consistency" json:"consistency,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,8 +130,25 @@ func (x *PutRequest) GetUpdate() []byte {
 	return nil
 }
 
+func (x *PutRequest) GetExpectedClock() *VectorClock {
+	if x != nil {
+		return x.ExpectedClock
+	}
+	return nil
+}
+
+func (x *PutRequest) GetConsistency() Consistency {
+	if x != nil {
+		return x.Consistency
+	}
+	return Consistency_ONE
+}
+
 type PutResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// An opaque causality token covering this put. Pass it as the minToken of a later Get,
+	// on any node, to be sure that Get observes the put.
+	Token         []byte `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -109,9 +183,20 @@ func (*PutResponse) Descriptor() ([]byte, []int) {
 	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{1}
 }
 
+func (x *PutResponse) GetToken() []byte {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
 type GetRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// A token from a previous PutResponse or GetResponse. The node waits, for at most the
+	// request deadline, until its copy of the key has caught up with the token.
+	MinToken      []byte      `protobuf:"bytes,2,opt,name=minToken,proto3" json:"minToken,omitempty"`
+	Consistency   Consistency `protobuf:"varint,3,opt,name=consistency,proto3,enum=kvstore.Consistency" json:"consistency,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,14 +238,32 @@ func (x *GetRequest) GetKey() string {
 	return ""
 }
 
+func (x *GetRequest) GetMinToken() []byte {
+	if x != nil {
+		return x.MinToken
+	}
+	return nil
+}
+
+func (x *GetRequest) GetConsistency() Consistency {
+	if x != nil {
+		return x.Consistency
+	}
+	return Consistency_ONE
+}
+
 type GetResponse struct {
 	state               protoimpl.MessageState `protogen:"open.v1"`
 	Data                []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	NodeId              uint64                 `protobuf:"varint,2,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
 	Version             uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
 	WriteTimeUnixMillis uint64                 `protobuf:"varint,4,opt,name=writeTimeUnixMillis,proto3" json:"writeTimeUnixMillis,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+	// An opaque causality token for the state of the key that was read. It is the same on
+	// every response of a stream.
+	Token         []byte `protobuf:"bytes,5,opt,name=token,proto3" json:"token,omitempty"`
+	Epoch         uint64 `protobuf:"varint,6,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetResponse) Reset() {
@@ -221,27 +324,811 @@ func (x *GetResponse) GetWriteTimeUnixMillis() uint64 {
 	return 0
 }
 
-var File_kvstore_v1_kvstore_proto protoreflect.FileDescriptor
+func (x *GetResponse) GetToken() []byte {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
 
-const file_kvstore_v1_kvstore_proto_rawDesc = "" +
-	"\n" +
-	"\x18kvstore/v1/kvstore.proto\x12\akvstore\"6\n" +
-	"\n" +
-	"PutRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
-	"\x06update\x18\x02 \x01(\fR\x06update\"\r\n" +
-	"\vPutResponse\"\x1e\n" +
-	"\n" +
-	"GetRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\"\x85\x01\n" +
-	"\vGetResponse\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
-	"\x06nodeId\x18\x02 \x01(\x04R\x06nodeId\x12\x18\n" +
-	"\aversion\x18\x03 \x01(\x04R\aversion\x120\n" +
-	"\x13writeTimeUnixMillis\x18\x04 \x01(\x04R\x13writeTimeUnixMillis2s\n" +
+func (x *GetResponse) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// An opaque causality token covering this delete, see PutResponse.
+	Token         []byte `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteResponse) GetToken() []byte {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
+type ScanRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only return keys that start with prefix. Strongly consistent keys are only returned when
+	// prefix lies within the strongly consistent keyspace.
+	Prefix string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// Only return keys at or after startKey and strictly before endKey. Empty bounds are
+	// unbounded.
+	StartKey string `protobuf:"bytes,2,opt,name=startKey,proto3" json:"startKey,omitempty"`
+	EndKey   string `protobuf:"bytes,3,opt,name=endKey,proto3" json:"endKey,omitempty"`
+	// The maximum number of keys to return. Defaults to 100.
+	PageSize uint32 `protobuf:"varint,4,opt,name=pageSize,proto3" json:"pageSize,omitempty"`
+	// The nextPageToken of a previous response, to continue the scan where it left off.
+	PageToken string `protobuf:"bytes,5,opt,name=pageToken,proto3" json:"pageToken,omitempty"`
+	// Also return the clock and chunk count of each key.
+	IncludeDetails bool `protobuf:"varint,6,opt,name=includeDetails,proto3" json:"includeDetails,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ScanRequest) Reset() {
+	*x = ScanRequest{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanRequest) ProtoMessage() {}
+
+func (x *ScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanRequest.ProtoReflect.Descriptor instead.
+func (*ScanRequest) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ScanRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetStartKey() string {
+	if x != nil {
+		return x.StartKey
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetEndKey() string {
+	if x != nil {
+		return x.EndKey
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetPageSize() uint32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ScanRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetIncludeDetails() bool {
+	if x != nil {
+		return x.IncludeDetails
+	}
+	return false
+}
+
+type ScanResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Keys in lexicographic order.
+	Keys []*KeyInfo `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	// Empty once there are no more keys to scan.
+	NextPageToken string `protobuf:"bytes,2,opt,name=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanResponse) Reset() {
+	*x = ScanResponse{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanResponse) ProtoMessage() {}
+
+func (x *ScanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanResponse.ProtoReflect.Descriptor instead.
+func (*ScanResponse) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ScanResponse) GetKeys() []*KeyInfo {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *ScanResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type KeyInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Clock         *VectorClock           `protobuf:"bytes,2,opt,name=clock,proto3" json:"clock,omitempty"`
+	ChunkCount    uint64                 `protobuf:"varint,3,opt,name=chunkCount,proto3" json:"chunkCount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyInfo) Reset() {
+	*x = KeyInfo{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyInfo) ProtoMessage() {}
+
+func (x *KeyInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyInfo.ProtoReflect.Descriptor instead.
+func (*KeyInfo) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *KeyInfo) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyInfo) GetClock() *VectorClock {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *KeyInfo) GetChunkCount() uint64 {
+	if x != nil {
+		return x.ChunkCount
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Watch a single key. Takes precedence over prefix.
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Watch every key that starts with prefix. An empty prefix watches every key.
+	Prefix string `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// Send the live chunks of every watched key before streaming new ones.
+	Replay bool `protobuf:"varint,3,opt,name=replay,proto3" json:"replay,omitempty"`
+	// Per key clocks built from the chunks a previous watch already received. Only chunks
+	// newer than these are sent for those keys, so a reconnecting watcher neither misses nor
+	// duplicates chunks.
+	ResumeClocks  map[string]*VectorClock `protobuf:"bytes,4,rep,name=resumeClocks,proto3" json:"resumeClocks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetReplay() bool {
+	if x != nil {
+		return x.Replay
+	}
+	return false
+}
+
+func (x *WatchRequest) GetResumeClocks() map[string]*VectorClock {
+	if x != nil {
+		return x.ResumeClocks
+	}
+	return nil
+}
+
+type WatchResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Key                 string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Data                []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	NodeId              uint64                 `protobuf:"varint,3,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Version             uint64                 `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	WriteTimeUnixMillis uint64                 `protobuf:"varint,5,opt,name=writeTimeUnixMillis,proto3" json:"writeTimeUnixMillis,omitempty"`
+	// Set when this chunk deleted the key.
+	Tombstone     bool   `protobuf:"varint,6,opt,name=tombstone,proto3" json:"tombstone,omitempty"`
+	Epoch         uint64 `protobuf:"varint,7,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchResponse) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *WatchResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *WatchResponse) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *WatchResponse) GetWriteTimeUnixMillis() uint64 {
+	if x != nil {
+		return x.WriteTimeUnixMillis
+	}
+	return 0
+}
+
+func (x *WatchResponse) GetTombstone() bool {
+	if x != nil {
+		return x.Tombstone
+	}
+	return false
+}
+
+func (x *WatchResponse) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+type VectorClock struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Versions of writers in their first epoch, keyed by node id.
+	Clock map[uint64]uint64 `protobuf:"bytes,1,rep,name=clock,proto3" json:"clock,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Versions of writers in any later epoch. A node starts a new epoch when it restarts
+	// without its previous state, and counts versions from 1 again.
+	Entries       []*VersionEntry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VectorClock) Reset() {
+	*x = VectorClock{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VectorClock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VectorClock) ProtoMessage() {}
+
+func (x *VectorClock) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VectorClock.ProtoReflect.Descriptor instead.
+func (*VectorClock) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *VectorClock) GetClock() map[uint64]uint64 {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *VectorClock) GetEntries() []*VersionEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type VersionEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeId        uint64                 `protobuf:"varint,1,opt,name=nodeId,proto3" json:"nodeId,omitempty"`
+	Epoch         uint64                 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Version       uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionEntry) Reset() {
+	*x = VersionEntry{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionEntry) ProtoMessage() {}
+
+func (x *VersionEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionEntry.ProtoReflect.Descriptor instead.
+func (*VersionEntry) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *VersionEntry) GetNodeId() uint64 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *VersionEntry) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *VersionEntry) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type CompareAndSetRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// The value the register must hold. When unset, the register must never have been written.
+	Expected      []byte `protobuf:"bytes,2,opt,name=expected,proto3,oneof" json:"expected,omitempty"`
+	Update        []byte `protobuf:"bytes,3,opt,name=update,proto3" json:"update,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompareAndSetRequest) Reset() {
+	*x = CompareAndSetRequest{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompareAndSetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompareAndSetRequest) ProtoMessage() {}
+
+func (x *CompareAndSetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompareAndSetRequest.ProtoReflect.Descriptor instead.
+func (*CompareAndSetRequest) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CompareAndSetRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *CompareAndSetRequest) GetExpected() []byte {
+	if x != nil {
+		return x.Expected
+	}
+	return nil
+}
+
+func (x *CompareAndSetRequest) GetUpdate() []byte {
+	if x != nil {
+		return x.Update
+	}
+	return nil
+}
+
+type CompareAndSetResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Succeeded bool                   `protobuf:"varint,1,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	// Whether the register has been written, and its value after the request.
+	Exists        bool   `protobuf:"varint,2,opt,name=exists,proto3" json:"exists,omitempty"`
+	Current       []byte `protobuf:"bytes,3,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompareAndSetResponse) Reset() {
+	*x = CompareAndSetResponse{}
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompareAndSetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompareAndSetResponse) ProtoMessage() {}
+
+func (x *CompareAndSetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kvstore_v1_kvstore_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompareAndSetResponse.ProtoReflect.Descriptor instead.
+func (*CompareAndSetResponse) Descriptor() ([]byte, []int) {
+	return file_kvstore_v1_kvstore_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CompareAndSetResponse) GetSucceeded() bool {
+	if x != nil {
+		return x.Succeeded
+	}
+	return false
+}
+
+func (x *CompareAndSetResponse) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+func (x *CompareAndSetResponse) GetCurrent() []byte {
+	if x != nil {
+		return x.Current
+	}
+	return nil
+}
+
+var File_kvstore_v1_kvstore_proto protoreflect.FileDescriptor
+
+const file_kvstore_v1_kvstore_proto_rawDesc = "" +
+	"\n" +
+	"\x18kvstore/v1/kvstore.proto\x12\akvstore\"\xaa\x01\n" +
+	"\n" +
+	"PutRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06update\x18\x02 \x01(\fR\x06update\x12:\n" +
+	"\rexpectedClock\x18\x03 \x01(\v2\x14.kvstore.VectorClockR\rexpectedClock\x126\n" +
+	"\vconsistency\x18\x04 \x01(\x0e2\x14.kvstore.ConsistencyR\vconsistency\"#\n" +
+	"\vPutResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\fR\x05token\"r\n" +
+	"\n" +
+	"GetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1a\n" +
+	"\bminToken\x18\x02 \x01(\fR\bminToken\x126\n" +
+	"\vconsistency\x18\x03 \x01(\x0e2\x14.kvstore.ConsistencyR\vconsistency\"\xb1\x01\n" +
+	"\vGetResponse\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
+	"\x06nodeId\x18\x02 \x01(\x04R\x06nodeId\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x04R\aversion\x120\n" +
+	"\x13writeTimeUnixMillis\x18\x04 \x01(\x04R\x13writeTimeUnixMillis\x12\x14\n" +
+	"\x05token\x18\x05 \x01(\fR\x05token\x12\x14\n" +
+	"\x05epoch\x18\x06 \x01(\x04R\x05epoch\"!\n" +
+	"\rDeleteRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"&\n" +
+	"\x0eDeleteResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\fR\x05token\"\xbb\x01\n" +
+	"\vScanRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x1a\n" +
+	"\bstartKey\x18\x02 \x01(\tR\bstartKey\x12\x16\n" +
+	"\x06endKey\x18\x03 \x01(\tR\x06endKey\x12\x1a\n" +
+	"\bpageSize\x18\x04 \x01(\rR\bpageSize\x12\x1c\n" +
+	"\tpageToken\x18\x05 \x01(\tR\tpageToken\x12&\n" +
+	"\x0eincludeDetails\x18\x06 \x01(\bR\x0eincludeDetails\"Z\n" +
+	"\fScanResponse\x12$\n" +
+	"\x04keys\x18\x01 \x03(\v2\x10.kvstore.KeyInfoR\x04keys\x12$\n" +
+	"\rnextPageToken\x18\x02 \x01(\tR\rnextPageToken\"g\n" +
+	"\aKeyInfo\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\x05clock\x18\x02 \x01(\v2\x14.kvstore.VectorClockR\x05clock\x12\x1e\n" +
+	"\n" +
+	"chunkCount\x18\x03 \x01(\x04R\n" +
+	"chunkCount\"\xf4\x01\n" +
+	"\fWatchRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12\x16\n" +
+	"\x06replay\x18\x03 \x01(\bR\x06replay\x12K\n" +
+	"\fresumeClocks\x18\x04 \x03(\v2'.kvstore.WatchRequest.ResumeClocksEntryR\fresumeClocks\x1aU\n" +
+	"\x11ResumeClocksEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\x05value\x18\x02 \x01(\v2\x14.kvstore.VectorClockR\x05value:\x028\x01\"\xcd\x01\n" +
+	"\rWatchResponse\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x16\n" +
+	"\x06nodeId\x18\x03 \x01(\x04R\x06nodeId\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\x04R\aversion\x120\n" +
+	"\x13writeTimeUnixMillis\x18\x05 \x01(\x04R\x13writeTimeUnixMillis\x12\x1c\n" +
+	"\ttombstone\x18\x06 \x01(\bR\ttombstone\x12\x14\n" +
+	"\x05epoch\x18\a \x01(\x04R\x05epoch\"\xaf\x01\n" +
+	"\vVectorClock\x125\n" +
+	"\x05clock\x18\x01 \x03(\v2\x1f.kvstore.VectorClock.ClockEntryR\x05clock\x12/\n" +
+	"\aentries\x18\x02 \x03(\v2\x15.kvstore.VersionEntryR\aentries\x1a8\n" +
+	"\n" +
+	"ClockEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x04R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"V\n" +
+	"\fVersionEntry\x12\x16\n" +
+	"\x06nodeId\x18\x01 \x01(\x04R\x06nodeId\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x04R\x05epoch\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x04R\aversion\"n\n" +
+	"\x14CompareAndSetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1f\n" +
+	"\bexpected\x18\x02 \x01(\fH\x00R\bexpected\x88\x01\x01\x12\x16\n" +
+	"\x06update\x18\x03 \x01(\fR\x06updateB\v\n" +
+	"\t_expected\"g\n" +
+	"\x15CompareAndSetResponse\x12\x1c\n" +
+	"\tsucceeded\x18\x01 \x01(\bR\tsucceeded\x12\x16\n" +
+	"\x06exists\x18\x02 \x01(\bR\x06exists\x12\x18\n" +
+	"\acurrent\x18\x03 \x01(\fR\acurrent*+\n" +
+	"\vConsistency\x12\a\n" +
+	"\x03ONE\x10\x00\x12\n" +
+	"\n" +
+	"\x06QUORUM\x10\x01\x12\a\n" +
+	"\x03ALL\x10\x022\xf5\x02\n" +
 	"\akvstore\x122\n" +
 	"\x03Put\x12\x13.kvstore.PutRequest\x1a\x14.kvstore.PutResponse\"\x00\x124\n" +
-	"\x03Get\x12\x13.kvstore.GetRequest\x1a\x14.kvstore.GetResponse\"\x000\x01B<Z:github.com/WadeCappa/consensus/pkg/go/kvstore/v1;kvstorepbb\x06proto3"
+	"\x03Get\x12\x13.kvstore.GetRequest\x1a\x14.kvstore.GetResponse\"\x000\x01\x12;\n" +
+	"\x06Delete\x12\x16.kvstore.DeleteRequest\x1a\x17.kvstore.DeleteResponse\"\x00\x125\n" +
+	"\x04Scan\x12\x14.kvstore.ScanRequest\x1a\x15.kvstore.ScanResponse\"\x00\x12:\n" +
+	"\x05Watch\x12\x15.kvstore.WatchRequest\x1a\x16.kvstore.WatchResponse\"\x000\x01\x12P\n" +
+	"\rCompareAndSet\x12\x1d.kvstore.CompareAndSetRequest\x1a\x1e.kvstore.CompareAndSetResponse\"\x00B<Z:github.com/WadeCappa/consensus/pkg/go/kvstore/v1;kvstorepbb\x06proto3"
 
 var (
 	file_kvstore_v1_kvstore_proto_rawDescOnce sync.Once
@@ -255,23 +1142,55 @@ func file_kvstore_v1_kvstore_proto_rawDescGZIP() []byte {
 	return file_kvstore_v1_kvstore_proto_rawDescData
 }
 
-var file_kvstore_v1_kvstore_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_kvstore_v1_kvstore_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_kvstore_v1_kvstore_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_kvstore_v1_kvstore_proto_goTypes = []any{
-	(*PutRequest)(nil),  // 0: kvstore.PutRequest
-	(*PutResponse)(nil), // 1: kvstore.PutResponse
-	(*GetRequest)(nil),  // 2: kvstore.GetRequest
-	(*GetResponse)(nil), // 3: kvstore.GetResponse
+	(Consistency)(0),              // 0: kvstore.Consistency
+	(*PutRequest)(nil),            // 1: kvstore.PutRequest
+	(*PutResponse)(nil),           // 2: kvstore.PutResponse
+	(*GetRequest)(nil),            // 3: kvstore.GetRequest
+	(*GetResponse)(nil),           // 4: kvstore.GetResponse
+	(*DeleteRequest)(nil),         // 5: kvstore.DeleteRequest
+	(*DeleteResponse)(nil),        // 6: kvstore.DeleteResponse
+	(*ScanRequest)(nil),           // 7: kvstore.ScanRequest
+	(*ScanResponse)(nil),          // 8: kvstore.ScanResponse
+	(*KeyInfo)(nil),               // 9: kvstore.KeyInfo
+	(*WatchRequest)(nil),          // 10: kvstore.WatchRequest
+	(*WatchResponse)(nil),         // 11: kvstore.WatchResponse
+	(*VectorClock)(nil),           // 12: kvstore.VectorClock
+	(*VersionEntry)(nil),          // 13: kvstore.VersionEntry
+	(*CompareAndSetRequest)(nil),  // 14: kvstore.CompareAndSetRequest
+	(*CompareAndSetResponse)(nil), // 15: kvstore.CompareAndSetResponse
+	nil,                           // 16: kvstore.WatchRequest.ResumeClocksEntry
+	nil,                           // 17: kvstore.VectorClock.ClockEntry
 }
 var file_kvstore_v1_kvstore_proto_depIdxs = []int32{
-	0, // 0: kvstore.kvstore.Put:input_type -> kvstore.PutRequest
-	2, // 1: kvstore.kvstore.Get:input_type -> kvstore.GetRequest
-	1, // 2: kvstore.kvstore.Put:output_type -> kvstore.PutResponse
-	3, // 3: kvstore.kvstore.Get:output_type -> kvstore.GetResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	12, // 0: kvstore.PutRequest.expectedClock:type_name -> kvstore.VectorClock
+	0,  // 1: kvstore.PutRequest.consistency:type_name -> kvstore.Consistency
+	0,  // 2: kvstore.GetRequest.consistency:type_name -> kvstore.Consistency
+	9,  // 3: kvstore.ScanResponse.keys:type_name -> kvstore.KeyInfo
+	12, // 4: kvstore.KeyInfo.clock:type_name -> kvstore.VectorClock
+	16, // 5: kvstore.WatchRequest.resumeClocks:type_name -> kvstore.WatchRequest.ResumeClocksEntry
+	17, // 6: kvstore.VectorClock.clock:type_name -> kvstore.VectorClock.ClockEntry
+	13, // 7: kvstore.VectorClock.entries:type_name -> kvstore.VersionEntry
+	12, // 8: kvstore.WatchRequest.ResumeClocksEntry.value:type_name -> kvstore.VectorClock
+	1,  // 9: kvstore.kvstore.Put:input_type -> kvstore.PutRequest
+	3,  // 10: kvstore.kvstore.Get:input_type -> kvstore.GetRequest
+	5,  // 11: kvstore.kvstore.Delete:input_type -> kvstore.DeleteRequest
+	7,  // 12: kvstore.kvstore.Scan:input_type -> kvstore.ScanRequest
+	10, // 13: kvstore.kvstore.Watch:input_type -> kvstore.WatchRequest
+	14, // 14: kvstore.kvstore.CompareAndSet:input_type -> kvstore.CompareAndSetRequest
+	2,  // 15: kvstore.kvstore.Put:output_type -> kvstore.PutResponse
+	4,  // 16: kvstore.kvstore.Get:output_type -> kvstore.GetResponse
+	6,  // 17: kvstore.kvstore.Delete:output_type -> kvstore.DeleteResponse
+	8,  // 18: kvstore.kvstore.Scan:output_type -> kvstore.ScanResponse
+	11, // 19: kvstore.kvstore.Watch:output_type -> kvstore.WatchResponse
+	15, // 20: kvstore.kvstore.CompareAndSet:output_type -> kvstore.CompareAndSetResponse
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_kvstore_v1_kvstore_proto_init() }
@@ -279,18 +1198,20 @@ func file_kvstore_v1_kvstore_proto_init() {
 	if File_kvstore_v1_kvstore_proto != nil {
 		return
 	}
+	file_kvstore_v1_kvstore_proto_msgTypes[13].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_kvstore_v1_kvstore_proto_rawDesc), len(file_kvstore_v1_kvstore_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_kvstore_v1_kvstore_proto_goTypes,
 		DependencyIndexes: file_kvstore_v1_kvstore_proto_depIdxs,
+		EnumInfos:         file_kvstore_v1_kvstore_proto_enumTypes,
 		MessageInfos:      file_kvstore_v1_kvstore_proto_msgTypes,
 	}.Build()
 	File_kvstore_v1_kvstore_proto = out.File
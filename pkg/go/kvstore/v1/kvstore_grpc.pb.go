@@ -19,8 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Kvstore_Put_FullMethodName = "/kvstore.kvstore/Put"
-	Kvstore_Get_FullMethodName = "/kvstore.kvstore/Get"
+	Kvstore_Put_FullMethodName           = "/kvstore.kvstore/Put"
+	Kvstore_Get_FullMethodName           = "/kvstore.kvstore/Get"
+	Kvstore_Delete_FullMethodName        = "/kvstore.kvstore/Delete"
+	Kvstore_Scan_FullMethodName          = "/kvstore.kvstore/Scan"
+	Kvstore_Watch_FullMethodName         = "/kvstore.kvstore/Watch"
+	Kvstore_CompareAndSet_FullMethodName = "/kvstore.kvstore/CompareAndSet"
 )
 
 // KvstoreClient is the client API for Kvstore service.
@@ -29,6 +33,14 @@ const (
 type KvstoreClient interface {
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetResponse], error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
+	// CompareAndSet replaces the value of a register if it currently holds the expected value.
+	// Registers are agreed on with Paxos among all nodes, so of several racing writers
+	// expecting the same value exactly one succeeds. They live apart from the keys written by
+	// Put and are not visible to Get, Scan or Watch.
+	CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*CompareAndSetResponse, error)
 }
 
 type kvstoreClient struct {
@@ -68,12 +80,69 @@ func (c *kvstoreClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.Ca
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Kvstore_GetClient = grpc.ServerStreamingClient[GetResponse]
 
+func (c *kvstoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Kvstore_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kvstoreClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, Kvstore_Scan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kvstoreClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Kvstore_ServiceDesc.Streams[1], Kvstore_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Kvstore_WatchClient = grpc.ServerStreamingClient[WatchResponse]
+
+func (c *kvstoreClient) CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*CompareAndSetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompareAndSetResponse)
+	err := c.cc.Invoke(ctx, Kvstore_CompareAndSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // KvstoreServer is the server API for Kvstore service.
 // All implementations must embed UnimplementedKvstoreServer
 // for forward compatibility.
 type KvstoreServer interface {
 	Put(context.Context, *PutRequest) (*PutResponse, error)
 	Get(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
+	// CompareAndSet replaces the value of a register if it currently holds the expected value.
+	// Registers are agreed on with Paxos among all nodes, so of several racing writers
+	// expecting the same value exactly one succeeds. They live apart from the keys written by
+	// Put and are not visible to Get, Scan or Watch.
+	CompareAndSet(context.Context, *CompareAndSetRequest) (*CompareAndSetResponse, error)
 	mustEmbedUnimplementedKvstoreServer()
 }
 
@@ -90,6 +159,18 @@ func (UnimplementedKvstoreServer) Put(context.Context, *PutRequest) (*PutRespons
 func (UnimplementedKvstoreServer) Get(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error {
 	return status.Error(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedKvstoreServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKvstoreServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedKvstoreServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error {
+	return status.Error(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedKvstoreServer) CompareAndSet(context.Context, *CompareAndSetRequest) (*CompareAndSetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CompareAndSet not implemented")
+}
 func (UnimplementedKvstoreServer) mustEmbedUnimplementedKvstoreServer() {}
 func (UnimplementedKvstoreServer) testEmbeddedByValue()                 {}
 
@@ -140,6 +221,71 @@ func _Kvstore_Get_Handler(srv interface{}, stream grpc.ServerStream) error {
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Kvstore_GetServer = grpc.ServerStreamingServer[GetResponse]
 
+func _Kvstore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KvstoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kvstore_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KvstoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kvstore_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KvstoreServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kvstore_Scan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KvstoreServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kvstore_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KvstoreServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Kvstore_WatchServer = grpc.ServerStreamingServer[WatchResponse]
+
+func _Kvstore_CompareAndSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KvstoreServer).CompareAndSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kvstore_CompareAndSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KvstoreServer).CompareAndSet(ctx, req.(*CompareAndSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Kvstore_ServiceDesc is the grpc.ServiceDesc for Kvstore service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -151,6 +297,18 @@ var Kvstore_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Put",
 			Handler:    _Kvstore_Put_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _Kvstore_Delete_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _Kvstore_Scan_Handler,
+		},
+		{
+			MethodName: "CompareAndSet",
+			Handler:    _Kvstore_CompareAndSet_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -158,6 +316,11 @@ var Kvstore_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Kvstore_Get_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Kvstore_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "kvstore/v1/kvstore.proto",
 }